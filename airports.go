@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/airports"
+)
+
+// airportRefreshInterval is how often the airport directory is reloaded to
+// pick up new imports
+const airportRefreshInterval = time.Hour
+
+// loadAirports loads the airport directory used for flight phase
+// detection. It returns nil if the airports table cannot be read.
+func loadAirports() *airports.Directory {
+	dir, err := airports.LoadDirectory()
+	if err != nil {
+		log.Printf("Error loading airports: %v", err)
+		return nil
+	}
+	if dir.Len() == 0 {
+		log.Printf("No airports imported, flight phases are detected without airport positions")
+	} else {
+		log.Printf("Loaded %d airports", dir.Len())
+	}
+	return dir
+}
+
+// refreshAirports periodically reloads the airport directory
+func refreshAirports(dir *airports.Directory) {
+	for {
+		time.Sleep(airportRefreshInterval)
+		if err := dir.Reload(); err != nil {
+			log.Printf("Error reloading airports: %v", err)
+		}
+	}
+}
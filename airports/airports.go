@@ -0,0 +1,130 @@
+// Package airports provides airport reference data: importing it from an
+// OurAirports style CSV and looking airports up by code.
+package airports
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// ErrNotFound is returned when no airport matches a code
+var ErrNotFound = errors.New("airport not found")
+
+// Airport is the reference data of one airport
+type Airport struct {
+	Ident        string  `json:"ident"`
+	Type         string  `json:"type"`
+	Name         string  `json:"name"`
+	Latitude     float64 `json:"latitude"`
+	Longitude    float64 `json:"longitude"`
+	Elevation    *int    `json:"elevation_ft"`
+	Continent    string  `json:"continent,omitempty"`
+	Country      string  `json:"country,omitempty"`
+	Region       string  `json:"region,omitempty"`
+	Municipality string  `json:"municipality,omitempty"`
+	ICAO         string  `json:"icao,omitempty"`
+	GPSCode      string  `json:"gps_code,omitempty"`
+	IATA         string  `json:"iata,omitempty"`
+}
+
+// airportColumns are the airports table columns in Airport field order
+const airportColumns = `
+	ident, type, name, latitude, longitude, elevation_ft,
+	COALESCE(continent, ''), COALESCE(iso_country, ''), COALESCE(iso_region, ''),
+	COALESCE(municipality, ''), COALESCE(icao_code, ''), COALESCE(gps_code, ''),
+	COALESCE(iata_code, '')`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAirport(row scanner) (*Airport, error) {
+	var a Airport
+	var elevation sql.NullInt64
+	err := row.Scan(
+		&a.Ident, &a.Type, &a.Name, &a.Latitude, &a.Longitude, &elevation,
+		&a.Continent, &a.Country, &a.Region,
+		&a.Municipality, &a.ICAO, &a.GPSCode,
+		&a.IATA,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if elevation.Valid {
+		e := int(elevation.Int64)
+		a.Elevation = &e
+	}
+	return &a, nil
+}
+
+// Lookup finds an airport by identifier, ICAO code or GPS code, preferring
+// an exact identifier match
+func Lookup(code string) (*Airport, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	a, err := scanAirport(db.DB.QueryRow(`
+		SELECT `+airportColumns+`
+		FROM airports
+		WHERE ident = $1 OR icao_code = $1 OR gps_code = $1
+		ORDER BY ident = $1 DESC, icao_code = $1 DESC
+		LIMIT 1
+	`, code))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return a, err
+}
+
+// LookupMany finds the airports for several codes with a single query. The
+// result is keyed by the requested code; unknown codes are left out.
+func LookupMany(codes []string) (map[string]*Airport, error) {
+	found := make(map[string]*Airport)
+	if len(codes) == 0 {
+		return found, nil
+	}
+
+	upper := make([]string, 0, len(codes))
+	for _, code := range codes {
+		upper = append(upper, strings.ToUpper(strings.TrimSpace(code)))
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT `+airportColumns+`
+		FROM airports
+		WHERE ident = ANY($1) OR icao_code = ANY($1) OR gps_code = ANY($1)
+	`, pq.Array(upper))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*Airport
+	for rows.Next() {
+		a, err := scanAirport(rows)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Resolve codes with the same precedence as a Directory
+	d := &Directory{}
+	list := make([]Airport, len(matches))
+	for i, a := range matches {
+		list[i] = *a
+	}
+	d.set(list)
+	for _, code := range upper {
+		if a, ok := d.Get(code); ok {
+			found[code] = a
+		}
+	}
+	return found, nil
+}
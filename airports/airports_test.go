@@ -0,0 +1,63 @@
+package airports
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleCSV = `"id","ident","type","name","latitude_deg","longitude_deg","elevation_ft","continent","iso_country","iso_region","municipality","scheduled_service","icao_code","iata_code","gps_code","local_code","home_link","wikipedia_link","keywords"
+3622,"KJFK","large_airport","John F Kennedy International Airport",40.639447,-73.779317,13,"NA","US","US-NY","New York","yes","KJFK","JFK","KJFK","JFK",,,
+20462,"K00","small_airport","Example Field",40.1,-74.2,,"NA","US","US-NJ","Nowhere","no",,,"KXYZ","00",,,
+1,"XXXX","closed","Closed Field",1,2,3,"EU","GB","GB-ENG",,"no",,,,,,,
+`
+
+func TestParseCSV(t *testing.T) {
+	airports, err := ParseCSV(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(airports) != 2 {
+		t.Fatalf("expected 2 open airports, got %d", len(airports))
+	}
+
+	jfk := airports[0]
+	if jfk.Ident != "KJFK" || jfk.IATA != "JFK" || jfk.Country != "US" || jfk.Region != "US-NY" {
+		t.Errorf("unexpected airport %+v", jfk)
+	}
+	if jfk.Elevation == nil || *jfk.Elevation != 13 {
+		t.Errorf("elevation = %v, want 13", jfk.Elevation)
+	}
+	if airports[1].Elevation != nil {
+		t.Errorf("missing elevation should be nil, got %v", *airports[1].Elevation)
+	}
+}
+
+func TestParseCSVMissingColumn(t *testing.T) {
+	_, err := ParseCSV(strings.NewReader("ident,name\nKJFK,Kennedy\n"))
+	if err == nil {
+		t.Fatal("expected an error for a CSV without coordinates")
+	}
+}
+
+func TestDirectoryLookup(t *testing.T) {
+	airports, err := ParseCSV(strings.NewReader(sampleCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := NewDirectory(airports)
+
+	if a, ok := d.Get("kjfk"); !ok || a.Name != "John F Kennedy International Airport" {
+		t.Errorf("lookup by ident failed: %+v", a)
+	}
+	if a, ok := d.Get("KXYZ"); !ok || a.Ident != "K00" {
+		t.Errorf("lookup by GPS code failed: %+v", a)
+	}
+	if _, ok := d.Get("XXXX"); ok {
+		t.Error("closed airports should not be imported")
+	}
+
+	lat, lon, elevation, ok := d.Locate("KJFK")
+	if !ok || lat != 40.639447 || lon != -73.779317 || elevation != 13 {
+		t.Errorf("Locate = %v, %v, %v, %v", lat, lon, elevation, ok)
+	}
+}
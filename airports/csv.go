@@ -0,0 +1,92 @@
+package airports
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// requiredColumns must be present in the header of an airport CSV
+var requiredColumns = []string{"ident", "type", "name", "latitude_deg", "longitude_deg"}
+
+// ParseCSV reads airports from an OurAirports style CSV with a header row.
+// Columns are matched by name, so extra or reordered columns are fine.
+// Closed airports are skipped.
+func ParseCSV(r io.Reader) ([]Airport, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading header: %v", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(strings.ToLower(name))] = i
+	}
+	for _, name := range requiredColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %q", name)
+		}
+	}
+
+	var airports []Airport
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		if field("type") == "closed" {
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		a := Airport{
+			Ident:        strings.ToUpper(field("ident")),
+			Type:         field("type"),
+			Name:         field("name"),
+			Continent:    field("continent"),
+			Country:      field("iso_country"),
+			Region:       field("iso_region"),
+			Municipality: field("municipality"),
+			ICAO:         strings.ToUpper(field("icao_code")),
+			GPSCode:      strings.ToUpper(field("gps_code")),
+			IATA:         strings.ToUpper(field("iata_code")),
+		}
+		if a.Ident == "" {
+			return nil, fmt.Errorf("line %d: empty ident", line)
+		}
+
+		if a.Latitude, err = strconv.ParseFloat(field("latitude_deg"), 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid latitude: %v", line, err)
+		}
+		if a.Longitude, err = strconv.ParseFloat(field("longitude_deg"), 64); err != nil {
+			return nil, fmt.Errorf("line %d: invalid longitude: %v", line, err)
+		}
+		if v := field("elevation_ft"); v != "" {
+			elevation, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid elevation: %v", line, err)
+			}
+			a.Elevation = &elevation
+		}
+
+		airports = append(airports, a)
+	}
+
+	return airports, nil
+}
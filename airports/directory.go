@@ -0,0 +1,106 @@
+package airports
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// Directory is an in-memory copy of the airports table for lookups on hot
+// paths such as the collector. It is safe for concurrent use.
+type Directory struct {
+	mu       sync.RWMutex
+	byCode   map[string]*Airport
+	airports int
+}
+
+// NewDirectory builds a directory from a list of airports
+func NewDirectory(airports []Airport) *Directory {
+	d := &Directory{}
+	d.set(airports)
+	return d
+}
+
+// LoadDirectory builds a directory from the airports table
+func LoadDirectory() (*Directory, error) {
+	d := &Directory{}
+	if err := d.Reload(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Reload replaces the directory contents with the airports table
+func (d *Directory) Reload() error {
+	rows, err := db.DB.Query(`SELECT ` + airportColumns + ` FROM airports`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var airports []Airport
+	for rows.Next() {
+		a, err := scanAirport(rows)
+		if err != nil {
+			return err
+		}
+		airports = append(airports, *a)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	d.set(airports)
+	return nil
+}
+
+// set indexes airports by identifier, ICAO code and GPS code. Identifiers
+// take precedence over the other codes, and ICAO codes over GPS codes.
+func (d *Directory) set(airports []Airport) {
+	byCode := make(map[string]*Airport, len(airports))
+	for _, code := range []func(a *Airport) string{
+		func(a *Airport) string { return a.GPSCode },
+		func(a *Airport) string { return a.ICAO },
+		func(a *Airport) string { return a.Ident },
+	} {
+		for i := range airports {
+			if c := code(&airports[i]); c != "" {
+				byCode[c] = &airports[i]
+			}
+		}
+	}
+
+	d.mu.Lock()
+	d.byCode = byCode
+	d.airports = len(airports)
+	d.mu.Unlock()
+}
+
+// Len returns the number of airports in the directory
+func (d *Directory) Len() int {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.airports
+}
+
+// Get returns the airport for a code
+func (d *Directory) Get(code string) (*Airport, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	a, ok := d.byCode[strings.ToUpper(code)]
+	return a, ok
+}
+
+// Locate returns the position and elevation of an airport. Airports
+// without a known elevation are treated as being at sea level.
+func (d *Directory) Locate(code string) (lat, lon float64, elevation int, ok bool) {
+	a, ok := d.Get(code)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	if a.Elevation != nil {
+		elevation = *a.Elevation
+	}
+	return a.Latitude, a.Longitude, elevation, true
+}
@@ -0,0 +1,71 @@
+package airports
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// Import replaces the airports table with the given airports in a single
+// transaction. Readers see the previous data until it commits.
+func Import(airports []Airport) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM airports`); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("airports",
+		"ident", "type", "name", "latitude", "longitude", "elevation_ft",
+		"continent", "iso_country", "iso_region", "municipality",
+		"icao_code", "gps_code", "iata_code"))
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(airports))
+	for _, a := range airports {
+		if seen[a.Ident] {
+			stmt.Close()
+			return fmt.Errorf("duplicate airport %s", a.Ident)
+		}
+		seen[a.Ident] = true
+
+		var elevation interface{}
+		if a.Elevation != nil {
+			elevation = *a.Elevation
+		}
+		_, err := stmt.Exec(
+			a.Ident, a.Type, a.Name, a.Latitude, a.Longitude, elevation,
+			nullable(a.Continent), nullable(a.Country), nullable(a.Region), nullable(a.Municipality),
+			nullable(a.ICAO), nullable(a.GPSCode), nullable(a.IATA),
+		)
+		if err != nil {
+			stmt.Close()
+			return fmt.Errorf("error copying airport %s: %v", a.Ident, err)
+		}
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// nullable stores empty strings as NULL
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
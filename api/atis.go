@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// GetAirportATIS returns the ATIS stations of an airport that were online
+// between from and to, with every letter and runway change they broadcast
+func GetAirportATIS(w http.ResponseWriter, r *http.Request) {
+	icao := strings.ToUpper(mux.Vars(r)["icao"])
+
+	from, to, err := parseCoverageRange(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := queryATISSessions(icao, from, to)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err := loadATISUpdates(sessions); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	history := ATISHistory{
+		ICAO:     icao,
+		From:     from,
+		To:       to,
+		Sessions: make([]ATISSession, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		session.Updates = len(session.Letters)
+		history.Sessions = append(history.Sessions, *session)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// atisCallsigns returns LIKE patterns for the ATIS callsigns of an
+// airport: ICAO_ATIS and the split ones such as ICAO_D_ATIS
+func atisCallsigns(icao string) []string {
+	prefix := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(icao)
+	return []string{prefix + `\_ATIS`, prefix + `\_%\_ATIS`}
+}
+
+// queryATISSessions returns the ATIS sessions of an airport that overlap
+// from to to, latest first
+func queryATISSessions(icao string, from, to time.Time) ([]*ATISSession, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, vatsim_id, callsign, COALESCE(frequency, ''), start_time, end_time, NOT closed
+		FROM connections
+		WHERE type = $1
+		AND UPPER(callsign) LIKE ANY($2)
+		AND start_time < $4 AND end_time > $3
+		ORDER BY start_time DESC
+	`, TypeATIS, pq.Array(atisCallsigns(icao)), from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*ATISSession
+	for rows.Next() {
+		session := &ATISSession{Letters: make([]ATISLetter, 0)}
+		err := rows.Scan(
+			&session.ConnectionID, &session.VatsimID, &session.Callsign, &session.Frequency,
+			&session.Start, &session.End, &session.Online,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// loadATISUpdates fills in the broadcasts of each session in order
+func loadATISUpdates(sessions []*ATISSession) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*ATISSession, len(sessions))
+	ids := make([]int64, 0, len(sessions))
+	for _, session := range sessions {
+		byID[session.ConnectionID] = session
+		ids = append(ids, session.ConnectionID)
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT connection_id, updated_at, letter, runways
+		FROM atis_updates
+		WHERE connection_id = ANY($1)
+		ORDER BY connection_id, updated_at
+	`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		letter := ATISLetter{Runways: make([]string, 0)}
+		if err := rows.Scan(&id, &letter.Time, &letter.Letter, pq.Array(&letter.Runways)); err != nil {
+			return err
+		}
+		if session := byID[id]; session != nil {
+			session.Letters = append(session.Letters, letter)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,14 @@
+package api
+
+import "testing"
+
+func TestATISCallsigns(t *testing.T) {
+	got := atisCallsigns("EGLL")
+	want := []string{`EGLL\_ATIS`, `EGLL\_%\_ATIS`}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("atisCallsigns(EGLL) = %v, want %v", got, want)
+	}
+	if got := atisCallsigns("EG%"); got[0] != `EG\%\_ATIS` {
+		t.Errorf("wildcards are not escaped: %v", got)
+	}
+}
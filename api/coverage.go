@@ -0,0 +1,193 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// maxCoverageRange is the longest timeline the coverage endpoint returns
+const maxCoverageRange = 31 * 24 * time.Hour
+
+// interval is a span of time during which a position was staffed
+type interval struct {
+	start, end time.Time
+}
+
+// mergeIntervals sorts intervals and joins those that overlap or touch
+func mergeIntervals(intervals []interval) []interval {
+	sorted := append([]interval(nil), intervals...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start.Before(sorted[j].start) })
+
+	merged := make([]interval, 0, len(sorted))
+	for _, iv := range sorted {
+		if n := len(merged); n > 0 && !iv.start.After(merged[n-1].end) {
+			if iv.end.After(merged[n-1].end) {
+				merged[n-1].end = iv.end
+			}
+			continue
+		}
+		merged = append(merged, iv)
+	}
+	return merged
+}
+
+// coveredDuration returns how much of from to to merged intervals cover
+func coveredDuration(merged []interval, from, to time.Time) time.Duration {
+	var covered time.Duration
+	for _, iv := range merged {
+		start, end := iv.start, iv.end
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if end.After(start) {
+			covered += end.Sub(start)
+		}
+	}
+	return covered
+}
+
+func durationHours(d time.Duration) float64 {
+	return secondsToHours(int64(d / time.Second))
+}
+
+// queryFacilitySessions returns the controller sessions classified under a
+// facility that overlap from to to, clipped to it and keyed by callsign. Observers and
+// other callsigns that are not a controlling position are left out.
+func queryFacilitySessions(facility string, from, to time.Time) (map[string][]interval, error) {
+	rows, err := db.DB.Query(`
+		SELECT callsign, GREATEST(start_time, $3), LEAST(end_time, $4)
+		FROM connections
+		WHERE type = $1 AND facility = $2
+		AND controller_position(callsign) <> 'OTHER'
+		AND start_time < $4 AND end_time > $3
+	`, TypeATC, facility, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("error querying facility sessions: %v", err)
+	}
+	defer rows.Close()
+
+	sessions := make(map[string][]interval)
+	for rows.Next() {
+		var callsign string
+		var iv interval
+		if err := rows.Scan(&callsign, &iv.start, &iv.end); err != nil {
+			return nil, fmt.Errorf("error scanning facility session: %v", err)
+		}
+		sessions[callsign] = append(sessions[callsign], iv)
+	}
+	return sessions, rows.Err()
+}
+
+// allIntervals flattens sessions keyed by callsign
+func allIntervals(sessions map[string][]interval) []interval {
+	var all []interval
+	for _, intervals := range sessions {
+		all = append(all, intervals...)
+	}
+	return all
+}
+
+func coverageIntervals(merged []interval) []CoverageInterval {
+	timeline := make([]CoverageInterval, 0, len(merged))
+	for _, iv := range merged {
+		timeline = append(timeline, CoverageInterval{Start: iv.start, End: iv.end})
+	}
+	return timeline
+}
+
+// parseCoverageRange reads the from and to parameters as RFC 3339 times
+// or dates. It defaults to the 24 hours up to now.
+func parseCoverageRange(query url.Values, now time.Time) (time.Time, time.Time, error) {
+	parse := func(name string, def time.Time) (time.Time, error) {
+		value := query.Get(name)
+		if value == "" {
+			return def, nil
+		}
+		if t, err := time.Parse(time.RFC3339, value); err == nil {
+			return t, nil
+		}
+		if t, err := time.Parse("2006-01-02", value); err == nil {
+			return t, nil
+		}
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time or a date", name)
+	}
+
+	to, err := parse("to", now)
+	if err != nil {
+		return to, to, err
+	}
+	from, err := parse("from", to.Add(-24*time.Hour))
+	if err != nil {
+		return from, to, err
+	}
+
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("from must be before to")
+	}
+	if to.Sub(from) > maxCoverageRange {
+		return from, to, fmt.Errorf("the range may not exceed %d days", int(maxCoverageRange.Hours()/24))
+	}
+	return from, to, nil
+}
+
+// GetFacilityCoverage returns when a facility and each of its positions
+// were staffed between from and to
+func GetFacilityCoverage(w http.ResponseWriter, r *http.Request) {
+	facility := facilityRegistry.Get(mux.Vars(r)["facility"])
+
+	from, to, err := parseCoverageRange(r.URL.Query(), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sessions, err := queryFacilitySessions(facility.ID, from, to)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	merged := mergeIntervals(allIntervals(sessions))
+	covered := coveredDuration(merged, from, to)
+	coverage := FacilityCoverage{
+		Facility:        facility.ID,
+		Name:            facility.Name,
+		From:            from,
+		To:              to,
+		CoveredHours:    durationHours(covered),
+		CoveragePercent: roundPercent(float64(covered) / float64(to.Sub(from)) * 100),
+		Timeline:        coverageIntervals(merged),
+		Positions:       make([]PositionCoverage, 0, len(sessions)),
+	}
+
+	for callsign, intervals := range sessions {
+		position := mergeIntervals(intervals)
+		coverage.Positions = append(coverage.Positions, PositionCoverage{
+			Callsign:  callsign,
+			Hours:     durationHours(coveredDuration(position, from, to)),
+			Intervals: coverageIntervals(position),
+		})
+	}
+	sort.Slice(coverage.Positions, func(i, j int) bool {
+		return coverage.Positions[i].Callsign < coverage.Positions[j].Callsign
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(coverage)
+}
+
+// roundPercent rounds a percentage to one decimal
+func roundPercent(p float64) float64 {
+	return math.Round(p*10) / 10
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+var coverageEpoch = time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)
+
+func hoursAfter(h float64) time.Time {
+	return coverageEpoch.Add(time.Duration(h * float64(time.Hour)))
+}
+
+func TestMergeIntervals(t *testing.T) {
+	merged := mergeIntervals([]interval{
+		{hoursAfter(5), hoursAfter(6)},
+		{hoursAfter(0), hoursAfter(2)},
+		{hoursAfter(1), hoursAfter(3)},
+		// Touching the previous interval joins it
+		{hoursAfter(3), hoursAfter(4)},
+		{hoursAfter(5.5), hoursAfter(5.75)},
+	})
+
+	want := []interval{{hoursAfter(0), hoursAfter(4)}, {hoursAfter(5), hoursAfter(6)}}
+	if len(merged) != len(want) {
+		t.Fatalf("merged = %v, want %v", merged, want)
+	}
+	for i := range want {
+		if !merged[i].start.Equal(want[i].start) || !merged[i].end.Equal(want[i].end) {
+			t.Errorf("interval %d = %v, want %v", i, merged[i], want[i])
+		}
+	}
+
+	if got := coveredDuration(merged, hoursAfter(3), hoursAfter(5.5)); got != 90*time.Minute {
+		t.Errorf("covered = %v, want 1h30m", got)
+	}
+}
+
+func TestParseCoverageRange(t *testing.T) {
+	now := hoursAfter(12)
+
+	from, to, err := parseCoverageRange(url.Values{}, now)
+	if err != nil || !to.Equal(now) || to.Sub(from) != 24*time.Hour {
+		t.Errorf("default range = %v to %v, %v", from, to, err)
+	}
+
+	from, to, err = parseCoverageRange(url.Values{"from": {"2024-03-01"}, "to": {"2024-03-02T06:00:00Z"}}, now)
+	if err != nil || from.Day() != 1 || to.Hour() != 6 {
+		t.Errorf("explicit range = %v to %v, %v", from, to, err)
+	}
+
+	invalid := []url.Values{
+		{"from": {"yesterday"}},
+		{"from": {"2024-03-02"}, "to": {"2024-03-01"}},
+		{"from": {"2024-01-01"}, "to": {"2024-03-01"}},
+	}
+	for _, query := range invalid {
+		if _, _, err := parseCoverageRange(query, now); err == nil {
+			t.Errorf("%v should be rejected", query)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/airports"
+	"github.com/vainnor/vatsim-stats/db"
+	"github.com/vainnor/vatsim-stats/geo"
+)
+
+// fillRouteDistances sets the average distance flown by the flights that
+// landed on each route in the last 24 hours, and the direct distance
+// between its airports where both are known
+func fillRouteDistances(routes ...*RouteStatistics) error {
+	if len(routes) == 0 {
+		return nil
+	}
+
+	origins := make([]string, 0, len(routes))
+	destinations := make([]string, 0, len(routes))
+	for _, route := range routes {
+		origins = append(origins, route.Origin)
+		destinations = append(destinations, route.Destination)
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT f.departure, f.arrival, AVG(c.distance_nm)
+		FROM flights f
+		JOIN connections c ON c.id = f.connection_id
+		JOIN unnest($1::varchar[], $2::varchar[]) AS r(departure, arrival)
+			ON f.departure = r.departure AND f.arrival = r.arrival
+		WHERE f.landing_time > NOW() - INTERVAL '24 hours'
+		GROUP BY f.departure, f.arrival
+	`, pq.Array(origins), pq.Array(destinations))
+	if err != nil {
+		return fmt.Errorf("error querying route distances: %v", err)
+	}
+	defer rows.Close()
+
+	flown := make(map[[2]string]float64)
+	for rows.Next() {
+		var origin, destination string
+		var distance float64
+		if err := rows.Scan(&origin, &destination, &distance); err != nil {
+			return fmt.Errorf("error scanning route distance: %v", err)
+		}
+		flown[[2]string{origin, destination}] = distance
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	found, err := airports.LookupMany(append(origins, destinations...))
+	if err != nil {
+		return err
+	}
+
+	for _, route := range routes {
+		route.AvgDistanceNM = roundDistance(flown[[2]string{route.Origin, route.Destination}])
+
+		from, fromKnown := found[route.Origin]
+		to, toKnown := found[route.Destination]
+		if fromKnown && toKnown {
+			direct := roundDistance(geo.DistanceNM(from.Latitude, from.Longitude, to.Latitude, to.Longitude))
+			route.DirectDistanceNM = &direct
+		}
+	}
+	return nil
+}
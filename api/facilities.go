@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/db"
+	"github.com/vainnor/vatsim-stats/facilities"
+)
+
+// facilityRegistry defines the facilities served by the facility and
+// airport endpoints. Without one, a facility is the positions whose
+// callsign starts with its ID.
+var facilityRegistry *facilities.Registry
+
+// SetFacilities sets the facility registry. It must be called before the
+// API starts serving.
+func SetFacilities(registry *facilities.Registry) {
+	facilityRegistry = registry
+}
+
+// queryAirportControllers returns the controllers last reported in the
+// past five minutes that belong to a facility serving an airport
+func queryAirportControllers(icao string) ([]ActiveController, error) {
+	serving := facilityRegistry.ForAirport(icao)
+	var patterns []string
+	for _, f := range serving {
+		patterns = append(patterns, f.LikePatterns()...)
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT DISTINCT ON (c.callsign)
+			c.callsign, c.frequency, c.visual_range, c.cid, c.rating, c.name
+		FROM controllers c
+		WHERE c.callsign LIKE ANY($1)
+		AND c.last_updated > NOW() - INTERVAL '5 minutes'
+		ORDER BY c.callsign, c.last_updated DESC
+	`, pq.Array(patterns))
+	if err != nil {
+		return nil, fmt.Errorf("error querying airport controllers: %v", err)
+	}
+	defer rows.Close()
+
+	var controllers []ActiveController
+	for rows.Next() {
+		var ctrl ActiveController
+		err := rows.Scan(
+			&ctrl.Position,
+			&ctrl.Frequency,
+			&ctrl.VisualRange,
+			&ctrl.Controller.CID,
+			&ctrl.Controller.Rating,
+			&ctrl.Controller.Name,
+		)
+		if err != nil {
+			continue
+		}
+		if servedBy(serving, ctrl.Position, ctrl.Frequency) {
+			controllers = append(controllers, ctrl)
+		}
+	}
+	return controllers, rows.Err()
+}
+
+// servedBy reports whether a controller belongs to one of the facilities
+func servedBy(serving []*facilities.Facility, callsign, frequency string) bool {
+	for _, f := range serving {
+		if f.Matches(callsign, frequency) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vainnor/vatsim-stats/db"
+	"github.com/vainnor/vatsim-stats/firs"
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+// firIndex holds the imported FIR boundaries. Without one, nothing is
+// attributed to FIRs.
+var firIndex *firs.Index
+
+// SetFIRs sets the FIR boundaries. It must be called before the API starts
+// serving.
+func SetFIRs(index *firs.Index) {
+	firIndex = index
+}
+
+// firCounts is the number of pilots and controllers in each FIR
+type firCounts map[string]*FIRSummary
+
+// countFIRTraffic attributes the pilots and controllers of a snapshot to
+// FIRs. Pilots are located by position. Controllers are located by
+// callsign prefix when it names a FIR, as in EGTT_CTR, and otherwise by
+// their center; observers and ATIS are not counted.
+func countFIRTraffic(data *types.VatsimData) (firCounts, error) {
+	counts := make(firCounts)
+	if firIndex == nil || firIndex.Len() == 0 {
+		return counts, nil
+	}
+
+	summary := func(id string) *FIRSummary {
+		s, ok := counts[id]
+		if !ok {
+			s = &FIRSummary{ID: id}
+			if b, ok := firIndex.Get(id); ok {
+				s.Name, s.Region, s.Division, s.Oceanic = b.Name, b.Region, b.Division, b.Oceanic
+			}
+			counts[id] = s
+		}
+		return s
+	}
+
+	for _, pilot := range data.Pilots {
+		if fir := firIndex.FIR(pilot.Latitude, pilot.Longitude); fir != "" {
+			summary(fir).ActivePilots++
+		}
+	}
+
+	var callsigns []string
+	for _, controller := range data.Controllers {
+		if ControllerType(controller.Callsign, controller.Facility) == TypeATC {
+			callsigns = append(callsigns, controller.Callsign)
+		}
+	}
+	centers, err := controllerCenters(callsigns)
+	if err != nil {
+		return nil, err
+	}
+	for _, callsign := range callsigns {
+		if b, ok := firIndex.Get(callsignPrefix(callsign)); ok {
+			summary(b.ID).ActiveATCs++
+		} else if center := centers[callsign]; center != nil {
+			if fir := firIndex.FIR(center.Latitude, center.Longitude); fir != "" {
+				summary(fir).ActiveATCs++
+			}
+		}
+	}
+
+	return counts, nil
+}
+
+// sorted returns the FIRs busiest first
+func (counts firCounts) sorted() []FIRSummary {
+	list := make([]FIRSummary, 0, len(counts))
+	for _, s := range counts {
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.ActivePilots+a.ActiveATCs != b.ActivePilots+b.ActiveATCs {
+			return a.ActivePilots+a.ActiveATCs > b.ActivePilots+b.ActiveATCs
+		}
+		return a.ID < b.ID
+	})
+	return list
+}
+
+// GetFIRsHandler returns a handler listing the FIRs with their current
+// traffic. all=true includes FIRs without traffic.
+func GetFIRsHandler(collector Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := collector.GetCurrentData()
+		if err != nil {
+			http.Error(w, "No snapshot collected yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		counts, err := countFIRTraffic(data)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Get("all") == "true" && firIndex != nil {
+			for _, b := range firIndex.All() {
+				if _, ok := counts[b.ID]; !ok {
+					counts[b.ID] = &FIRSummary{ID: b.ID, Name: b.Name, Region: b.Region, Division: b.Division, Oceanic: b.Oceanic}
+				}
+			}
+		}
+
+		list := FIRList{
+			Timestamp: data.General.UpdateTimestamp,
+			FIRs:      counts.sorted(),
+		}
+		list.Total = len(list.FIRs)
+
+		if wantsGeoJSON(r) {
+			writeGeoJSON(w, firFeatures(list))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// firFeatures maps FIRs to their boundaries
+func firFeatures(list FIRList) FeatureCollection {
+	fc := newFeatureCollection()
+	for _, fir := range list.FIRs {
+		var geometry *Geometry
+		if b, ok := firIndex.Get(fir.ID); ok && len(b.Polygons) > 0 {
+			geometry = &Geometry{Type: "MultiPolygon", Coordinates: b.Polygons}
+		}
+		fc.add(geometry, fir)
+	}
+	return fc
+}
+
+// GetFIRTraffic returns the pilots currently within a FIR and the number
+// of pilots seen in it over the last day
+func GetFIRTraffic(w http.ResponseWriter, r *http.Request) {
+	var boundary *firs.Boundary
+	if firIndex != nil {
+		boundary, _ = firIndex.Get(mux.Vars(r)["fir"])
+	}
+	if boundary == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "FIR not found"})
+		return
+	}
+
+	traffic := FIRTraffic{
+		ID:        boundary.ID,
+		Name:      boundary.Name,
+		Region:    boundary.Region,
+		Division:  boundary.Division,
+		Oceanic:   boundary.Oceanic,
+		Timestamp: time.Now(),
+		Hourly:    make([]FIRHourlyCount, 0),
+		Pilots:    make([]FIRPilot, 0),
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT
+			p.cid, p.callsign, COALESCE(fp.departure, ''), COALESCE(fp.arrival, ''),
+			p.altitude, p.groundspeed, p.latitude, p.longitude, p.heading
+		FROM live_positions lp
+		JOIN pilots p ON p.id = lp.pilot_id AND p.snapshot_time = lp.snapshot_time
+		LEFT JOIN flight_plans fp ON fp.pilot_id = p.id AND fp.snapshot_time = p.snapshot_time
+		WHERE lp.fir = $1
+		AND lp.snapshot_time > NOW() - INTERVAL '5 minutes'
+		ORDER BY p.callsign
+	`, boundary.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pilot FIRPilot
+		err := rows.Scan(
+			&pilot.CID, &pilot.Callsign, &pilot.Departure, &pilot.Arrival,
+			&pilot.Altitude, &pilot.Groundspeed,
+			&pilot.Position.Latitude, &pilot.Position.Longitude, &pilot.Position.Heading,
+		)
+		if err != nil {
+			continue
+		}
+		traffic.Pilots = append(traffic.Pilots, pilot)
+	}
+	traffic.ActivePilots = len(traffic.Pilots)
+
+	// Unique pilots per hour, and over the last hour and day
+	err = db.DB.QueryRow(`
+		SELECT
+			COUNT(DISTINCT cid) FILTER (WHERE snapshot_time > NOW() - INTERVAL '1 hour'),
+			COUNT(DISTINCT cid)
+		FROM pilots
+		WHERE fir = $1 AND snapshot_time > NOW() - INTERVAL '24 hours'
+	`, boundary.ID).Scan(&traffic.LastHour, &traffic.LastDay)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err = db.DB.Query(`
+		SELECT date_trunc('hour', snapshot_time) AS hour, COUNT(DISTINCT cid)
+		FROM pilots
+		WHERE fir = $1 AND snapshot_time > NOW() - INTERVAL '24 hours'
+		GROUP BY hour
+		ORDER BY hour
+	`, boundary.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hour FIRHourlyCount
+		if err := rows.Scan(&hour.Hour, &hour.Pilots); err != nil {
+			continue
+		}
+		traffic.Hourly = append(traffic.Hourly, hour)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(traffic)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/vainnor/vatsim-stats/firs"
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+func TestCountFIRTrafficPilots(t *testing.T) {
+	square := func(west, south float64) [][][][2]float64 {
+		return [][][][2]float64{{{{west, south}, {west + 10, south}, {west + 10, south + 10}, {west, south + 10}}}}
+	}
+	SetFIRs(firs.NewIndex([]firs.Boundary{
+		{ID: "AAAA", Name: "Alpha", Polygons: square(0, 0)},
+		{ID: "BBBB", Polygons: square(20, 0)},
+	}))
+	defer SetFIRs(nil)
+
+	data := &types.VatsimData{Pilots: []types.Pilot{
+		{Latitude: 5, Longitude: 5},
+		{Latitude: 5, Longitude: 25},
+		{Latitude: 6, Longitude: 26},
+		{Latitude: 50, Longitude: 50},
+	}}
+	counts, err := countFIRTraffic(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := counts.sorted()
+	if len(list) != 2 {
+		t.Fatalf("expected two FIRs with traffic, got %+v", list)
+	}
+	if list[0].ID != "BBBB" || list[0].ActivePilots != 2 {
+		t.Errorf("busiest FIR = %+v, want BBBB with 2 pilots", list[0])
+	}
+	if list[1].ID != "AAAA" || list[1].Name != "Alpha" || list[1].ActivePilots != 1 {
+		t.Errorf("second FIR = %+v, want AAAA with 1 pilot", list[1])
+	}
+
+	fc := firFeatures(FIRList{FIRs: list})
+	if len(fc.Features) != 2 || fc.Features[0].Geometry == nil || fc.Features[0].Geometry.Type != "MultiPolygon" {
+		t.Errorf("unexpected features %+v", fc.Features)
+	}
+}
+
+func TestCountRegions(t *testing.T) {
+	square := func(west float64) [][][][2]float64 {
+		return [][][][2]float64{{{{west, 0}, {west + 10, 0}, {west + 10, 10}, {west, 10}}}}
+	}
+	SetFIRs(firs.NewIndex([]firs.Boundary{
+		{ID: "AAAA", Division: "ONE", Polygons: square(0)},
+		{ID: "BBBB", Division: "ONE", Polygons: square(20)},
+		{ID: "CCCC", Division: "TWO", Polygons: square(40)},
+		{ID: "DDDD", Polygons: square(60)},
+	}))
+	defer SetFIRs(nil)
+
+	data := &types.VatsimData{Pilots: []types.Pilot{
+		{Latitude: 5, Longitude: 5},
+		{Latitude: 5, Longitude: 25},
+		{Latitude: 5, Longitude: 45},
+		{Latitude: 5, Longitude: 65},
+	}}
+
+	grouping, _ := firs.GroupBy(firs.GroupDivision)
+	regions, err := countRegions(data, grouping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(regions) != 2 || regions[0].Region != "ONE" || regions[0].ActivePilots != 2 ||
+		regions[1].Region != "TWO" || regions[1].ActivePilots != 1 {
+		t.Errorf("unexpected regions %+v", regions)
+	}
+}
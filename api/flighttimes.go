@@ -0,0 +1,84 @@
+package api
+
+import (
+	"database/sql"
+	"time"
+)
+
+// flightTimeColumns selects the columns scanned into a flightTimeRow from
+// the flights table aliased as f
+const flightTimeColumns = `
+	f.filed_deptime, f.filed_enroute_time,
+	f.off_block_time, f.takeoff_time, f.landing_time, f.on_block_time`
+
+// flightTimeRow receives the columns of flightTimeColumns
+type flightTimeRow struct {
+	depTime, enrouteTime                sql.NullString
+	offBlock, takeoff, landing, onBlock sql.NullTime
+}
+
+func (r *flightTimeRow) dest() []interface{} {
+	return []interface{}{
+		&r.depTime, &r.enrouteTime,
+		&r.offBlock, &r.takeoff, &r.landing, &r.onBlock,
+	}
+}
+
+// times converts the row and derives block and airborne durations and the
+// off-block delay against the filed departure time
+func (r *flightTimeRow) times() FlightTimes {
+	t := FlightTimes{
+		FiledDepTime:     r.depTime.String,
+		FiledEnrouteTime: r.enrouteTime.String,
+		OffBlock:         nullTime(r.offBlock),
+		Takeoff:          nullTime(r.takeoff),
+		Landing:          nullTime(r.landing),
+		OnBlock:          nullTime(r.onBlock),
+	}
+
+	if t.OffBlock != nil && t.OnBlock != nil && t.OnBlock.After(*t.OffBlock) {
+		t.BlockMinutes = minutesBetween(*t.OffBlock, *t.OnBlock)
+	}
+	if t.Takeoff != nil && t.Landing != nil && t.Landing.After(*t.Takeoff) {
+		t.AirborneMinutes = minutesBetween(*t.Takeoff, *t.Landing)
+	}
+	if t.OffBlock != nil {
+		if scheduled, ok := scheduledTime(t.FiledDepTime, *t.OffBlock); ok {
+			t.DepartureDelayMinutes = minutesBetween(scheduled, *t.OffBlock)
+		}
+	}
+
+	return t
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func minutesBetween(from, to time.Time) *int {
+	minutes := int(to.Sub(from).Round(time.Minute) / time.Minute)
+	return &minutes
+}
+
+// scheduledTime resolves a filed HHMM UTC time to the occurrence closest
+// to ref, since flight plans carry no date
+func scheduledTime(hhmm string, ref time.Time) (time.Time, bool) {
+	parsed, err := time.Parse("1504", hhmm)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	ref = ref.UTC()
+	scheduled := time.Date(ref.Year(), ref.Month(), ref.Day(),
+		parsed.Hour(), parsed.Minute(), 0, 0, time.UTC)
+	switch {
+	case scheduled.Sub(ref) > 12*time.Hour:
+		scheduled = scheduled.AddDate(0, 0, -1)
+	case ref.Sub(scheduled) > 12*time.Hour:
+		scheduled = scheduled.AddDate(0, 0, 1)
+	}
+	return scheduled, true
+}
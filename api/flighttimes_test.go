@@ -0,0 +1,52 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledTime(t *testing.T) {
+	tests := []struct {
+		hhmm string
+		ref  time.Time
+		want time.Time
+	}{
+		{"1430", time.Date(2024, 3, 15, 14, 50, 0, 0, time.UTC), time.Date(2024, 3, 15, 14, 30, 0, 0, time.UTC)},
+		// Filed just before midnight, off block just after
+		{"2350", time.Date(2024, 3, 16, 0, 20, 0, 0, time.UTC), time.Date(2024, 3, 15, 23, 50, 0, 0, time.UTC)},
+		// Filed just after midnight, off block early
+		{"0010", time.Date(2024, 3, 15, 23, 45, 0, 0, time.UTC), time.Date(2024, 3, 16, 0, 10, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, ok := scheduledTime(tt.hhmm, tt.ref)
+		if !ok || !got.Equal(tt.want) {
+			t.Errorf("scheduledTime(%q, %v) = %v, %v; want %v", tt.hhmm, tt.ref, got, ok, tt.want)
+		}
+	}
+
+	if _, ok := scheduledTime("", time.Now()); ok {
+		t.Error("an empty filed time should not resolve")
+	}
+}
+
+func TestFlightTimesDurations(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2024, 3, 15, h, m, 0, 0, time.UTC) }
+
+	row := flightTimeRow{}
+	row.depTime.String, row.depTime.Valid = "1000", true
+	row.offBlock.Time, row.offBlock.Valid = at(10, 12), true
+	row.takeoff.Time, row.takeoff.Valid = at(10, 25), true
+	row.landing.Time, row.landing.Valid = at(11, 40), true
+
+	times := row.times()
+	if times.DepartureDelayMinutes == nil || *times.DepartureDelayMinutes != 12 {
+		t.Errorf("departure delay = %v, want 12", times.DepartureDelayMinutes)
+	}
+	if times.AirborneMinutes == nil || *times.AirborneMinutes != 75 {
+		t.Errorf("airborne minutes = %v, want 75", times.AirborneMinutes)
+	}
+	if times.BlockMinutes != nil || times.OnBlock != nil {
+		t.Errorf("block time should be unknown before on block: %+v", times)
+	}
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/vainnor/vatsim-stats/airports"
+	"github.com/vainnor/vatsim-stats/geo"
+)
+
+// geoJSONType is the media type of GeoJSON responses
+const geoJSONType = "application/geo+json"
+
+// circleSegments is the number of sides of controller range polygons
+const circleSegments = 64
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature. Geometry is null when a position is not
+// known.
+type Feature struct {
+	Type       string      `json:"type"`
+	Geometry   *Geometry   `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry with [lon, lat] coordinates
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func newFeatureCollection() FeatureCollection {
+	return FeatureCollection{Type: "FeatureCollection", Features: make([]Feature, 0)}
+}
+
+func (fc *FeatureCollection) add(geometry *Geometry, properties interface{}) {
+	fc.Features = append(fc.Features, Feature{Type: "Feature", Geometry: geometry, Properties: properties})
+}
+
+func pointGeometry(lat, lon float64) *Geometry {
+	return &Geometry{Type: "Point", Coordinates: [2]float64{lon, lat}}
+}
+
+func circleGeometry(lat, lon, radiusNM float64) *Geometry {
+	return &Geometry{Type: "Polygon", Coordinates: [][][2]float64{geo.Circle(lat, lon, radiusNM, circleSegments)}}
+}
+
+// wantsGeoJSON reports whether a request asks for GeoJSON, with
+// format=geojson or an Accept header naming application/geo+json
+func wantsGeoJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return strings.EqualFold(format, "geojson")
+	}
+	return strings.Contains(r.Header.Get("Accept"), geoJSONType)
+}
+
+func writeGeoJSON(w http.ResponseWriter, fc FeatureCollection) {
+	w.Header().Set("Content-Type", geoJSONType)
+	json.NewEncoder(w).Encode(fc)
+}
+
+// controllerGeometry is the visual range of a controller around its
+// center, or its center if it has no range
+func controllerGeometry(center *LatLon, visualRange int) *Geometry {
+	switch {
+	case center == nil:
+		return nil
+	case visualRange <= 0:
+		return pointGeometry(center.Latitude, center.Longitude)
+	default:
+		return circleGeometry(center.Latitude, center.Longitude, float64(visualRange))
+	}
+}
+
+// controllerCenters locates controllers by callsign, keyed by callsign.
+// See locateControllers.
+func controllerCenters(callsigns []string) (map[string]*LatLon, error) {
+	codes := make([]string, 0, len(callsigns))
+	for _, callsign := range callsigns {
+		codes = append(codes, callsignPrefix(callsign))
+		codes = append(codes, facilityAirports(callsign)...)
+	}
+
+	found, err := airports.LookupMany(codes)
+	if err != nil {
+		return nil, err
+	}
+	return locateControllers(callsigns, found), nil
+}
+
+// locateControllers places a controller at the airport named by its
+// callsign prefix, or else at the middle of the FIR it names, as for
+// EDGG_CTR. Other controllers, such as LON_S_CTR, are placed at the
+// midpoint of the airports of their facility in the registry. Controllers
+// none of these locate are left out.
+func locateControllers(callsigns []string, found map[string]*airports.Airport) map[string]*LatLon {
+	centers := make(map[string]*LatLon, len(callsigns))
+	for _, callsign := range callsigns {
+		prefix := callsignPrefix(callsign)
+		if a, ok := found[prefix]; ok {
+			centers[callsign] = &LatLon{Latitude: a.Latitude, Longitude: a.Longitude}
+			continue
+		}
+		if firIndex != nil {
+			if lat, lon, ok := firIndex.Center(prefix); ok {
+				centers[callsign] = &LatLon{Latitude: lat, Longitude: lon}
+				continue
+			}
+		}
+
+		var points [][2]float64
+		for _, code := range facilityAirports(callsign) {
+			if a, ok := found[code]; ok {
+				points = append(points, [2]float64{a.Longitude, a.Latitude})
+			}
+		}
+		if len(points) > 0 {
+			lat, lon := geo.Midpoint(points)
+			centers[callsign] = &LatLon{Latitude: lat, Longitude: lon}
+		}
+	}
+	return centers
+}
+
+// facilityAirports returns the airports of the facility a controller
+// belongs to
+func facilityAirports(callsign string) []string {
+	return facilityRegistry.Get(facilityRegistry.Classify(callsign, "")).Airports
+}
+
+// callsignPrefix returns the part of a callsign before the first underscore
+func callsignPrefix(callsign string) string {
+	prefix, _, _ := strings.Cut(strings.ToUpper(callsign), "_")
+	return prefix
+}
+
+// flightSearchFeatures maps search results to points
+func flightSearchFeatures(response FlightSearchResponse) FeatureCollection {
+	fc := newFeatureCollection()
+	for _, flight := range response.Flights {
+		fc.add(pointGeometry(flight.Position.Latitude, flight.Position.Longitude), flight)
+	}
+	return fc
+}
+
+// controllerFeatures maps live controllers to their visual range circles
+func controllerFeatures(list ControllerList) FeatureCollection {
+	fc := newFeatureCollection()
+	for _, controller := range list.Controllers {
+		fc.add(controllerGeometry(controller.Center, controller.VisualRange), controller)
+	}
+	return fc
+}
+
+// airportTrafficFeatures maps an airport, its controllers and its flights
+// to features, told apart by the kind property
+func airportTrafficFeatures(traffic AirportTraffic) FeatureCollection {
+	fc := newFeatureCollection()
+
+	var center *LatLon
+	if a := traffic.Airport; a != nil {
+		center = &LatLon{Latitude: a.Latitude, Longitude: a.Longitude}
+		fc.add(pointGeometry(a.Latitude, a.Longitude), struct {
+			Kind string `json:"kind"`
+			*airports.Airport
+		}{"airport", a})
+	}
+
+	for _, controller := range traffic.ActiveControllers {
+		fc.add(controllerGeometry(center, controller.VisualRange), struct {
+			Kind string `json:"kind"`
+			ActiveController
+		}{"controller", controller})
+	}
+
+	for _, group := range []struct {
+		kind    string
+		flights []FlightInfo
+	}{
+		{"arrival", traffic.Traffic.Arrivals},
+		{"departure", traffic.Traffic.Departures},
+	} {
+		for _, flight := range group.flights {
+			var geometry *Geometry
+			if flight.Position != nil {
+				geometry = pointGeometry(flight.Position.Latitude, flight.Position.Longitude)
+			}
+			fc.add(geometry, struct {
+				Kind string `json:"kind"`
+				FlightInfo
+			}{group.kind, flight})
+		}
+	}
+
+	return fc
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vainnor/vatsim-stats/airports"
+	"github.com/vainnor/vatsim-stats/facilities"
+	"github.com/vainnor/vatsim-stats/firs"
+)
+
+func TestWantsGeoJSON(t *testing.T) {
+	tests := []struct {
+		url    string
+		accept string
+		want   bool
+	}{
+		{"/api/flights/search", "", false},
+		{"/api/flights/search", "application/json", false},
+		{"/api/flights/search", "application/geo+json", true},
+		{"/api/flights/search?format=geojson", "", true},
+		{"/api/flights/search?format=json", "application/geo+json", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		if tt.accept != "" {
+			r.Header.Set("Accept", tt.accept)
+		}
+		if got := wantsGeoJSON(r); got != tt.want {
+			t.Errorf("wantsGeoJSON(%s, Accept %q) = %v, want %v", tt.url, tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestControllerFeatures(t *testing.T) {
+	fc := controllerFeatures(ControllerList{Controllers: []LiveController{
+		{Callsign: "EGLL_TWR", VisualRange: 50, Center: &LatLon{Latitude: 51.47, Longitude: -0.45}},
+		{Callsign: "LON_CTR", VisualRange: 300},
+	}})
+
+	w := httptest.NewRecorder()
+	writeGeoJSON(w, fc)
+	if ct := w.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	var decoded struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry *struct {
+				Type        string         `json:"type"`
+				Coordinates [][][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(strings.NewReader(w.Body.String())).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Type != "FeatureCollection" || len(decoded.Features) != 2 {
+		t.Fatalf("unexpected collection %+v", decoded)
+	}
+	tower := decoded.Features[0]
+	if tower.Geometry == nil || tower.Geometry.Type != "Polygon" || len(tower.Geometry.Coordinates[0]) != circleSegments+1 {
+		t.Errorf("tower should be a closed circle polygon: %+v", tower.Geometry)
+	}
+	if tower.Properties["callsign"] != "EGLL_TWR" {
+		t.Errorf("unexpected properties %v", tower.Properties)
+	}
+	if decoded.Features[1].Geometry != nil {
+		t.Error("a controller without a known center should have a null geometry")
+	}
+}
+
+func TestLocateControllers(t *testing.T) {
+	SetFIRs(firs.NewIndex([]firs.Boundary{
+		{ID: "EDGG", Polygons: [][][][2]float64{{{{6, 48}, {12, 48}, {12, 52}, {6, 52}}}}},
+	}))
+	defer SetFIRs(nil)
+	registry, err := facilities.New([]facilities.Facility{
+		{ID: "EGTT", Callsigns: []string{"LON_*"}, Airports: []string{"EGLL", "EGKK"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetFacilities(registry)
+	defer SetFacilities(nil)
+
+	found := map[string]*airports.Airport{
+		"EGLL": {Latitude: 51.4, Longitude: -0.4},
+		"EGKK": {Latitude: 51.2, Longitude: -0.2},
+	}
+	centers := locateControllers([]string{"EGLL_TWR", "EDGG_CTR", "LON_S_CTR", "CZQX_FSS"}, found)
+
+	want := map[string]LatLon{
+		"EGLL_TWR":  {Latitude: 51.4, Longitude: -0.4},
+		"EDGG_CTR":  {Latitude: 50, Longitude: 9},
+		"LON_S_CTR": {Latitude: 51.3, Longitude: -0.3},
+	}
+	for callsign, center := range want {
+		got := centers[callsign]
+		if got == nil || math.Abs(got.Latitude-center.Latitude) > 1e-9 || math.Abs(got.Longitude-center.Longitude) > 1e-9 {
+			t.Errorf("%s center = %+v, want %+v", callsign, got, center)
+		}
+	}
+	if centers["CZQX_FSS"] != nil {
+		t.Errorf("a controller nothing locates should have no center, got %+v", centers["CZQX_FSS"])
+	}
+
+	fc := controllerFeatures(ControllerList{Controllers: []LiveController{
+		{Callsign: "EDGG_CTR", VisualRange: 300, Center: centers["EDGG_CTR"]},
+	}})
+	if fc.Features[0].Geometry == nil || fc.Features[0].Geometry.Type != "Polygon" {
+		t.Errorf("a CTR located by its FIR should have a range circle, got %+v", fc.Features[0].Geometry)
+	}
+}
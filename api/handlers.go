@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,7 +15,9 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/airports"
 	"github.com/vainnor/vatsim-stats/db"
+	"github.com/vainnor/vatsim-stats/geo"
 )
 
 func GetMembershipHandler(w http.ResponseWriter, r *http.Request) {
@@ -29,8 +33,10 @@ func GetMembershipHandler(w http.ResponseWriter, r *http.Request) {
 		typeID = TypeATIS
 	case "pilot":
 		typeID = TypePilot
+	case "observer":
+		typeID = TypeObserver
 	default:
-		http.Error(w, "Invalid connection type. Must be 'atc', 'atis', or 'pilot'", http.StatusBadRequest)
+		http.Error(w, "Invalid connection type. Must be 'atc', 'atis', 'observer', or 'pilot'", http.StatusBadRequest)
 		return
 	}
 
@@ -49,6 +55,140 @@ func GetMembershipHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(MembershipResponse{Items: items})
 }
 
+// GetMembershipSummaryHandler returns a member's aggregated pilot and
+// controller time
+func GetMembershipSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	cid := mux.Vars(r)["cid"]
+
+	summary, err := getMemberSummary(cid)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	if summary.Pilot == nil && summary.Controller == nil {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No data found"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+func getMemberSummary(cid string) (*MemberSummary, error) {
+	summary := &MemberSummary{VatsimID: cid}
+
+	var pilotSeconds [6]int64
+	var flights int
+	var distanceNM float64
+	var updated time.Time
+	err := db.DB.QueryRow(`
+		SELECT
+			total_seconds, total_flights,
+			student_seconds, ppl_seconds, instrument_seconds,
+			cpl_seconds, atpl_seconds, total_distance_nm, last_updated
+		FROM pilot_total_stats
+		WHERE vatsim_id = $1
+	`, cid).Scan(
+		&pilotSeconds[0], &flights,
+		&pilotSeconds[1], &pilotSeconds[2], &pilotSeconds[3],
+		&pilotSeconds[4], &pilotSeconds[5], &distanceNM, &updated,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		summary.Pilot = newPilotTotals(pilotSeconds, flights, distanceNM, updated)
+	}
+
+	var controllerSeconds [8]int64
+	var sessions int
+	err = db.DB.QueryRow(`
+		SELECT
+			total_seconds, sessions,
+			del_seconds, gnd_seconds, twr_seconds, app_seconds,
+			ctr_seconds, fss_seconds, other_seconds, last_updated
+		FROM controller_total_stats
+		WHERE vatsim_id = $1
+	`, cid).Scan(
+		&controllerSeconds[0], &sessions,
+		&controllerSeconds[1], &controllerSeconds[2], &controllerSeconds[3], &controllerSeconds[4],
+		&controllerSeconds[5], &controllerSeconds[6], &controllerSeconds[7], &updated,
+	)
+	if err == sql.ErrNoRows {
+		return summary, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	controller := newControllerTotals(controllerSeconds, sessions, updated)
+
+	rows, err := db.DB.Query(`
+		SELECT s.rating, COALESCE(r.short_name, ''), s.total_seconds, s.sessions
+		FROM controller_rating_stats s
+		LEFT JOIN ratings r ON r.id = s.rating
+		WHERE s.vatsim_id = $1
+		ORDER BY s.rating
+	`, cid)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rating RatingHours
+		var seconds int64
+		if err := rows.Scan(&rating.Rating, &rating.ShortName, &seconds, &rating.Sessions); err != nil {
+			return nil, err
+		}
+		rating.Hours = secondsToHours(seconds)
+		controller.Ratings = append(controller.Ratings, rating)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	summary.Controller = controller
+	return summary, nil
+}
+
+// newPilotTotals converts pilot_total_stats seconds, the total followed by
+// the student, PPL, instrument, CPL and ATPL times, to hours
+func newPilotTotals(seconds [6]int64, flights int, distanceNM float64, updated time.Time) *PilotTotals {
+	return &PilotTotals{
+		TotalHours:      secondsToHours(seconds[0]),
+		TotalFlights:    flights,
+		StudentHours:    secondsToHours(seconds[1]),
+		PPLHours:        secondsToHours(seconds[2]),
+		InstrumentHours: secondsToHours(seconds[3]),
+		CPLHours:        secondsToHours(seconds[4]),
+		ATPLHours:       secondsToHours(seconds[5]),
+		TotalDistanceNM: roundDistance(distanceNM),
+		LastUpdated:     updated,
+	}
+}
+
+// newControllerTotals converts controller_total_stats seconds, the total
+// followed by the DEL, GND, TWR, APP, CTR, FSS and other times, to hours.
+// Ratings are added by the caller.
+func newControllerTotals(seconds [8]int64, sessions int, updated time.Time) *ControllerTotals {
+	return &ControllerTotals{
+		TotalHours: secondsToHours(seconds[0]),
+		Sessions:   sessions,
+		Positions: PositionHours{
+			DEL:   secondsToHours(seconds[1]),
+			GND:   secondsToHours(seconds[2]),
+			TWR:   secondsToHours(seconds[3]),
+			APP:   secondsToHours(seconds[4]),
+			CTR:   secondsToHours(seconds[5]),
+			FSS:   secondsToHours(seconds[6]),
+			Other: secondsToHours(seconds[7]),
+		},
+		Ratings:     []RatingHours{},
+		LastUpdated: updated,
+	}
+}
+
 func getMembershipData(cid string, typeID ConnectionType) ([]interface{}, error) {
 	var items []interface{}
 
@@ -106,6 +246,10 @@ func getMembershipData(cid string, typeID ConnectionType) ([]interface{}, error)
 			}
 			stats.ConnectionID = conn
 			items = append(items, stats)
+
+		case TypeObserver:
+			// Observers keep no statistics beyond the connection
+			items = append(items, conn)
 		}
 	}
 
@@ -118,33 +262,49 @@ func getMembershipData(cid string, typeID ConnectionType) ([]interface{}, error)
 
 func getATCStats(connID int64) (*ATCStats, error) {
 	stats := &ATCStats{}
+	var onlineSeconds int64
 	err := db.DB.QueryRow(`
 		SELECT 
 			aircraft_tracked, aircraft_seen, flights_amended,
 			handoffs_initiated, handoffs_received, handoffs_refused,
 			squawks_assigned, cruise_alts_modified, temp_alts_modified,
-			scratchpad_mods
+			scratchpad_mods, online_seconds
 		FROM atc_stats
 		WHERE connection_id = $1
 	`, connID).Scan(
 		&stats.AircraftTracked, &stats.AircraftSeen, &stats.FlightsAmended,
 		&stats.HandoffsInitiated, &stats.HandoffsReceived, &stats.HandoffsRefused,
 		&stats.SquawksAssigned, &stats.CruiseAltsModified, &stats.TempAltsModified,
-		&stats.ScratchpadMods,
+		&stats.ScratchpadMods, &onlineSeconds,
 	)
+	if err == sql.ErrNoRows {
+		// The session is still open, stats are recorded when it closes
+		return stats, nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	stats.OnlineHours = secondsToHours(onlineSeconds)
 	return stats, nil
 }
 
+// secondsToHours converts a stored duration to hours rounded to two decimals
+func secondsToHours(seconds int64) float64 {
+	return math.Round(float64(seconds)/36) / 100
+}
+
+// roundDistance rounds a distance in nautical miles to one decimal
+func roundDistance(nm float64) float64 {
+	return math.Round(nm*10) / 10
+}
+
 func getPilotStats(connID int64) (*PilotStats, error) {
 	stats := &PilotStats{}
 
 	// Get the connection details
 	var conn ConnectionID
 	err := db.DB.QueryRow(`
-		SELECT id, vatsim_id, rating, callsign, start_time, end_time, server
+		SELECT id, vatsim_id, rating, callsign, start_time, end_time, server, distance_nm
 		FROM connections WHERE id = $1
 	`, connID).Scan(
 		&conn.ID,
@@ -154,24 +314,27 @@ func getPilotStats(connID int64) (*PilotStats, error) {
 		&conn.Start,
 		&conn.End,
 		&conn.Server,
+		&stats.DistanceNM,
 	)
 	if err != nil {
 		return nil, err
 	}
 	stats.ConnectionID = conn
+	stats.DistanceNM = roundDistance(stats.DistanceNM)
 
-	// Get the total stats for this pilot
+	// Get the total stats for this pilot, stored in seconds
+	var totals [6]int64
 	err = db.DB.QueryRow(`
 		SELECT 
-			total_hours, total_flights,
-			student_hours, ppl_hours, instrument_hours,
-			cpl_hours, atpl_hours
+			total_seconds, total_flights,
+			student_seconds, ppl_seconds, instrument_seconds,
+			cpl_seconds, atpl_seconds, total_distance_nm
 		FROM pilot_total_stats
 		WHERE vatsim_id = $1
 	`, conn.VatsimID).Scan(
-		&stats.TotalHours, &stats.TotalFlights,
-		&stats.StudentHours, &stats.PPLHours, &stats.InstrumentHours,
-		&stats.CPLHours, &stats.ATPLHours,
+		&totals[0], &stats.TotalFlights,
+		&totals[1], &totals[2], &totals[3],
+		&totals[4], &totals[5], &stats.TotalDistanceNM,
 	)
 	if err == sql.ErrNoRows {
 		// If no stats exist yet, return zeros
@@ -180,6 +343,13 @@ func getPilotStats(connID int64) (*PilotStats, error) {
 	if err != nil {
 		return nil, err
 	}
+	stats.TotalHours = secondsToHours(totals[0])
+	stats.StudentHours = secondsToHours(totals[1])
+	stats.PPLHours = secondsToHours(totals[2])
+	stats.InstrumentHours = secondsToHours(totals[3])
+	stats.CPLHours = secondsToHours(totals[4])
+	stats.ATPLHours = secondsToHours(totals[5])
+	stats.TotalDistanceNM = roundDistance(stats.TotalDistanceNM)
 
 	// Check if there's an active session
 	var startTime time.Time
@@ -212,14 +382,18 @@ func getPilotStats(connID int64) (*PilotStats, error) {
 }
 
 func getATISStats(connID int64) (*ATISStats, error) {
-	stats := &ATISStats{}
+	stats := &ATISStats{Runways: make([]string, 0)}
 	err := db.DB.QueryRow(`
-		SELECT updates, frequency, letter
+		SELECT updates, COALESCE(frequency, ''), COALESCE(letter, ''), COALESCE(runways, '{}')
 		FROM atis_stats
 		WHERE connection_id = $1
 	`, connID).Scan(
-		&stats.Updates, &stats.Frequency, &stats.Letter,
+		&stats.Updates, &stats.Frequency, &stats.Letter, pq.Array(&stats.Runways),
 	)
+	if err == sql.ErrNoRows {
+		// The session is still open, stats are recorded when it closes
+		return stats, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -341,21 +515,6 @@ func GetAirportTraffic(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	icao := strings.ToUpper(vars["icao"])
 
-	// Get active controllers at this airport
-	rows, err := db.DB.Query(`
-		SELECT 
-			c.callsign, c.frequency, c.cid, c.rating, p.name
-		FROM pilots p
-		JOIN controllers c ON c.callsign LIKE $1
-		WHERE c.last_updated > NOW() - INTERVAL '5 minutes'
-		ORDER BY c.callsign
-	`, icao+"_%")
-	if err != nil {
-		http.Error(w, "Database error", http.StatusInternalServerError)
-		return
-	}
-	defer rows.Close()
-
 	traffic := AirportTraffic{
 		ICAO:      icao,
 		Timestamp: time.Now(),
@@ -365,19 +524,14 @@ func GetAirportTraffic(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	// Process controllers
-	for rows.Next() {
-		var ctrl ActiveController
-		err := rows.Scan(
-			&ctrl.Position,
-			&ctrl.Frequency,
-			&ctrl.Controller.CID,
-			&ctrl.Controller.Rating,
-			&ctrl.Controller.Name,
-		)
-		if err != nil {
-			continue
-		}
+	// Get active controllers of the facilities serving this airport, as
+	// last reported
+	controllers, err := queryAirportControllers(icao)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	for _, ctrl := range controllers {
 		traffic.ActiveControllers = append(traffic.ActiveControllers, ctrl)
 
 		// Check if this is an ATIS position
@@ -390,60 +544,93 @@ func GetAirportTraffic(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Get arrivals
-	rows, err = db.DB.Query(`
-		SELECT 
-			p.callsign, fp.aircraft_short, p.altitude, 
-			p.groundspeed, fp.departure, fp.arrival,
-			p.logon_time
-		FROM pilots p
-		JOIN flight_plans fp ON fp.pilot_id = p.id
-		WHERE fp.arrival = $1
-		AND p.last_updated > NOW() - INTERVAL '5 minutes'
-	`, icao)
+	// Get arrivals and departures from the open flights, with the live
+	// position of each
+	flightQuery := `
+		SELECT
+			c.callsign, COALESCE(fp.aircraft_short, ''), p.altitude,
+			p.groundspeed, COALESCE(f.departure, ''), COALESCE(f.arrival, ''),
+			c.start_time, p.latitude, p.longitude, p.heading, ` + flightTimeColumns + `
+		FROM flights f
+		JOIN connections c ON c.id = f.connection_id
+		JOIN LATERAL (
+			SELECT p.id, p.altitude, p.groundspeed, p.latitude, p.longitude, p.heading, p.snapshot_time
+			FROM live_positions lp
+			JOIN pilots p ON p.id = lp.pilot_id AND p.snapshot_time = lp.snapshot_time
+			WHERE p.cid = c.vatsim_id::integer AND p.callsign = c.callsign
+			AND lp.snapshot_time > NOW() - INTERVAL '5 minutes'
+			ORDER BY lp.snapshot_time DESC
+			LIMIT 1
+		) p ON true
+		LEFT JOIN flight_plans fp ON fp.pilot_id = p.id AND fp.snapshot_time = p.snapshot_time
+		WHERE NOT c.closed
+		AND c.end_time > NOW() - INTERVAL '5 minutes'
+	`
+
+	traffic.Traffic.Arrivals, err = queryAirportFlights(flightQuery+" AND f.arrival = $1", icao)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var flight FlightInfo
-		err := rows.Scan(
-			&flight.Callsign,
-			&flight.Aircraft,
-			&flight.Altitude,
-			&flight.Groundspeed,
-			&flight.Origin,
-			&flight.Destination,
-			&flight.Time,
-		)
-		if err != nil {
-			continue
-		}
-		traffic.Traffic.Arrivals = append(traffic.Traffic.Arrivals, flight)
+	traffic.Traffic.Departures, err = queryAirportFlights(flightQuery+" AND f.departure = $1", icao)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
 	}
 
-	// Get departures
-	rows, err = db.DB.Query(`
-		SELECT 
-			p.callsign, fp.aircraft_short, p.altitude, 
-			p.groundspeed, fp.departure, fp.arrival,
-			p.logon_time
-		FROM pilots p
-		JOIN flight_plans fp ON fp.pilot_id = p.id
-		WHERE fp.departure = $1
-		AND p.last_updated > NOW() - INTERVAL '5 minutes'
-	`, icao)
+	// Count actual movements in the last hour
+	var departuresLastHour, arrivalsLastHour int
+	err = db.DB.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM flights WHERE departure = $1 AND takeoff_time > NOW() - INTERVAL '1 hour'),
+			(SELECT COUNT(*) FROM flights WHERE arrival = $1 AND landing_time > NOW() - INTERVAL '1 hour')
+	`, icao).Scan(&departuresLastHour, &arrivalsLastHour)
 	if err != nil {
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+
+	// Add the airport's reference data, if it has been imported
+	airport, err := airports.Lookup(icao)
+	if err != nil && err != airports.ErrNotFound {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	traffic.Airport = airport
+
+	// Calculate statistics
+	traffic.Statistics = AirportStatistics{
+		HourlyMovements:    departuresLastHour + arrivalsLastHour,
+		ArrivalCount:       len(traffic.Traffic.Arrivals),
+		DepartureCount:     len(traffic.Traffic.Departures),
+		DeparturesLastHour: departuresLastHour,
+		ArrivalsLastHour:   arrivalsLastHour,
+	}
+
+	if wantsGeoJSON(r) {
+		writeGeoJSON(w, airportTrafficFeatures(traffic))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(traffic)
+}
+
+// queryAirportFlights runs an airport traffic flight query
+func queryAirportFlights(query string, args ...interface{}) ([]FlightInfo, error) {
+	rows, err := db.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
+	flights := make([]FlightInfo, 0)
 	for rows.Next() {
 		var flight FlightInfo
-		err := rows.Scan(
+		var position Position
+		var times flightTimeRow
+		dest := append([]interface{}{
 			&flight.Callsign,
 			&flight.Aircraft,
 			&flight.Altitude,
@@ -451,25 +638,23 @@ func GetAirportTraffic(w http.ResponseWriter, r *http.Request) {
 			&flight.Origin,
 			&flight.Destination,
 			&flight.Time,
-		)
-		if err != nil {
+			&position.Latitude,
+			&position.Longitude,
+			&position.Heading,
+		}, times.dest()...)
+		if err := rows.Scan(dest...); err != nil {
 			continue
 		}
-		traffic.Traffic.Departures = append(traffic.Traffic.Departures, flight)
+		flight.Position = &position
+		flight.Times = times.times()
+		flights = append(flights, flight)
 	}
 
-	// Calculate statistics
-	traffic.Statistics = AirportStatistics{
-		HourlyMovements: len(traffic.Traffic.Arrivals) + len(traffic.Traffic.Departures),
-		ArrivalCount:    len(traffic.Traffic.Arrivals),
-		DepartureCount:  len(traffic.Traffic.Departures),
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(traffic)
+	return flights, rows.Err()
 }
 
-// SearchFlights allows searching for active flights based on various criteria
+// SearchFlights allows searching for active flights based on various
+// criteria, including an area given as a bounding box or a center and radius
 func SearchFlights(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	callsign := query.Get("callsign")
@@ -477,16 +662,24 @@ func SearchFlights(w http.ResponseWriter, r *http.Request) {
 	origin := query.Get("origin")
 	destination := query.Get("destination")
 
-	// Build the SQL query based on provided parameters
+	area, err := parseSearchArea(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Build the SQL query based on provided parameters. Live positions hold
+	// the latest position of every connected pilot.
 	sqlQuery := `
 		SELECT 
-			p.callsign, fp.aircraft_short, fp.departure, 
-			fp.arrival, p.altitude, p.groundspeed,
+			p.callsign, COALESCE(fp.aircraft_short, ''), COALESCE(fp.departure, ''),
+			COALESCE(fp.arrival, ''), p.altitude, p.groundspeed,
 			p.latitude, p.longitude, p.heading,
-			fp.route, p.logon_time
-		FROM pilots p
-		JOIN flight_plans fp ON fp.pilot_id = p.id
-		WHERE p.last_updated > NOW() - INTERVAL '5 minutes'
+			COALESCE(fp.route, ''), p.logon_time
+		FROM live_positions lp
+		JOIN pilots p ON p.id = lp.pilot_id AND p.snapshot_time = lp.snapshot_time
+		LEFT JOIN flight_plans fp ON fp.pilot_id = p.id AND fp.snapshot_time = p.snapshot_time
+		WHERE lp.snapshot_time > NOW() - INTERVAL '5 minutes'
 	`
 	params := make([]interface{}, 0)
 	paramCount := 1
@@ -509,6 +702,36 @@ func SearchFlights(w http.ResponseWriter, r *http.Request) {
 	if destination != "" {
 		sqlQuery += fmt.Sprintf(" AND fp.arrival = $%d", paramCount)
 		params = append(params, strings.ToUpper(destination))
+		paramCount++
+	}
+
+	// Restrict to the area's boxes using the spatial index
+	if area.box != nil {
+		var boxes []string
+		for _, box := range area.box.Split() {
+			boxes = append(boxes, fmt.Sprintf("lp.position <@ box(point($%d, $%d), point($%d, $%d))",
+				paramCount, paramCount+1, paramCount+2, paramCount+3))
+			params = append(params, box.West, box.South, box.East, box.North)
+			paramCount += 4
+		}
+		sqlQuery += " AND (" + strings.Join(boxes, " OR ") + ")"
+	}
+
+	for _, bound := range []struct {
+		column string
+		op     string
+		value  *int
+	}{
+		{"lp.altitude", ">=", area.minAltitude},
+		{"lp.altitude", "<=", area.maxAltitude},
+		{"lp.groundspeed", ">=", area.minGroundspeed},
+		{"lp.groundspeed", "<=", area.maxGroundspeed},
+	} {
+		if bound.value != nil {
+			sqlQuery += fmt.Sprintf(" AND %s %s $%d", bound.column, bound.op, paramCount)
+			params = append(params, *bound.value)
+			paramCount++
+		}
 	}
 
 	rows, err := db.DB.Query(sqlQuery, params...)
@@ -540,52 +763,335 @@ func SearchFlights(w http.ResponseWriter, r *http.Request) {
 		if err != nil {
 			continue
 		}
+
+		// The box only approximates a radius search
+		if area.radius > 0 {
+			distance := geo.DistanceNM(area.lat, area.lon, flight.Position.Latitude, flight.Position.Longitude)
+			if distance > area.radius {
+				continue
+			}
+			distance = math.Round(distance*10) / 10
+			flight.DistanceNM = &distance
+		}
+
 		response.Flights = append(response.Flights, flight)
 	}
 
+	if area.radius > 0 {
+		sort.Slice(response.Flights, func(i, j int) bool {
+			return *response.Flights[i].DistanceNM < *response.Flights[j].DistanceNM
+		})
+	}
+
 	response.Total = len(response.Flights)
 
+	if wantsGeoJSON(r) {
+		writeGeoJSON(w, flightSearchFeatures(response))
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetControllersHandler returns a handler listing the controllers in the
+// collector's latest snapshot
+func GetControllersHandler(collector Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := collector.GetCurrentData()
+		if err != nil {
+			http.Error(w, "No snapshot collected yet", http.StatusServiceUnavailable)
+			return
+		}
+
+		callsigns := make([]string, 0, len(data.Controllers))
+		for _, controller := range data.Controllers {
+			callsigns = append(callsigns, controller.Callsign)
+		}
+		centers, err := controllerCenters(callsigns)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		list := ControllerList{
+			Timestamp:   data.General.UpdateTimestamp,
+			Controllers: make([]LiveController, 0, len(data.Controllers)),
+		}
+		for _, controller := range data.Controllers {
+			list.Controllers = append(list.Controllers, LiveController{
+				Callsign:    controller.Callsign,
+				CID:         controller.CID,
+				Name:        controller.Name,
+				Frequency:   controller.Frequency,
+				Facility:    controller.Facility,
+				Rating:      controller.Rating,
+				VisualRange: controller.VisualRange,
+				LogonTime:   controller.LogonTime,
+				Center:      centers[controller.Callsign],
+			})
+		}
+		list.Total = len(list.Controllers)
+
+		if wantsGeoJSON(r) {
+			writeGeoJSON(w, controllerFeatures(list))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	}
+}
+
+// searchArea is the geographic part of a flight search
+type searchArea struct {
+	box *geo.Box
+	// Center and radius in nautical miles of a radius search
+	lat, lon, radius float64
+	// Altitude and groundspeed bands, nil when open
+	minAltitude, maxAltitude       *int
+	minGroundspeed, maxGroundspeed *int
+}
+
+// parseSearchArea reads the bbox=west,south,east,north or lat, lon and
+// radius parameters and the altitude and groundspeed bands
+func parseSearchArea(query url.Values) (searchArea, error) {
+	var area searchArea
+
+	if bbox := query.Get("bbox"); bbox != "" {
+		parts := strings.Split(bbox, ",")
+		if len(parts) != 4 {
+			return area, fmt.Errorf("bbox must be west,south,east,north")
+		}
+		var v [4]float64
+		for i, part := range parts {
+			f, err := parseFinite(strings.TrimSpace(part))
+			if err != nil {
+				return area, fmt.Errorf("invalid bbox coordinate %q", part)
+			}
+			v[i] = f
+		}
+		box := geo.Box{West: v[0], South: v[1], East: v[2], North: v[3]}
+		if !box.Valid() {
+			return area, fmt.Errorf("bbox is out of range")
+		}
+		area.box = &box
+	}
+
+	if query.Get("lat") != "" || query.Get("lon") != "" || query.Get("radius") != "" {
+		if area.box != nil {
+			return area, fmt.Errorf("use either bbox or lat, lon and radius")
+		}
+
+		var err error
+		if area.lat, err = parseFinite(query.Get("lat")); err != nil || area.lat < -90 || area.lat > 90 {
+			return area, fmt.Errorf("lat must be between -90 and 90")
+		}
+		if area.lon, err = parseFinite(query.Get("lon")); err != nil || area.lon < -180 || area.lon > 180 {
+			return area, fmt.Errorf("lon must be between -180 and 180")
+		}
+		if area.radius, err = parseFinite(query.Get("radius")); err != nil || area.radius <= 0 {
+			return area, fmt.Errorf("radius must be a positive number of nautical miles")
+		}
+
+		box := geo.RadiusBox(area.lat, area.lon, area.radius)
+		area.box = &box
+	}
+
+	for _, band := range []struct {
+		name  string
+		value **int
+	}{
+		{"min_altitude", &area.minAltitude},
+		{"max_altitude", &area.maxAltitude},
+		{"min_groundspeed", &area.minGroundspeed},
+		{"max_groundspeed", &area.maxGroundspeed},
+	} {
+		if v := query.Get(band.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return area, fmt.Errorf("%s must be an integer", band.name)
+			}
+			*band.value = &n
+		}
+	}
+
+	return area, nil
+}
+
+// parseFinite parses a number, rejecting NaN and infinities that would
+// slip past range checks
+func parseFinite(value string) (float64, error) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("%q is not a finite number", value)
+	}
+	return f, nil
+}
+
+// pilotConnectionQuery selects a pilot session and whether it has closed
+const pilotConnectionQuery = `
+	SELECT id, vatsim_id, type, rating, callsign, start_time, end_time, server, closed
+	FROM connections
+`
+
+func scanPilotConnection(row *sql.Row) (ConnectionID, bool, error) {
+	var conn ConnectionID
+	var closed bool
+	err := row.Scan(
+		&conn.ID,
+		&conn.VatsimID,
+		&conn.Type,
+		&conn.Rating,
+		&conn.Callsign,
+		&conn.Start,
+		&conn.End,
+		&conn.Server,
+		&closed,
+	)
+	return conn, closed, err
+}
+
+// latestPilotConnection returns a pilot's latest session under a callsign
+func latestPilotConnection(cid, callsign string) (ConnectionID, bool, error) {
+	return scanPilotConnection(db.DB.QueryRow(pilotConnectionQuery+`
+		WHERE vatsim_id = $1 AND callsign = $2 AND type = $3
+		ORDER BY start_time DESC
+		LIMIT 1
+	`, cid, callsign, TypePilot))
+}
+
+// pilotConnection returns a pilot session by its connection id
+func pilotConnection(id int64) (ConnectionID, bool, error) {
+	return scanPilotConnection(db.DB.QueryRow(pilotConnectionQuery+`
+		WHERE id = $1 AND type = $2
+	`, id, TypePilot))
+}
+
+// GetFlightPhases returns the detected phases of a pilot's latest session
+// under a callsign
+func GetFlightPhases(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cid := vars["cid"]
+	callsign := strings.ToUpper(vars["callsign"])
+
+	var flight FlightPhases
+	var closed bool
+	var err error
+	flight.ConnectionID, closed, err = latestPilotConnection(cid, callsign)
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No flight found"})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT phase, started_at, latitude, longitude, altitude, groundspeed
+		FROM flight_phases
+		WHERE connection_id = $1
+		ORDER BY started_at
+	`, flight.ConnectionID.ID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	flight.Phases = make([]PhaseInterval, 0)
+	for rows.Next() {
+		var phase PhaseInterval
+		err := rows.Scan(
+			&phase.Phase,
+			&phase.StartedAt,
+			&phase.Latitude,
+			&phase.Longitude,
+			&phase.Altitude,
+			&phase.Groundspeed,
+		)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
+		// Each phase ends where the next one starts
+		if n := len(flight.Phases); n > 0 {
+			ended := phase.StartedAt
+			flight.Phases[n-1].EndedAt = &ended
+		}
+		flight.Phases = append(flight.Phases, phase)
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var times flightTimeRow
+	err = db.DB.QueryRow(`
+		SELECT `+flightTimeColumns+`
+		FROM flights f
+		WHERE f.connection_id = $1
+	`, flight.ConnectionID.ID).Scan(times.dest()...)
+	if err != nil && err != sql.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err == nil {
+		t := times.times()
+		flight.Times = &t
+	}
+
+	if n := len(flight.Phases); n > 0 {
+		flight.CurrentPhase = flight.Phases[n-1].Phase
+		if closed {
+			ended := flight.ConnectionID.End
+			flight.Phases[n-1].EndedAt = &ended
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flight)
+}
+
 // GetNetworkStatisticsHandler returns a handler that uses the collector's data
 func GetNetworkStatisticsHandler(collector Collector) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		grouping, err := requestGrouping(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		stats := collector.GetStats()
 		data, err := collector.GetCurrentData()
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error getting VATSIM data: %v", err), http.StatusInternalServerError)
+			http.Error(w, fmt.Sprintf("Error getting VATSIM data: %v", err), http.StatusServiceUnavailable)
 			return
 		}
 
+		now := time.Now()
 		networkStats := NetworkStatistics{
-			Timestamp: time.Now(),
+			Timestamp:    now,
+			SnapshotTime: data.General.UpdateTimestamp,
+			SnapshotAge:  now.Sub(data.General.UpdateTimestamp).Seconds(),
 			Global: GlobalStats{
-				TotalClients:   stats.ActivePilots + stats.ActiveATCs + stats.ActiveATIS,
+				TotalClients:   stats.ActivePilots + stats.ActiveATCs + stats.ActiveATIS + stats.ActiveObservers,
 				TotalPilots:    stats.ActivePilots,
 				TotalATCs:      stats.ActiveATCs,
-				TotalObservers: stats.ActiveATIS,
+				TotalATIS:      stats.ActiveATIS,
+				TotalObservers: stats.ActiveObservers,
 				ActivePilots:   stats.ActivePilots,
 			},
 		}
 
-		// Count users per server
-		serverCounts := make(map[string]int)
-		for _, pilot := range data.Pilots {
-			serverCounts[pilot.Server]++
-		}
-		for _, controller := range data.Controllers {
-			serverCounts[controller.Server]++
-		}
-
-		// Convert server counts to stats
-		for server, count := range serverCounts {
-			networkStats.ServerStats = append(networkStats.ServerStats, ServerStats{
-				Name:           server,
-				ConnectedUsers: count,
-			})
-		}
+		// Count users per server, with the server metadata
+		networkStats.ServerStats = serverStats(data, collector.GetServers())
 
 		// Count aircraft types
 		aircraftCounts := make(map[string]int)
@@ -618,7 +1124,7 @@ func GetNetworkStatisticsHandler(collector Collector) http.HandlerFunc {
 			pilotRatings[pilot.PilotRating]++
 		}
 		for _, controller := range data.Controllers {
-			if len(controller.TextAtis) == 0 {
+			if ControllerType(controller.Callsign, controller.Facility) == TypeATC {
 				atcRatings[controller.Rating]++
 			}
 		}
@@ -639,6 +1145,14 @@ func GetNetworkStatisticsHandler(collector Collector) http.HandlerFunc {
 			})
 		}
 
+		// Count pilots, controllers and airport movements per region
+		networkStats.RegionGrouping = grouping.Name()
+		networkStats.RegionStats, err = countRegions(data, grouping)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(networkStats)
 	}
@@ -784,6 +1298,15 @@ func GetPopularRoutes(w http.ResponseWriter, r *http.Request) {
 		response.Routes = append(response.Routes, route)
 	}
 
+	routes := make([]*RouteStatistics, 0, len(response.Routes))
+	for i := range response.Routes {
+		routes = append(routes, &response.Routes[i])
+	}
+	if err := fillRouteDistances(routes...); err != nil {
+		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	response.Total = len(response.Routes)
 
 	w.Header().Set("Content-Type", "application/json")
@@ -847,8 +1370,8 @@ func GetRouteStats(w http.ResponseWriter, r *http.Request) {
 				SELECT COUNT(DISTINCT p2.cid)
 				FROM pilots p2
 				JOIN flight_plans fp2 ON fp2.pilot_id = p2.id
-				WHERE fp2.departure = rs.origin 
-				AND fp2.arrival = rs.destination
+				WHERE fp2.departure = $1
+				AND fp2.arrival = $2
 				AND p2.last_updated > NOW() - INTERVAL '5 minutes'
 			) as active_flights
 		FROM route_data rd
@@ -861,6 +1384,9 @@ func GetRouteStats(w http.ResponseWriter, r *http.Request) {
 		&route.ActiveFlights,
 	)
 
+	if err == nil {
+		err = fillRouteDistances(&route)
+	}
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Return empty stats instead of 404
@@ -914,66 +1440,53 @@ func GetRouteStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(route)
 }
 
-// GetFacilityStats returns statistics for a specific ATC facility
+// GetFacilityStats returns statistics for a specific ATC facility, as
+// defined in the facility registry
 func GetFacilityStats(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	facility := strings.ToUpper(vars["facility"])
+	facility := facilityRegistry.Get(vars["facility"])
+	airportCodes := facility.Airports
+	if airportCodes == nil {
+		airportCodes = make([]string, 0)
+	}
 
 	stats := FacilityStatistics{
-		Facility:    facility,
+		Facility:    facility.ID,
+		Name:        facility.Name,
+		Airports:    airportCodes,
 		Timestamp:   time.Now(),
 		Controllers: make([]ControllerInfo, 0),
 	}
 
-	// Get coverage statistics
-	err := db.DB.QueryRow(`
-		WITH coverage_data AS (
-			SELECT 
-				COUNT(DISTINCT c.id) * 15 as total_minutes,
-				COUNT(DISTINCT CASE WHEN c.last_updated > NOW() - INTERVAL '24 hours' THEN c.id END) * 15 as last_day_minutes,
-				COUNT(DISTINCT CASE WHEN c.last_updated > NOW() - INTERVAL '7 days' THEN c.id END) * 15 as last_week_minutes,
-				COUNT(DISTINCT CASE WHEN c.last_updated > NOW() - INTERVAL '30 days' THEN c.id END) * 15 as last_month_minutes
-			FROM controllers c
-			WHERE c.callsign LIKE $1
-			AND c.last_updated > NOW() - INTERVAL '30 days'
-		)
-		SELECT 
-			total_minutes / 60,
-			last_day_minutes / 60,
-			last_week_minutes / 60,
-			last_month_minutes / 60,
-			(last_day_minutes::float / (24 * 60)) * 100
-		FROM coverage_data
-	`, facility+"_%").Scan(
-		&stats.Coverage.TotalHours,
-		&stats.Coverage.LastDay,
-		&stats.Coverage.LastWeek,
-		&stats.Coverage.LastMonth,
-		&stats.Coverage.CoveragePercent,
-	)
-	if err != nil && err != sql.ErrNoRows {
+	// Get coverage statistics from the merged sessions of all positions
+	now := stats.Timestamp
+	sessions, err := queryFacilitySessions(facility.ID, now.Add(-30*24*time.Hour), now)
+	if err != nil {
 		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 		return
 	}
-
-	// Calculate average per day
-	if stats.Coverage.LastMonth > 0 {
-		stats.Coverage.AveragePerDay = float64(stats.Coverage.LastMonth) / 30.0
-	}
+	merged := mergeIntervals(allIntervals(sessions))
+	lastDay := coveredDuration(merged, now.Add(-24*time.Hour), now)
+	stats.Coverage.LastDay = durationHours(lastDay)
+	stats.Coverage.LastWeek = durationHours(coveredDuration(merged, now.Add(-7*24*time.Hour), now))
+	stats.Coverage.LastMonth = durationHours(coveredDuration(merged, now.Add(-30*24*time.Hour), now))
+	stats.Coverage.TotalHours = stats.Coverage.LastMonth
+	stats.Coverage.AveragePerDay = math.Round(stats.Coverage.LastMonth/30*100) / 100
+	stats.Coverage.CoveragePercent = roundPercent(float64(lastDay) / float64(24*time.Hour) * 100)
 
 	// Get traffic statistics
 	err = db.DB.QueryRow(`
 		WITH traffic_data AS (
 			SELECT 
 				COUNT(DISTINCT fp.id) as total_flights,
-				COUNT(DISTINCT CASE WHEN fp.arrival = $1 THEN fp.id END) as arrivals,
-				COUNT(DISTINCT CASE WHEN fp.departure = $1 THEN fp.id END) as departures,
+				COUNT(DISTINCT CASE WHEN fp.arrival = ANY($1) THEN fp.id END) as arrivals,
+				COUNT(DISTINCT CASE WHEN fp.departure = ANY($1) THEN fp.id END) as departures,
 				COUNT(DISTINCT CASE WHEN p.last_updated > NOW() - INTERVAL '24 hours' THEN fp.id END) as last_day,
 				COUNT(DISTINCT CASE WHEN p.last_updated > NOW() - INTERVAL '7 days' THEN fp.id END) as last_week,
 				COUNT(DISTINCT CASE WHEN p.last_updated > NOW() - INTERVAL '30 days' THEN fp.id END) as last_month
 			FROM pilots p
 			JOIN flight_plans fp ON fp.pilot_id = p.id
-			WHERE (fp.arrival = $1 OR fp.departure = $1)
+			WHERE (fp.arrival = ANY($1) OR fp.departure = ANY($1))
 			AND p.last_updated > NOW() - INTERVAL '30 days'
 		)
 		SELECT 
@@ -984,7 +1497,7 @@ func GetFacilityStats(w http.ResponseWriter, r *http.Request) {
 			last_week,
 			last_month
 		FROM traffic_data
-	`, facility).Scan(
+	`, pq.Array(airportCodes)).Scan(
 		&stats.Traffic.TotalFlights,
 		&stats.Traffic.ArrivingFlights,
 		&stats.Traffic.DepartingFlights,
@@ -997,68 +1510,46 @@ func GetFacilityStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get controller information
+	// Get controller information from their sessions in the last 30 days
 	rows, err := db.DB.Query(`
-		WITH controller_stats AS (
-			SELECT DISTINCT ON (c.cid)
-				c.cid,
-				COALESCE(p.name, 'Unknown') as name,
-				c.rating,
-				(
-					SELECT COUNT(DISTINCT c2.id) * 15 / 60
-					FROM controllers c2 
-					WHERE c2.cid = c.cid 
-					AND c2.callsign SIMILAR TO $1
-					AND c2.last_updated > NOW() - INTERVAL '30 days'
-				) as total_hours,
-				MAX(c.last_updated) as last_seen,
-				CASE 
-					WHEN EXISTS (
-						SELECT 1 FROM controllers c3 
-						WHERE c3.cid = c.cid 
-						AND c3.callsign SIMILAR TO $1
-						AND c3.last_updated > NOW() - INTERVAL '5 minutes'
-					) THEN 
-						json_build_object(
-							'start_time', (
-								SELECT c4.logon_time 
-								FROM controllers c4 
-								WHERE c4.cid = c.cid 
-								AND c4.callsign SIMILAR TO $1
-								AND c4.last_updated > NOW() - INTERVAL '5 minutes'
-								ORDER BY c4.last_updated DESC
-								LIMIT 1
-							),
-							'position', (
-								SELECT c4.callsign 
-								FROM controllers c4 
-								WHERE c4.cid = c.cid 
-								AND c4.callsign SIMILAR TO $1
-								AND c4.last_updated > NOW() - INTERVAL '5 minutes'
-								ORDER BY c4.last_updated DESC
-								LIMIT 1
-							)
-						)
-					ELSE NULL
-				END as active_session
-			FROM controllers c
-			LEFT JOIN pilots p ON p.cid = c.cid
-			WHERE c.callsign SIMILAR TO $1
-			AND c.last_updated > NOW() - INTERVAL '30 days'
-			GROUP BY c.cid, p.name, c.rating
-			ORDER BY c.cid, MAX(c.last_updated) DESC
+		WITH sessions AS (
+			SELECT
+				vatsim_id, rating, callsign, start_time, end_time, closed,
+				GREATEST(EXTRACT(EPOCH FROM end_time - GREATEST(start_time, NOW() - INTERVAL '30 days')), 0) AS seconds
+			FROM connections
+			WHERE type = $2 AND facility = $1
+			AND controller_position(callsign) <> 'OTHER'
+			AND end_time > NOW() - INTERVAL '30 days'
+		),
+		controller_stats AS (
+			SELECT
+				vatsim_id,
+				(array_agg(rating ORDER BY end_time DESC))[1] AS rating,
+				(array_agg(callsign ORDER BY end_time DESC))[1] AS last_callsign,
+				SUM(seconds)::bigint AS seconds,
+				MAX(end_time) AS last_seen,
+				(array_agg(start_time ORDER BY start_time DESC) FILTER (WHERE NOT closed))[1] AS active_start,
+				(array_agg(callsign ORDER BY start_time DESC) FILTER (WHERE NOT closed))[1] AS active_position
+			FROM sessions
+			GROUP BY vatsim_id
 		)
-		SELECT 
-			cid,
-			name,
-			rating,
-			total_hours,
-			last_seen,
-			active_session::text
-		FROM controller_stats
-		WHERE total_hours > 0
-		ORDER BY total_hours DESC
-	`, facility+"_(DEL|GND|TWR|APP|DEP|CTR|FSS)")
+		SELECT
+			cs.vatsim_id,
+			COALESCE((
+				SELECT c.name FROM controllers c
+				WHERE c.callsign = cs.last_callsign AND c.cid = cs.vatsim_id::integer
+				ORDER BY c.last_updated DESC
+				LIMIT 1
+			), 'Unknown'),
+			cs.rating,
+			cs.seconds,
+			cs.last_seen,
+			cs.active_start,
+			cs.active_position
+		FROM controller_stats cs
+		WHERE cs.seconds > 0
+		ORDER BY cs.seconds DESC
+	`, facility.ID, TypeATC)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 		return
@@ -1067,32 +1558,30 @@ func GetFacilityStats(w http.ResponseWriter, r *http.Request) {
 
 	for rows.Next() {
 		var ctrl ControllerInfo
-		var activeSessionJSON sql.NullString
+		var seconds int64
+		var activeStart sql.NullTime
+		var activePosition sql.NullString
 		err := rows.Scan(
 			&ctrl.CID,
 			&ctrl.Name,
 			&ctrl.Rating,
-			&ctrl.TotalHours,
+			&seconds,
 			&ctrl.LastSeen,
-			&activeSessionJSON,
+			&activeStart,
+			&activePosition,
 		)
 		if err != nil {
 			continue
 		}
+		ctrl.TotalHours = secondsToHours(seconds)
 
-		if activeSessionJSON.Valid {
-			var session struct {
+		if activeStart.Valid && activePosition.Valid {
+			ctrl.ActiveSession = &struct {
 				StartTime time.Time `json:"start_time"`
 				Position  string    `json:"position"`
-			}
-			if err := json.Unmarshal([]byte(activeSessionJSON.String), &session); err == nil {
-				ctrl.ActiveSession = &struct {
-					StartTime time.Time `json:"start_time"`
-					Position  string    `json:"position"`
-				}{
-					StartTime: session.StartTime,
-					Position:  session.Position,
-				}
+			}{
+				StartTime: activeStart.Time,
+				Position:  activePosition.String,
 			}
 		}
 
@@ -1107,14 +1596,15 @@ func GetFacilityStats(w http.ResponseWriter, r *http.Request) {
 				EXTRACT(DOW FROM p.last_updated AT TIME ZONE 'UTC') as day_of_week,
 				COUNT(DISTINCT p.id) as traffic_count,
 				bool_or(EXISTS (
-					SELECT 1 FROM controllers c 
-					WHERE c.callsign LIKE $1 
-					AND c.last_updated >= p.last_updated - INTERVAL '15 minutes'
-					AND c.last_updated <= p.last_updated + INTERVAL '15 minutes'
+					SELECT 1 FROM connections c
+					WHERE c.type = $3 AND c.facility = $1
+					AND controller_position(c.callsign) <> 'OTHER'
+					AND c.start_time <= p.last_updated
+					AND c.end_time >= p.last_updated
 				)) as has_coverage
 			FROM pilots p
 			JOIN flight_plans fp ON fp.pilot_id = p.id
-			WHERE (fp.arrival = $2 OR fp.departure = $2)
+			WHERE (fp.arrival = ANY($2) OR fp.departure = ANY($2))
 			AND p.last_updated > NOW() - INTERVAL '7 days'
 			GROUP BY 
 				EXTRACT(HOUR FROM p.last_updated AT TIME ZONE 'UTC'),
@@ -1125,7 +1615,7 @@ func GetFacilityStats(w http.ResponseWriter, r *http.Request) {
 		)
 		SELECT hour, day_of_week, traffic_count, has_coverage
 		FROM hourly_stats
-	`, facility+"_%", facility)
+	`, facility.ID, pq.Array(airportCodes), TypeATC)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
 		return
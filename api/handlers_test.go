@@ -0,0 +1,134 @@
+package api
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseSearchArea(t *testing.T) {
+	area, err := parseSearchArea(url.Values{
+		"bbox":         {"-10,40,5,55"},
+		"min_altitude": {"10000"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area.box == nil || area.box.West != -10 || area.box.North != 55 {
+		t.Errorf("unexpected box %+v", area.box)
+	}
+	if area.minAltitude == nil || *area.minAltitude != 10000 || area.maxAltitude != nil {
+		t.Errorf("unexpected altitude band %v-%v", area.minAltitude, area.maxAltitude)
+	}
+
+	area, err = parseSearchArea(url.Values{"lat": {"51.47"}, "lon": {"-0.45"}, "radius": {"50"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if area.radius != 50 || area.box == nil || !area.box.Contains(51.47, -0.45) {
+		t.Errorf("unexpected radius area %+v", area)
+	}
+}
+
+func TestParseSearchAreaInvalid(t *testing.T) {
+	for _, values := range []url.Values{
+		{"bbox": {"1,2,3"}},
+		{"bbox": {"0,60,10,50"}},
+		{"lat": {"51"}, "lon": {"0"}},
+		{"lat": {"91"}, "lon": {"0"}, "radius": {"10"}},
+		{"bbox": {"0,0,1,1"}, "lat": {"0"}, "lon": {"0"}, "radius": {"10"}},
+		{"max_groundspeed": {"fast"}},
+		{"lat": {"NaN"}, "lon": {"0"}, "radius": {"10"}},
+		{"lat": {"51"}, "lon": {"Inf"}, "radius": {"10"}},
+		{"lat": {"51"}, "lon": {"0"}, "radius": {"NaN"}},
+		{"lat": {"51"}, "lon": {"0"}, "radius": {"+Inf"}},
+		{"bbox": {"NaN,50,10,60"}},
+		{"bbox": {"0,-Inf,10,60"}},
+	} {
+		if _, err := parseSearchArea(values); err == nil {
+			t.Errorf("expected an error for %v", values)
+		}
+	}
+}
+
+func TestParseLeaderboardQuery(t *testing.T) {
+	hours, _ := findLeaderboard("pilot_hours")
+	controllers, _ := findLeaderboard("controller_hours")
+
+	q, err := parseLeaderboardQuery(hours, url.Values{}, defaultLeaderboardLimit)
+	if err != nil || q.period != "all" || q.limit != defaultLeaderboardLimit {
+		t.Errorf("defaults = %+v, %v", q, err)
+	}
+
+	q, err = parseLeaderboardQuery(controllers, url.Values{"period": {"Week"}, "facility": {"lon"}, "limit": {"5"}}, defaultLeaderboardLimit)
+	if err != nil || q.period != "week" || q.facility != "LON" || q.limit != 5 {
+		t.Errorf("controller query = %+v, %v", q, err)
+	}
+
+	invalid := []url.Values{
+		{"period": {"year"}},
+		{"facility": {"LON"}},
+		{"limit": {"0"}},
+		{"limit": {"101"}},
+	}
+	for _, query := range invalid {
+		if _, err := parseLeaderboardQuery(hours, query, defaultLeaderboardLimit); err == nil {
+			t.Errorf("%v should be rejected", query)
+		}
+	}
+
+	if _, ok := findLeaderboard("karma"); ok {
+		t.Error("unknown board found")
+	}
+}
+
+func TestControllerType(t *testing.T) {
+	tests := []struct {
+		callsign string
+		facility int
+		want     ConnectionType
+	}{
+		{"EGLL_TWR", 4, TypeATC},
+		{"EGTT_CTR", 6, TypeATC},
+		{"EGLL_ATIS", 4, TypeATIS},
+		{"egll_atis", 4, TypeATIS},
+		{"JD_OBS", 0, TypeObserver},
+		{"EGLL_OBS", 4, TypeObserver},
+		{"EGLL_TWR", 0, TypeObserver},
+	}
+	for _, tt := range tests {
+		if got := ControllerType(tt.callsign, tt.facility); got != tt.want {
+			t.Errorf("ControllerType(%s, %d) = %d, want %d", tt.callsign, tt.facility, got, tt.want)
+		}
+	}
+}
+
+func TestNewPilotTotals(t *testing.T) {
+	updated := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	// 10h total: 1h student, 2h PPL, 3h instrument, 1.5h CPL, 2.5h ATPL
+	totals := newPilotTotals([6]int64{36000, 3600, 7200, 10800, 5400, 9000}, 7, 1234.56, updated)
+
+	if totals.TotalHours != 10 || totals.TotalFlights != 7 || !totals.LastUpdated.Equal(updated) {
+		t.Errorf("totals = %+v", totals)
+	}
+	if totals.StudentHours != 1 || totals.PPLHours != 2 || totals.InstrumentHours != 3 ||
+		totals.CPLHours != 1.5 || totals.ATPLHours != 2.5 {
+		t.Errorf("hours per rating = %+v", totals)
+	}
+	if totals.TotalDistanceNM != 1234.6 {
+		t.Errorf("distance = %v, want 1234.6", totals.TotalDistanceNM)
+	}
+}
+
+func TestNewControllerTotals(t *testing.T) {
+	// 5h total over DEL 0.25h, GND 0.5h, TWR 1h, APP 1.25h, CTR 2h and 1 minute elsewhere
+	totals := newControllerTotals([8]int64{18000, 900, 1800, 3600, 4500, 7200, 0, 60}, 4, time.Time{})
+
+	want := PositionHours{DEL: 0.25, GND: 0.5, TWR: 1, APP: 1.25, CTR: 2, FSS: 0, Other: 0.02}
+	if totals.TotalHours != 5 || totals.Sessions != 4 || totals.Positions != want {
+		t.Errorf("totals = %+v, want positions %+v", totals, want)
+	}
+	if totals.Ratings == nil {
+		t.Error("ratings should be an empty list, not null")
+	}
+}
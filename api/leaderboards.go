@@ -0,0 +1,185 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// leaderboardBoard describes a leaderboard and the unit of its values
+type leaderboardBoard struct {
+	name string
+	unit string
+	// Whether the board may be narrowed to a facility
+	perFacility bool
+}
+
+// leaderboardBoards are the boards in leaderboard_rankings, in the order
+// of the overview
+var leaderboardBoards = []leaderboardBoard{
+	{name: "pilot_hours", unit: "hours"},
+	{name: "controller_hours", unit: "hours", perFacility: true},
+	{name: "distance", unit: "nm"},
+	{name: "sessions", unit: "sessions"},
+	{name: "airports", unit: "airports"},
+}
+
+var leaderboardPeriods = []string{"day", "week", "month", "all"}
+
+const (
+	defaultLeaderboardLimit = 25
+	// maxLeaderboardLimit is the number of ranks materialized per board
+	maxLeaderboardLimit = 100
+)
+
+// leaderboardQuery selects one ranking
+type leaderboardQuery struct {
+	board    leaderboardBoard
+	period   string
+	facility string
+	limit    int
+}
+
+func findLeaderboard(name string) (leaderboardBoard, bool) {
+	for _, board := range leaderboardBoards {
+		if board.name == name {
+			return board, true
+		}
+	}
+	return leaderboardBoard{}, false
+}
+
+// parseLeaderboardQuery reads the period, facility and limit parameters.
+// The period defaults to all time.
+func parseLeaderboardQuery(board leaderboardBoard, query url.Values, defaultLimit int) (leaderboardQuery, error) {
+	q := leaderboardQuery{board: board, period: "all", limit: defaultLimit}
+
+	if period := strings.ToLower(query.Get("period")); period != "" {
+		q.period = ""
+		for _, p := range leaderboardPeriods {
+			if p == period {
+				q.period = p
+			}
+		}
+		if q.period == "" {
+			return q, fmt.Errorf("period must be one of %s", strings.Join(leaderboardPeriods, ", "))
+		}
+	}
+
+	if facility := strings.ToUpper(query.Get("facility")); facility != "" {
+		if !board.perFacility {
+			return q, fmt.Errorf("the %s leaderboard has no facilities", board.name)
+		}
+		q.facility = facility
+	}
+
+	if l := query.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit <= 0 || limit > maxLeaderboardLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxLeaderboardLimit)
+		}
+		q.limit = limit
+	}
+
+	return q, nil
+}
+
+// GetLeaderboards returns the top members of every board for a period
+func GetLeaderboards(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("facility") != "" {
+		http.Error(w, "facility is only supported on a single leaderboard", http.StatusBadRequest)
+		return
+	}
+
+	overview := LeaderboardOverview{Boards: make([]Leaderboard, 0, len(leaderboardBoards))}
+	for _, board := range leaderboardBoards {
+		q, err := parseLeaderboardQuery(board, r.URL.Query(), 10)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		overview.Period = q.period
+
+		leaderboard, err := queryLeaderboard(q)
+		if err != nil {
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		overview.Boards = append(overview.Boards, leaderboard)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(overview)
+}
+
+// GetLeaderboard returns the ranking of one board
+func GetLeaderboard(w http.ResponseWriter, r *http.Request) {
+	board, ok := findLeaderboard(mux.Vars(r)["board"])
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Unknown leaderboard"})
+		return
+	}
+
+	q, err := parseLeaderboardQuery(board, r.URL.Query(), defaultLeaderboardLimit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	leaderboard, err := queryLeaderboard(q)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(leaderboard)
+}
+
+// queryLeaderboard reads a ranking from the materialized rankings,
+// converting seconds to hours and rounding distances
+func queryLeaderboard(q leaderboardQuery) (Leaderboard, error) {
+	leaderboard := Leaderboard{
+		Board:    q.board.name,
+		Period:   q.period,
+		Facility: q.facility,
+		Unit:     q.board.unit,
+		Entries:  make([]LeaderboardEntry, 0),
+	}
+
+	rows, err := db.DB.Query(`
+		SELECT rank, vatsim_id, value, refreshed_at
+		FROM leaderboard_rankings
+		WHERE board = $1 AND period = $2 AND facility = $3 AND rank <= $4
+		ORDER BY rank, vatsim_id
+	`, q.board.name, q.period, q.facility, q.limit)
+	if err != nil {
+		return leaderboard, fmt.Errorf("error querying leaderboard: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry LeaderboardEntry
+		var refreshed time.Time
+		if err := rows.Scan(&entry.Rank, &entry.VatsimID, &entry.Value, &refreshed); err != nil {
+			return leaderboard, fmt.Errorf("error scanning leaderboard: %v", err)
+		}
+		switch q.board.unit {
+		case "hours":
+			entry.Value = secondsToHours(int64(entry.Value))
+		case "nm":
+			entry.Value = roundDistance(entry.Value)
+		}
+		leaderboard.RefreshedAt = &refreshed
+		leaderboard.Entries = append(leaderboard.Entries, entry)
+	}
+	return leaderboard, rows.Err()
+}
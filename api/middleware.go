@@ -2,6 +2,7 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -80,7 +81,7 @@ func RateLimit(next http.Handler) http.Handler {
 
 		// Set rate limit headers
 		w.Header().Set("X-RateLimit-Limit", "100")
-		w.Header().Set("X-RateLimit-Remaining", string(maxRequests-client.count))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(maxRequests-client.count))
 		w.Header().Set("X-RateLimit-Reset", time.Unix(client.lastSeen.Add(windowDuration).Unix(), 0).Format(time.RFC3339))
 
 		next.ServeHTTP(w, r)
@@ -1,10 +1,15 @@
 package api
 
-import "time"
+import (
+	"time"
+
+	"github.com/vainnor/vatsim-stats/airports"
+)
 
 // Airport Traffic Types
 type AirportTraffic struct {
 	ICAO              string             `json:"icao"`
+	Airport           *airports.Airport  `json:"airport,omitempty"`
 	Timestamp         time.Time          `json:"timestamp"`
 	ActiveControllers []ActiveController `json:"active_controllers"`
 	ATIS              *ATISInfo          `json:"atis,omitempty"`
@@ -13,9 +18,10 @@ type AirportTraffic struct {
 }
 
 type ActiveController struct {
-	Position   string           `json:"position"`
-	Frequency  string           `json:"frequency"`
-	Controller ControllerDetail `json:"controller"`
+	Position    string           `json:"position"`
+	Frequency   string           `json:"frequency"`
+	VisualRange int              `json:"visual_range"`
+	Controller  ControllerDetail `json:"controller"`
 }
 
 type ControllerDetail struct {
@@ -37,19 +43,38 @@ type TrafficInfo struct {
 }
 
 type FlightInfo struct {
-	Callsign    string    `json:"callsign"`
-	Aircraft    string    `json:"aircraft"`
-	Time        time.Time `json:"time"`
-	Altitude    int       `json:"altitude"`
-	Groundspeed int       `json:"groundspeed"`
-	Origin      string    `json:"origin,omitempty"`
-	Destination string    `json:"destination,omitempty"`
+	Callsign    string      `json:"callsign"`
+	Aircraft    string      `json:"aircraft"`
+	Time        time.Time   `json:"time"`
+	Altitude    int         `json:"altitude"`
+	Groundspeed int         `json:"groundspeed"`
+	Origin      string      `json:"origin,omitempty"`
+	Destination string      `json:"destination,omitempty"`
+	Position    *Position   `json:"position,omitempty"`
+	Times       FlightTimes `json:"times"`
+}
+
+// FlightTimes are the filed and actual movement times of a flight. Actual
+// times are null until observed; derived durations are omitted until both
+// of their ends are known.
+type FlightTimes struct {
+	FiledDepTime          string     `json:"filed_deptime,omitempty"`
+	FiledEnrouteTime      string     `json:"filed_enroute_time,omitempty"`
+	OffBlock              *time.Time `json:"off_block"`
+	Takeoff               *time.Time `json:"takeoff"`
+	Landing               *time.Time `json:"landing"`
+	OnBlock               *time.Time `json:"on_block"`
+	BlockMinutes          *int       `json:"block_minutes,omitempty"`
+	AirborneMinutes       *int       `json:"airborne_minutes,omitempty"`
+	DepartureDelayMinutes *int       `json:"departure_delay_minutes,omitempty"`
 }
 
 type AirportStatistics struct {
-	HourlyMovements int `json:"hourly_movements"`
-	DepartureCount  int `json:"departure_count"`
-	ArrivalCount    int `json:"arrival_count"`
+	HourlyMovements    int `json:"hourly_movements"`
+	DepartureCount     int `json:"departure_count"`
+	ArrivalCount       int `json:"arrival_count"`
+	DeparturesLastHour int `json:"departures_last_hour"`
+	ArrivalsLastHour   int `json:"arrivals_last_hour"`
 }
 
 // Active Flights Search Types
@@ -68,6 +93,7 @@ type FlightSearchResult struct {
 	Position    Position  `json:"position"`
 	FlightPlan  string    `json:"flight_plan,omitempty"`
 	StartTime   time.Time `json:"start_time"`
+	DistanceNM  *float64  `json:"distance_nm,omitempty"`
 }
 
 type Position struct {
@@ -76,35 +102,190 @@ type Position struct {
 	Heading   int     `json:"heading"`
 }
 
+// Flight Phase Types
+type FlightPhases struct {
+	ConnectionID ConnectionID    `json:"connection_id"`
+	CurrentPhase FlightPhase     `json:"current_phase"`
+	Times        *FlightTimes    `json:"times"`
+	Phases       []PhaseInterval `json:"phases"`
+}
+
+// PhaseInterval is one phase of a flight and where it started. EndedAt is
+// null for the current phase of a flight still in progress.
+type PhaseInterval struct {
+	Phase       FlightPhase `json:"phase"`
+	StartedAt   time.Time   `json:"started_at"`
+	EndedAt     *time.Time  `json:"ended_at"`
+	Latitude    float64     `json:"latitude"`
+	Longitude   float64     `json:"longitude"`
+	Altitude    int         `json:"altitude"`
+	Groundspeed int         `json:"groundspeed"`
+}
+
+// Flight Track Types
+type FlightTrack struct {
+	ConnectionID ConnectionID `json:"connection_id"`
+	ToleranceNM  *float64     `json:"tolerance_nm,omitempty"`
+	Total        int          `json:"total"`
+	Points       []TrackPoint `json:"points"`
+}
+
+// TrackPoint is a reported position of a flight. Altitude is in feet.
+type TrackPoint struct {
+	Time        time.Time `json:"time"`
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	Altitude    int       `json:"altitude"`
+	Groundspeed int       `json:"groundspeed"`
+	Heading     int       `json:"heading"`
+}
+
+// Leaderboard Types
+type LeaderboardOverview struct {
+	Period string        `json:"period"`
+	Boards []Leaderboard `json:"boards"`
+}
+
+// Leaderboard is a ranking of members. RefreshedAt is when the rankings
+// were last computed, null if the board is empty.
+type Leaderboard struct {
+	Board       string             `json:"board"`
+	Period      string             `json:"period"`
+	Facility    string             `json:"facility,omitempty"`
+	Unit        string             `json:"unit"`
+	RefreshedAt *time.Time         `json:"refreshed_at"`
+	Entries     []LeaderboardEntry `json:"entries"`
+}
+
+type LeaderboardEntry struct {
+	Rank     int     `json:"rank"`
+	VatsimID string  `json:"vatsim_id"`
+	Value    float64 `json:"value"`
+}
+
+// Live Controller Types
+type ControllerList struct {
+	Timestamp   time.Time        `json:"timestamp"`
+	Controllers []LiveController `json:"controllers"`
+	Total       int              `json:"total"`
+}
+
+// LiveController is a connected controller. Center is where the
+// controller is located by locateControllers, if anywhere.
+type LiveController struct {
+	Callsign    string    `json:"callsign"`
+	CID         int       `json:"cid"`
+	Name        string    `json:"name"`
+	Frequency   string    `json:"frequency"`
+	Facility    int       `json:"facility"`
+	Rating      int       `json:"rating"`
+	VisualRange int       `json:"visual_range"`
+	LogonTime   time.Time `json:"logon_time"`
+	Center      *LatLon   `json:"center,omitempty"`
+}
+
+type LatLon struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
 // Network Statistics Types
 type NetworkStatistics struct {
-	Timestamp     time.Time       `json:"timestamp"`
-	Global        GlobalStats     `json:"global"`
-	ServerStats   []ServerStats   `json:"servers"`
-	RegionStats   []RegionStats   `json:"regions"`
-	RatingStats   []RatingStats   `json:"ratings"`
-	AircraftStats []AircraftStats `json:"aircraft"`
+	Timestamp      time.Time       `json:"timestamp"`
+	SnapshotTime   time.Time       `json:"snapshot_time"`
+	SnapshotAge    float64         `json:"snapshot_age_seconds"`
+	Global         GlobalStats     `json:"global"`
+	ServerStats    []ServerStats   `json:"servers"`
+	RegionGrouping string          `json:"region_grouping"`
+	RegionStats    []RegionStats   `json:"regions"`
+	RatingStats    []RatingStats   `json:"ratings"`
+	AircraftStats  []AircraftStats `json:"aircraft"`
 }
 
 type GlobalStats struct {
 	TotalClients   int `json:"total_clients"`
 	TotalPilots    int `json:"total_pilots"`
 	TotalATCs      int `json:"total_atcs"`
+	TotalATIS      int `json:"total_atis"`
 	TotalObservers int `json:"total_observers"`
 	ActivePilots   int `json:"active_pilots"`
 }
 
+// ServerStats is the number of clients on a server. Hostname, location and
+// whether it accepts connections are only known for servers in the server
+// list or the data feed's list of servers.
 type ServerStats struct {
-	Name           string `json:"name"`
-	ConnectedUsers int    `json:"connected_users"`
-	Location       string `json:"location"`
+	Name                     string `json:"name"`
+	ConnectedUsers           int    `json:"connected_users"`
+	Location                 string `json:"location"`
+	Hostname                 string `json:"hostname,omitempty"`
+	ClientConnectionsAllowed *bool  `json:"client_connections_allowed,omitempty"`
+	Sweatbox                 bool   `json:"sweatbox,omitempty"`
 }
 
-type RegionStats struct {
-	Region       string `json:"region"`
+// FIRSummary is a FIR with the clients currently within it
+type FIRSummary struct {
+	ID           string `json:"id"`
+	Name         string `json:"name,omitempty"`
+	Region       string `json:"region,omitempty"`
+	Division     string `json:"division,omitempty"`
+	Oceanic      bool   `json:"oceanic"`
 	ActivePilots int    `json:"active_pilots"`
 	ActiveATCs   int    `json:"active_atcs"`
-	TopAirports  []int  `json:"top_airports"`
+}
+
+type FIRList struct {
+	Timestamp time.Time    `json:"timestamp"`
+	FIRs      []FIRSummary `json:"firs"`
+	Total     int          `json:"total"`
+}
+
+// FIRTraffic is the live and recent pilot traffic within a FIR. Unique
+// pilot counts are of the members seen in it over the period.
+type FIRTraffic struct {
+	ID           string           `json:"id"`
+	Name         string           `json:"name,omitempty"`
+	Region       string           `json:"region,omitempty"`
+	Division     string           `json:"division,omitempty"`
+	Oceanic      bool             `json:"oceanic"`
+	Timestamp    time.Time        `json:"timestamp"`
+	ActivePilots int              `json:"active_pilots"`
+	LastHour     int              `json:"unique_pilots_last_hour"`
+	LastDay      int              `json:"unique_pilots_last_day"`
+	Hourly       []FIRHourlyCount `json:"hourly"`
+	Pilots       []FIRPilot       `json:"pilots"`
+}
+
+type FIRHourlyCount struct {
+	Hour   time.Time `json:"hour"`
+	Pilots int       `json:"pilots"`
+}
+
+// FIRPilot is a pilot currently within a FIR
+type FIRPilot struct {
+	CID         int      `json:"cid"`
+	Callsign    string   `json:"callsign"`
+	Departure   string   `json:"departure,omitempty"`
+	Arrival     string   `json:"arrival,omitempty"`
+	Altitude    int      `json:"altitude"`
+	Groundspeed int      `json:"groundspeed"`
+	Position    Position `json:"position"`
+}
+
+// RegionStats is the traffic in a region of FIRs. TopAirports are the
+// region's airports with the most departures and arrivals filed by the
+// connected pilots.
+type RegionStats struct {
+	Region       string          `json:"region"`
+	ActivePilots int             `json:"active_pilots"`
+	ActiveATCs   int             `json:"active_atcs"`
+	TopAirports  []RegionAirport `json:"top_airports"`
+}
+
+type RegionAirport struct {
+	ICAO       string `json:"icao"`
+	Departures int    `json:"departures"`
+	Arrivals   int    `json:"arrivals"`
 }
 
 type RatingStats struct {
@@ -131,6 +312,10 @@ type RouteStatistics struct {
 	AvgAltitude    int `json:"avg_altitude"`
 	AvgGroundspeed int `json:"avg_groundspeed"`
 	ActiveFlights  int `json:"active_flights"`
+	// Average great-circle distance flown, and the direct distance between
+	// the airports if both are known
+	AvgDistanceNM    float64  `json:"avg_distance_nm"`
+	DirectDistanceNM *float64 `json:"direct_distance_nm,omitempty"`
 }
 
 type PopularRoutes struct {
@@ -142,6 +327,8 @@ type PopularRoutes struct {
 // FacilityStatistics represents statistics for an ATC facility
 type FacilityStatistics struct {
 	Facility    string           `json:"facility"`
+	Name        string           `json:"name,omitempty"`
+	Airports    []string         `json:"airports"`
 	Timestamp   time.Time        `json:"timestamp"`
 	Coverage    CoverageStats    `json:"coverage"`
 	Traffic     TrafficStats     `json:"traffic"`
@@ -149,16 +336,41 @@ type FacilityStatistics struct {
 	PeakTimes   []PeakTime       `json:"peak_times"`
 }
 
-// CoverageStats represents controller coverage statistics
+// CoverageStats represents controller coverage statistics, in hours during
+// which at least one position of the facility was staffed
 type CoverageStats struct {
-	TotalHours      int     `json:"total_hours"`
-	LastDay         int     `json:"last_24h"`
-	LastWeek        int     `json:"last_7d"`
-	LastMonth       int     `json:"last_30d"`
+	TotalHours      float64 `json:"total_hours"`
+	LastDay         float64 `json:"last_24h"`
+	LastWeek        float64 `json:"last_7d"`
+	LastMonth       float64 `json:"last_30d"`
 	AveragePerDay   float64 `json:"avg_per_day"`
 	CoveragePercent float64 `json:"coverage_percent"`
 }
 
+// FacilityCoverage is when a facility was staffed between From and To.
+// Timeline merges all positions; Positions has the intervals of each.
+type FacilityCoverage struct {
+	Facility        string             `json:"facility"`
+	Name            string             `json:"name,omitempty"`
+	From            time.Time          `json:"from"`
+	To              time.Time          `json:"to"`
+	CoveredHours    float64            `json:"covered_hours"`
+	CoveragePercent float64            `json:"coverage_percent"`
+	Timeline        []CoverageInterval `json:"timeline"`
+	Positions       []PositionCoverage `json:"positions"`
+}
+
+type PositionCoverage struct {
+	Callsign  string             `json:"callsign"`
+	Hours     float64            `json:"hours"`
+	Intervals []CoverageInterval `json:"intervals"`
+}
+
+type CoverageInterval struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
 // TrafficStats represents traffic handling statistics
 type TrafficStats struct {
 	TotalFlights     int `json:"total_flights"`
@@ -175,7 +387,7 @@ type ControllerInfo struct {
 	CID           string    `json:"cid"`
 	Name          string    `json:"name"`
 	Rating        int       `json:"rating"`
-	TotalHours    int       `json:"total_hours"`
+	TotalHours    float64   `json:"total_hours"`
 	LastSeen      time.Time `json:"last_seen"`
 	ActiveSession *struct {
 		StartTime time.Time `json:"start_time"`
@@ -226,3 +438,33 @@ type MonthlyActivity struct {
 	PeakUsers        int       `json:"peak_users"`
 	UniqueUsers      int       `json:"unique_users"`
 }
+
+// ATISHistory lists the ATIS stations of an airport online between From
+// and To, latest first
+type ATISHistory struct {
+	ICAO     string        `json:"icao"`
+	From     time.Time     `json:"from"`
+	To       time.Time     `json:"to"`
+	Sessions []ATISSession `json:"sessions"`
+}
+
+// ATISSession is an ATIS station's session and the broadcasts recorded
+// during it. Updates is the number of broadcasts.
+type ATISSession struct {
+	ConnectionID int64        `json:"connection_id"`
+	VatsimID     string       `json:"vatsim_id"`
+	Callsign     string       `json:"callsign"`
+	Frequency    string       `json:"frequency"`
+	Start        time.Time    `json:"start"`
+	End          time.Time    `json:"end"`
+	Online       bool         `json:"online"`
+	Updates      int          `json:"updates"`
+	Letters      []ATISLetter `json:"letters"`
+}
+
+// ATISLetter is an information letter and the runways in use from Time
+type ATISLetter struct {
+	Letter  string    `json:"letter"`
+	Runways []string  `json:"runways"`
+	Time    time.Time `json:"time"`
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/vainnor/vatsim-stats/airports"
+	"github.com/vainnor/vatsim-stats/firs"
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+// topRegionAirports is the number of airports listed per region
+const topRegionAirports = 5
+
+// regionGrouping is the grouping of network statistics regions when a
+// request does not name one
+var regionGrouping, _ = firs.GroupBy(firs.GroupDivision)
+
+// SetRegions sets the default region grouping. It must be called before
+// the API starts serving.
+func SetRegions(grouping *firs.Grouping) {
+	regionGrouping = grouping
+}
+
+// requestGrouping returns the grouping named by the regions parameter, or
+// the default one
+func requestGrouping(r *http.Request) (*firs.Grouping, error) {
+	if name := r.URL.Query().Get("regions"); name != "" {
+		return firs.GroupBy(name)
+	}
+	return regionGrouping, nil
+}
+
+// countRegions groups the traffic of a snapshot's FIRs into regions,
+// busiest first, and ranks the airports of each by the flight plans filed
+// to or from them
+func countRegions(data *types.VatsimData, grouping *firs.Grouping) ([]RegionStats, error) {
+	counts, err := countFIRTraffic(data)
+	if err != nil {
+		return nil, err
+	}
+
+	regions := make(map[string]*RegionStats)
+	region := func(name string) *RegionStats {
+		rs, ok := regions[name]
+		if !ok {
+			rs = &RegionStats{Region: name, TopAirports: make([]RegionAirport, 0)}
+			regions[name] = rs
+		}
+		return rs
+	}
+
+	for id, fir := range counts {
+		b, ok := firIndex.Get(id)
+		if !ok {
+			continue
+		}
+		if name := grouping.Region(b); name != "" {
+			rs := region(name)
+			rs.ActivePilots += fir.ActivePilots
+			rs.ActiveATCs += fir.ActiveATCs
+		}
+	}
+
+	// Rank airports by their region's filed departures and arrivals
+	movements := make(map[string]*RegionAirport)
+	movement := func(icao string) *RegionAirport {
+		icao = strings.ToUpper(icao)
+		a, ok := movements[icao]
+		if !ok {
+			a = &RegionAirport{ICAO: icao}
+			movements[icao] = a
+		}
+		return a
+	}
+	for _, pilot := range data.Pilots {
+		if fp := pilot.FlightPlan; fp != nil {
+			if fp.Departure != "" {
+				movement(fp.Departure).Departures++
+			}
+			if fp.Arrival != "" {
+				movement(fp.Arrival).Arrivals++
+			}
+		}
+	}
+
+	if firIndex != nil && firIndex.Len() > 0 && len(movements) > 0 {
+		codes := make([]string, 0, len(movements))
+		for icao := range movements {
+			codes = append(codes, icao)
+		}
+		found, err := airports.LookupMany(codes)
+		if err != nil {
+			return nil, err
+		}
+
+		for icao, a := range movements {
+			airport, ok := found[icao]
+			if !ok {
+				continue
+			}
+			b, ok := firIndex.Locate(airport.Latitude, airport.Longitude)
+			if !ok {
+				continue
+			}
+			if name := grouping.Region(b); name != "" {
+				rs := region(name)
+				rs.TopAirports = append(rs.TopAirports, *a)
+			}
+		}
+	}
+
+	list := make([]RegionStats, 0, len(regions))
+	for _, rs := range regions {
+		sort.Slice(rs.TopAirports, func(i, j int) bool {
+			a, b := rs.TopAirports[i], rs.TopAirports[j]
+			if a.Departures+a.Arrivals != b.Departures+b.Arrivals {
+				return a.Departures+a.Arrivals > b.Departures+b.Arrivals
+			}
+			return a.ICAO < b.ICAO
+		})
+		if len(rs.TopAirports) > topRegionAirports {
+			rs.TopAirports = rs.TopAirports[:topRegionAirports]
+		}
+		list = append(list, *rs)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.ActivePilots+a.ActiveATCs != b.ActivePilots+b.ActiveATCs {
+			return a.ActivePilots+a.ActiveATCs > b.ActivePilots+b.ActiveATCs
+		}
+		return a.Region < b.Region
+	})
+	return list, nil
+}
@@ -8,6 +8,7 @@ import (
 type Collector interface {
 	GetStats() types.CollectionStats
 	GetCurrentData() (*types.VatsimData, error)
+	GetServers() map[string]types.Server
 }
 
 // NewRouter creates and configures a new router with all API endpoints
@@ -24,21 +25,38 @@ func NewRouter(collector Collector) *mux.Router {
 	api.Use(RateLimit)
 
 	// Membership endpoints
-	api.HandleFunc("/membership/{cid}/pilot", GetMembershipHandler).Methods("GET")
 	api.HandleFunc("/membership/{cid}/debug", GetPilotDebug).Methods("GET")
+	api.HandleFunc("/membership/{cid}/summary", GetMembershipSummaryHandler).Methods("GET")
+	api.HandleFunc("/membership/{cid}/{type}", GetMembershipHandler).Methods("GET")
 	api.HandleFunc("/collector/stats", GetCollectorStats(collector)).Methods("GET")
 
 	// Airport traffic endpoint
 	api.HandleFunc("/airports/{icao}/traffic", GetAirportTraffic).Methods("GET")
+	api.HandleFunc("/airports/{icao}/atis", GetAirportATIS).Methods("GET")
 
 	// Flight search endpoint
 	api.HandleFunc("/flights/search", SearchFlights).Methods("GET")
+	api.HandleFunc("/flights/{cid}/{callsign}/phases", GetFlightPhases).Methods("GET")
+	api.HandleFunc("/flights/{cid}/{callsign}/track", GetFlightTrack).Methods("GET")
+	api.HandleFunc("/connections/{id:[0-9]+}/track", GetConnectionTrack).Methods("GET")
+
+	// Live controllers endpoint
+	api.HandleFunc("/controllers", GetControllersHandler(collector)).Methods("GET")
 
 	// Network statistics endpoint
 	api.HandleFunc("/network/stats", GetNetworkStatisticsHandler(collector)).Methods("GET")
 
+	// FIR endpoints
+	api.HandleFunc("/firs", GetFIRsHandler(collector)).Methods("GET")
+	api.HandleFunc("/firs/{fir}/traffic", GetFIRTraffic).Methods("GET")
+
 	// Add facility statistics endpoint
 	api.HandleFunc("/facilities/{facility}/stats", GetFacilityStats).Methods("GET")
+	api.HandleFunc("/facilities/{facility}/coverage", GetFacilityCoverage).Methods("GET")
+
+	// Leaderboard endpoints
+	api.HandleFunc("/leaderboards", GetLeaderboards).Methods("GET")
+	api.HandleFunc("/leaderboards/{board}", GetLeaderboard).Methods("GET")
 
 	// Route statistics endpoints
 	api.HandleFunc("/routes/popular", GetPopularRoutes).Methods("GET")
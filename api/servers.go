@@ -0,0 +1,76 @@
+package api
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+// LookupServer finds a client's server, keyed by ident, by its ident or
+// name
+func LookupServer(servers map[string]types.Server, name string) (types.Server, bool) {
+	if server, ok := servers[name]; ok {
+		return server, true
+	}
+	for _, server := range servers {
+		if strings.EqualFold(server.Name, name) || strings.EqualFold(server.Ident, name) {
+			return server, true
+		}
+	}
+	return types.Server{}, false
+}
+
+// serverStats counts a snapshot's clients per server and adds the metadata
+// of the known servers, keyed by ident, busiest first. Clients are matched
+// to servers with LookupServer, as the collector does. Known servers
+// without clients are listed too, except sweatbox servers, which are not on
+// the live network.
+func serverStats(data *types.VatsimData, servers map[string]types.Server) []ServerStats {
+	counts := make(map[string]int)
+	count := func(name string) {
+		if server, ok := LookupServer(servers, name); ok {
+			name = server.Ident
+		}
+		counts[name]++
+	}
+	for _, pilot := range data.Pilots {
+		count(pilot.Server)
+	}
+	for _, controller := range data.Controllers {
+		count(controller.Server)
+	}
+
+	stats := make([]ServerStats, 0, len(counts))
+	listed := make(map[string]bool, len(counts))
+	for _, server := range servers {
+		name := server.Ident
+		count := counts[name]
+		if count == 0 && server.IsSweatbox {
+			continue
+		}
+		allowed := server.ClientConnectionsAllowed
+		stats = append(stats, ServerStats{
+			Name:                     name,
+			ConnectedUsers:           count,
+			Location:                 server.Location,
+			Hostname:                 server.Hostname,
+			ClientConnectionsAllowed: &allowed,
+			Sweatbox:                 server.IsSweatbox,
+		})
+		listed[name] = true
+	}
+	for name, count := range counts {
+		if !listed[name] {
+			stats = append(stats, ServerStats{Name: name, ConnectedUsers: count})
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].ConnectedUsers != stats[j].ConnectedUsers {
+			return stats[i].ConnectedUsers > stats[j].ConnectedUsers
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
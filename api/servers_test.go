@@ -0,0 +1,39 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+func TestServerStats(t *testing.T) {
+	data := &types.VatsimData{
+		Pilots:      []types.Pilot{{Server: "USA-EAST"}, {Server: "USA-EAST"}, {Server: "USA-EAST"}, {Server: "UNKNOWN"}},
+		Controllers: []types.Controller{{Server: "GERMANY"}, {Server: "Frankfurt"}},
+	}
+	servers := map[string]types.Server{
+		"USA-EAST": {Ident: "USA-EAST", Name: "USA-EAST", Location: "New York, USA", ClientConnectionsAllowed: true},
+		"GERMANY":  {Ident: "GERMANY", Name: "FRANKFURT", Location: "Frankfurt, Germany"},
+		"UK-1":     {Ident: "UK-1", Name: "UK-1", Location: "London, UK", ClientConnectionsAllowed: true},
+		"SWEATBOX": {Ident: "SWEATBOX", Name: "SWEATBOX", IsSweatbox: true},
+	}
+
+	stats := serverStats(data, servers)
+	if len(stats) != 4 {
+		t.Fatalf("expected 4 servers, got %+v", stats)
+	}
+	if s := stats[0]; s.Name != "USA-EAST" || s.ConnectedUsers != 3 || s.Location != "New York, USA" ||
+		s.ClientConnectionsAllowed == nil || !*s.ClientConnectionsAllowed {
+		t.Errorf("busiest server = %+v", s)
+	}
+	// Clients naming the server rather than its ident count towards it
+	if s := stats[1]; s.Name != "GERMANY" || s.ConnectedUsers != 2 || s.ClientConnectionsAllowed == nil || *s.ClientConnectionsAllowed {
+		t.Errorf("second server = %+v", s)
+	}
+	if s := stats[2]; s.Name != "UNKNOWN" || s.ClientConnectionsAllowed != nil {
+		t.Errorf("unknown server = %+v", s)
+	}
+	if s := stats[3]; s.Name != "UK-1" || s.ConnectedUsers != 0 {
+		t.Errorf("empty server = %+v", s)
+	}
+}
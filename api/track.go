@@ -0,0 +1,313 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/vainnor/vatsim-stats/db"
+	"github.com/vainnor/vatsim-stats/geo"
+)
+
+// Media types of the track export formats
+const (
+	kmlType = "application/vnd.google-earth.kml+xml"
+	gpxType = "application/gpx+xml"
+)
+
+const feetToMeters = 0.3048
+
+// GetFlightTrack returns the position history of a pilot's latest session
+// under a callsign
+func GetFlightTrack(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	conn, _, err := latestPilotConnection(vars["cid"], strings.ToUpper(vars["callsign"]))
+	writeFlightTrack(w, r, conn, err)
+}
+
+// GetConnectionTrack returns the position history of a pilot session by
+// its connection id
+func GetConnectionTrack(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid connection id", http.StatusBadRequest)
+		return
+	}
+	conn, _, err := pilotConnection(id)
+	writeFlightTrack(w, r, conn, err)
+}
+
+// writeFlightTrack loads, simplifies and writes the track of a session in
+// the requested format. err is the error from looking up the session.
+func writeFlightTrack(w http.ResponseWriter, r *http.Request, conn ConnectionID, err error) {
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"error": "No flight found"})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	format, err := trackFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	track := FlightTrack{ConnectionID: conn}
+	if value := r.URL.Query().Get("simplify"); value != "" {
+		tolerance, err := parseFinite(value)
+		if err != nil || tolerance <= 0 {
+			http.Error(w, "simplify must be a positive tolerance in nautical miles", http.StatusBadRequest)
+			return
+		}
+		track.ToleranceNM = &tolerance
+	}
+
+	track.Points, err = queryTrackPoints(conn)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if track.ToleranceNM != nil {
+		track.Points = simplifyTrack(track.Points, *track.ToleranceNM)
+	}
+	track.Total = len(track.Points)
+
+	switch format {
+	case "geojson":
+		writeGeoJSON(w, trackFeatures(track))
+	case "kml":
+		writeTrackFile(w, kmlType, trackFilename(conn, "kml"), trackKML(track))
+	case "gpx":
+		writeTrackFile(w, gpxType, trackFilename(conn, "gpx"), trackGPX(track))
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(track)
+	}
+}
+
+// trackFormat returns the format named by the format parameter or else
+// by the Accept header, defaulting to json
+func trackFormat(r *http.Request) (string, error) {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		switch format {
+		case "json", "geojson", "kml", "gpx":
+			return format, nil
+		}
+		return "", fmt.Errorf("format must be one of json, geojson, kml or gpx")
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, geoJSONType):
+		return "geojson", nil
+	case strings.Contains(accept, kmlType):
+		return "kml", nil
+	case strings.Contains(accept, gpxType):
+		return "gpx", nil
+	}
+	return "json", nil
+}
+
+// trackSnapshotLag bounds how long after a pilot's last report the
+// snapshot carrying it may be stamped
+const trackSnapshotLag = 5 * time.Minute
+
+// queryTrackPoints returns the positions reported by a session in order
+func queryTrackPoints(conn ConnectionID) ([]TrackPoint, error) {
+	rows, err := db.DB.Query(`
+		SELECT snapshot_time, last_updated, latitude, longitude, altitude, groundspeed, heading
+		FROM pilots
+		WHERE cid = $1 AND callsign = $2 AND snapshot_time BETWEEN $3 AND $4
+		ORDER BY snapshot_time
+	`, conn.VatsimID, conn.Callsign, conn.Start, conn.End.Add(trackSnapshotLag))
+	if err != nil {
+		return nil, fmt.Errorf("error querying track: %v", err)
+	}
+	defer rows.Close()
+
+	var reports []trackReport
+	for rows.Next() {
+		var report trackReport
+		err := rows.Scan(
+			&report.point.Time,
+			&report.updated,
+			&report.point.Latitude,
+			&report.point.Longitude,
+			&report.point.Altitude,
+			&report.point.Groundspeed,
+			&report.point.Heading,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning track point: %v", err)
+		}
+		reports = append(reports, report)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return trackPoints(conn, reports), nil
+}
+
+// trackReport is a snapshot of a pilot and the client time of its report
+type trackReport struct {
+	point   TrackPoint
+	updated time.Time
+}
+
+// trackPoints keeps the reports of a session in snapshot order. The
+// session ends at the pilot's last report, which snapshots carry after
+// that time, so reports are matched to the session by their client time.
+// Snapshots that repeat a pilot's previous report are skipped.
+func trackPoints(conn ConnectionID, reports []trackReport) []TrackPoint {
+	points := make([]TrackPoint, 0, len(reports))
+	var previous time.Time
+	for _, report := range reports {
+		if report.updated.After(conn.End) || report.updated.Equal(previous) {
+			continue
+		}
+		previous = report.updated
+		points = append(points, report.point)
+	}
+	return points
+}
+
+// simplifyTrack drops the points of a track within toleranceNM of the
+// line through the points kept
+func simplifyTrack(points []TrackPoint, toleranceNM float64) []TrackPoint {
+	line := make([][2]float64, len(points))
+	for i, point := range points {
+		line[i] = [2]float64{point.Longitude, point.Latitude}
+	}
+
+	keep := geo.Simplify(line, toleranceNM)
+	simplified := make([]TrackPoint, 0, len(keep))
+	for _, i := range keep {
+		simplified = append(simplified, points[i])
+	}
+	return simplified
+}
+
+// trackFeatures maps a track to a line with altitudes in meters. The
+// times and groundspeeds of its points are properties, in the same order.
+func trackFeatures(track FlightTrack) FeatureCollection {
+	coordinates := make([][3]float64, 0, len(track.Points))
+	times := make([]time.Time, 0, len(track.Points))
+	groundspeeds := make([]int, 0, len(track.Points))
+	for _, point := range track.Points {
+		coordinates = append(coordinates, [3]float64{
+			point.Longitude, point.Latitude, float64(point.Altitude) * feetToMeters,
+		})
+		times = append(times, point.Time)
+		groundspeeds = append(groundspeeds, point.Groundspeed)
+	}
+
+	var geometry *Geometry
+	switch len(coordinates) {
+	case 0:
+	case 1:
+		geometry = &Geometry{Type: "Point", Coordinates: coordinates[0]}
+	default:
+		geometry = &Geometry{Type: "LineString", Coordinates: coordinates}
+	}
+
+	fc := newFeatureCollection()
+	fc.add(geometry, struct {
+		ConnectionID ConnectionID `json:"connection_id"`
+		Times        []time.Time  `json:"times"`
+		Groundspeeds []int        `json:"groundspeeds"`
+	}{track.ConnectionID, times, groundspeeds})
+	return fc
+}
+
+type kmlDocument struct {
+	XMLName   xml.Name     `xml:"http://www.opengis.net/kml/2.2 kml"`
+	Name      string       `xml:"Document>name"`
+	Placemark kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name         string    `xml:"name"`
+	Description  string    `xml:"description"`
+	Begin        time.Time `xml:"TimeSpan>begin"`
+	End          time.Time `xml:"TimeSpan>end"`
+	AltitudeMode string    `xml:"LineString>altitudeMode"`
+	Coordinates  string    `xml:"LineString>coordinates"`
+}
+
+// trackKML renders a track as a KML line with altitudes in meters
+func trackKML(track FlightTrack) interface{} {
+	coordinates := make([]string, 0, len(track.Points))
+	for _, point := range track.Points {
+		coordinates = append(coordinates, fmt.Sprintf("%f,%f,%.0f",
+			point.Longitude, point.Latitude, float64(point.Altitude)*feetToMeters))
+	}
+
+	conn := track.ConnectionID
+	return kmlDocument{
+		Name: conn.Callsign,
+		Placemark: kmlPlacemark{
+			Name:         conn.Callsign,
+			Description:  fmt.Sprintf("%s flown by %s", conn.Callsign, conn.VatsimID),
+			Begin:        conn.Start.UTC(),
+			End:          conn.End.UTC(),
+			AltitudeMode: "absolute",
+			Coordinates:  strings.Join(coordinates, " "),
+		},
+	}
+}
+
+type gpxDocument struct {
+	XMLName xml.Name   `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version string     `xml:"version,attr"`
+	Creator string     `xml:"creator,attr"`
+	Name    string     `xml:"trk>name"`
+	Points  []gpxPoint `xml:"trk>trkseg>trkpt"`
+}
+
+type gpxPoint struct {
+	Latitude  float64   `xml:"lat,attr"`
+	Longitude float64   `xml:"lon,attr"`
+	Elevation float64   `xml:"ele"`
+	Time      time.Time `xml:"time"`
+}
+
+// trackGPX renders a track as a GPX track with elevations in meters
+func trackGPX(track FlightTrack) interface{} {
+	doc := gpxDocument{
+		Version: "1.1",
+		Creator: "vatsim-stats",
+		Name:    track.ConnectionID.Callsign,
+		Points:  make([]gpxPoint, 0, len(track.Points)),
+	}
+	for _, point := range track.Points {
+		doc.Points = append(doc.Points, gpxPoint{
+			Latitude:  point.Latitude,
+			Longitude: point.Longitude,
+			Elevation: float64(point.Altitude) * feetToMeters,
+			Time:      point.Time.UTC(),
+		})
+	}
+	return doc
+}
+
+func trackFilename(conn ConnectionID, extension string) string {
+	return fmt.Sprintf("%s-%d.%s", conn.Callsign, conn.ID, extension)
+}
+
+// writeTrackFile writes an XML track as a download
+func writeTrackFile(w http.ResponseWriter, contentType, filename string, doc interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrackFormat(t *testing.T) {
+	tests := []struct {
+		url    string
+		accept string
+		want   string
+	}{
+		{"/track", "", "json"},
+		{"/track", "application/gpx+xml", "gpx"},
+		{"/track", "application/vnd.google-earth.kml+xml", "kml"},
+		{"/track", "application/geo+json", "geojson"},
+		{"/track?format=KML", "application/geo+json", "kml"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.url, nil)
+		r.Header.Set("Accept", tt.accept)
+		if got, err := trackFormat(r); err != nil || got != tt.want {
+			t.Errorf("trackFormat(%s, %q) = %q, %v, want %q", tt.url, tt.accept, got, err, tt.want)
+		}
+	}
+
+	if _, err := trackFormat(httptest.NewRequest("GET", "/track?format=csv", nil)); err == nil {
+		t.Error("an unknown format should be rejected")
+	}
+}
+
+func testTrack() FlightTrack {
+	start := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	track := FlightTrack{ConnectionID: ConnectionID{ID: 42, VatsimID: "1234567", Callsign: "BAW282", Start: start, End: start.Add(time.Hour)}}
+	for i := 0; i < 5; i++ {
+		track.Points = append(track.Points, TrackPoint{
+			Time:      start.Add(time.Duration(i) * 15 * time.Second),
+			Latitude:  51.47,
+			Longitude: -0.45 + float64(i)*0.1,
+			Altitude:  i * 1000,
+		})
+	}
+	return track
+}
+
+func TestSimplifyTrack(t *testing.T) {
+	track := testTrack()
+	simplified := simplifyTrack(track.Points, 0.5)
+	if len(simplified) != 2 || simplified[1].Altitude != 4000 {
+		t.Errorf("a straight track should keep its endpoints, got %+v", simplified)
+	}
+}
+
+func TestTrackPointsKeepFinalReport(t *testing.T) {
+	start := time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)
+	conn := ConnectionID{Callsign: "BAW282", Start: start, End: start.Add(40 * time.Second)}
+	report := func(snapshot, updated time.Duration, altitude int) trackReport {
+		return trackReport{
+			point:   TrackPoint{Time: start.Add(snapshot), Altitude: altitude},
+			updated: start.Add(updated),
+		}
+	}
+
+	points := trackPoints(conn, []trackReport{
+		report(15*time.Second, 5*time.Second, 1000),
+		report(30*time.Second, 20*time.Second, 500),
+		report(45*time.Second, 40*time.Second, 0),
+		report(60*time.Second, 40*time.Second, 0),
+		// a later session under the same callsign
+		report(90*time.Second, 80*time.Second, 2000),
+	})
+	if len(points) != 3 || points[2].Altitude != 0 || !points[2].Time.Equal(start.Add(45*time.Second)) {
+		t.Errorf("the last report should end the track once, got %+v", points)
+	}
+}
+
+func TestWriteFlightTrackRejectsTolerance(t *testing.T) {
+	for _, value := range []string{"0", "-1", "abc", "NaN", "Inf", "-Inf"} {
+		w := httptest.NewRecorder()
+		writeFlightTrack(w, httptest.NewRequest("GET", "/track?simplify="+value, nil), ConnectionID{}, nil)
+		if w.Code != 400 {
+			t.Errorf("simplify=%s: status %d, want 400", value, w.Code)
+		}
+	}
+}
+
+func TestTrackGPX(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeTrackFile(w, gpxType, "BAW282-42.gpx", trackGPX(testTrack()))
+
+	if ct := w.Header().Get("Content-Type"); ct != gpxType {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.HasPrefix(w.Body.String(), "<?xml") {
+		t.Error("missing XML declaration")
+	}
+
+	var doc gpxDocument
+	if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Name != "BAW282" || len(doc.Points) != 5 {
+		t.Fatalf("unexpected document %+v", doc)
+	}
+	if ele := doc.Points[1].Elevation; ele < 304 || ele > 305 {
+		t.Errorf("1000 ft should be about 304.8 m, got %v", ele)
+	}
+}
+
+func TestTrackKML(t *testing.T) {
+	doc := trackKML(testTrack()).(kmlDocument)
+	coordinates := strings.Fields(doc.Placemark.Coordinates)
+	if len(coordinates) != 5 || coordinates[0] != "-0.450000,51.470000,0" {
+		t.Errorf("unexpected coordinates %q", doc.Placemark.Coordinates)
+	}
+}
@@ -1,13 +1,46 @@
 package api
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type ConnectionType int
 
 const (
-	TypePilot ConnectionType = 1
-	TypeATC   ConnectionType = 2
-	TypeATIS  ConnectionType = 3
+	TypePilot    ConnectionType = 1
+	TypeATC      ConnectionType = 2
+	TypeATIS     ConnectionType = 3
+	TypeObserver ConnectionType = 4
+)
+
+// ControllerType classifies a client of the controllers list. Callsigns
+// ending in _ATIS are ATIS and those ending in _OBS, or connected without
+// a facility, are observers; everyone else controls.
+func ControllerType(callsign string, facility int) ConnectionType {
+	callsign = strings.ToUpper(callsign)
+	switch {
+	case strings.HasSuffix(callsign, "_ATIS"):
+		return TypeATIS
+	case strings.HasSuffix(callsign, "_OBS"), facility == 0:
+		return TypeObserver
+	}
+	return TypeATC
+}
+
+// FlightPhase is the phase of flight detected from a pilot's movements
+type FlightPhase string
+
+const (
+	PhasePreflight FlightPhase = "preflight"
+	PhaseTaxiOut   FlightPhase = "taxi_out"
+	PhaseTakeoff   FlightPhase = "takeoff"
+	PhaseClimb     FlightPhase = "climb"
+	PhaseCruise    FlightPhase = "cruise"
+	PhaseDescent   FlightPhase = "descent"
+	PhaseApproach  FlightPhase = "approach"
+	PhaseLanded    FlightPhase = "landed"
+	PhaseTaxiIn    FlightPhase = "taxi_in"
 )
 
 type ConnectionID struct {
@@ -33,17 +66,20 @@ type ATCStats struct {
 	CruiseAltsModified int          `json:"cruisealtsmodified"`
 	TempAltsModified   int          `json:"tempaltsmodified"`
 	ScratchpadMods     int          `json:"scratchpadmods"`
+	OnlineHours        float64      `json:"online_hours"`
 }
 
 type PilotStats struct {
 	ConnectionID    ConnectionID `json:"connection_id"`
-	TotalHours      int          `json:"total_hours"`
+	TotalHours      float64      `json:"total_hours"`
 	TotalFlights    int          `json:"total_flights"`
-	StudentHours    int          `json:"student_hours"`
-	PPLHours        int          `json:"ppl_hours"`
-	InstrumentHours int          `json:"instrument_hours"`
-	CPLHours        int          `json:"cpl_hours"`
-	ATPLHours       int          `json:"atpl_hours"`
+	StudentHours    float64      `json:"student_hours"`
+	PPLHours        float64      `json:"ppl_hours"`
+	InstrumentHours float64      `json:"instrument_hours"`
+	CPLHours        float64      `json:"cpl_hours"`
+	ATPLHours       float64      `json:"atpl_hours"`
+	DistanceNM      float64      `json:"distance_nm"`
+	TotalDistanceNM float64      `json:"total_distance_nm"`
 	CurrentSession  *SessionInfo `json:"current_session,omitempty"`
 }
 
@@ -59,8 +95,54 @@ type ATISStats struct {
 	Updates      int          `json:"updates"`
 	Frequency    string       `json:"frequency"`
 	Letter       string       `json:"letter"`
+	Runways      []string     `json:"runways"`
 }
 
 type MembershipResponse struct {
 	Items []interface{} `json:"items"`
 }
+
+// MemberSummary aggregates a member's pilot and controller time
+type MemberSummary struct {
+	VatsimID   string            `json:"vatsim_id"`
+	Pilot      *PilotTotals      `json:"pilot"`
+	Controller *ControllerTotals `json:"controller"`
+}
+
+type PilotTotals struct {
+	TotalHours      float64   `json:"total_hours"`
+	TotalFlights    int       `json:"total_flights"`
+	StudentHours    float64   `json:"student_hours"`
+	PPLHours        float64   `json:"ppl_hours"`
+	InstrumentHours float64   `json:"instrument_hours"`
+	CPLHours        float64   `json:"cpl_hours"`
+	ATPLHours       float64   `json:"atpl_hours"`
+	TotalDistanceNM float64   `json:"total_distance_nm"`
+	LastUpdated     time.Time `json:"last_updated"`
+}
+
+type ControllerTotals struct {
+	TotalHours  float64       `json:"total_hours"`
+	Sessions    int           `json:"sessions"`
+	Positions   PositionHours `json:"positions"`
+	Ratings     []RatingHours `json:"ratings"`
+	LastUpdated time.Time     `json:"last_updated"`
+}
+
+// PositionHours holds controller hours per position type
+type PositionHours struct {
+	DEL   float64 `json:"del"`
+	GND   float64 `json:"gnd"`
+	TWR   float64 `json:"twr"`
+	APP   float64 `json:"app"`
+	CTR   float64 `json:"ctr"`
+	FSS   float64 `json:"fss"`
+	Other float64 `json:"other"`
+}
+
+type RatingHours struct {
+	Rating    int     `json:"rating"`
+	ShortName string  `json:"short_name"`
+	Hours     float64 `json:"hours"`
+	Sessions  int     `json:"sessions"`
+}
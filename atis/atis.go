@@ -0,0 +1,138 @@
+// Package atis reads the information letter and runways in use from the
+// controller info text of ATIS stations.
+package atis
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Info is what an ATIS broadcast announces
+type Info struct {
+	// Letter is the information letter, empty if none was found
+	Letter string
+	// Runways are the runways in use, in the order they are announced
+	Runways []string
+}
+
+// Equal reports whether two broadcasts announce the same letter and runways
+func (i Info) Equal(o Info) bool {
+	return i.Letter == o.Letter && slices.Equal(i.Runways, o.Runways)
+}
+
+// phonetic maps the spelling alphabet to letters
+var phonetic = map[string]string{
+	"ALPHA": "A", "ALFA": "A", "BRAVO": "B", "CHARLIE": "C", "DELTA": "D",
+	"ECHO": "E", "FOXTROT": "F", "GOLF": "G", "HOTEL": "H", "INDIA": "I",
+	"JULIET": "J", "JULIETT": "J", "KILO": "K", "LIMA": "L", "MIKE": "M",
+	"NOVEMBER": "N", "OSCAR": "O", "PAPA": "P", "QUEBEC": "Q", "ROMEO": "R",
+	"SIERRA": "S", "TANGO": "T", "UNIFORM": "U", "VICTOR": "V", "WHISKEY": "W",
+	"WHISKY": "W", "XRAY": "X", "YANKEE": "Y", "ZULU": "Z",
+}
+
+// runwayPattern matches a runway designator such as 9, 27L or 04C
+var runwayPattern = regexp.MustCompile(`^(\d{1,2})([LRC]?)$`)
+
+// tokenPattern splits text into words and the separators of runway lists,
+// keeping X-RAY whole
+var tokenPattern = regexp.MustCompile(`[A-Z0-9]+(?:-RAY)?|[/,&]`)
+
+// Parse reads an ATIS text. The letter is the one following INFO,
+// INFORMATION or ATIS, as a letter or spelled out. Runways are those
+// following RWY or RUNWAY, optionally after IN USE, and may be listed
+// separated by slashes, commas or AND. Runways listed as CLOSED or CLSD and
+// runway condition codes such as 5/5/5 are left out.
+func Parse(lines []string) Info {
+	var tokens []string
+	for _, line := range lines {
+		for _, token := range tokenPattern.FindAllString(strings.ToUpper(line), -1) {
+			tokens = append(tokens, strings.ReplaceAll(token, "-", ""))
+		}
+	}
+
+	var info Info
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "INFO", "INFORMATION", "ATIS":
+			if info.Letter == "" && i+1 < len(tokens) {
+				info.Letter = letter(tokens[i+1])
+			}
+
+		case "RWY", "RWYS", "RUNWAY", "RUNWAYS":
+			j := i + 1
+			if j+1 < len(tokens) && tokens[j] == "IN" && tokens[j+1] == "USE" {
+				j += 2
+			}
+			runways, next := runwayList(tokens, j)
+			if next < len(tokens) && (tokens[next] == "CLOSED" || tokens[next] == "CLSD") {
+				runways = nil
+			}
+			for _, runway := range runways {
+				if !slices.Contains(info.Runways, runway) {
+					info.Runways = append(info.Runways, runway)
+				}
+			}
+			i = next - 1
+		}
+	}
+	return info
+}
+
+// runwayList reads the runways listed from tokens[start], each but the
+// first following a separator. It returns them with the index of the
+// first token after the list. A list of three or more single digits is a
+// runway condition code and yields no runways.
+func runwayList(tokens []string, start int) ([]string, int) {
+	var runways []string
+	codes := true
+	j := start
+	for j < len(tokens) {
+		runway, ok := runwayDesignator(tokens[j])
+		if !ok {
+			break
+		}
+		runways = append(runways, runway)
+		codes = codes && len(tokens[j]) == 1
+		j++
+
+		if j+1 < len(tokens) && isSeparator(tokens[j]) {
+			if _, ok := runwayDesignator(tokens[j+1]); ok {
+				j++
+				continue
+			}
+		}
+		break
+	}
+	if codes && len(runways) >= 3 {
+		return nil, j
+	}
+	return runways, j
+}
+
+// isSeparator reports whether a token separates the runways of a list
+func isSeparator(token string) bool {
+	return token == "/" || token == "," || token == "&" || token == "AND"
+}
+
+// letter returns the information letter a word stands for, if any
+func letter(word string) string {
+	if len(word) == 1 && word[0] >= 'A' && word[0] <= 'Z' {
+		return word
+	}
+	return phonetic[word]
+}
+
+// runwayDesignator normalises a runway to two digits and its side
+func runwayDesignator(word string) (string, bool) {
+	m := runwayPattern.FindStringSubmatch(word)
+	if m == nil {
+		return "", false
+	}
+	number, _ := strconv.Atoi(m[1])
+	if number < 1 || number > 36 {
+		return "", false
+	}
+	return strconv.Itoa(number/10) + strconv.Itoa(number%10) + m[2], true
+}
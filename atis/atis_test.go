@@ -0,0 +1,67 @@
+package atis
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    []string
+		letter  string
+		runways []string
+	}{
+		{
+			"heathrow",
+			[]string{"THIS IS HEATHROW INFORMATION K TIME 1250", "LDG RWY 27L DEP RWY 27R", "RWY CONDITION CODE 5/5/5"},
+			"K", []string{"27L", "27R"},
+		},
+		{
+			"spelled out",
+			[]string{"Kennedy airport information Bravo. 1251Z.", "ILS RWY 4R approach in use. Departing runways 4L, 31L."},
+			"B", []string{"04R", "04L", "31L"},
+		},
+		{
+			"in use and slashes",
+			[]string{"EDDF ATIS INFO Q", "RUNWAY IN USE 25L/25C AND 18"},
+			"Q", []string{"25L", "25C", "18"},
+		},
+		{
+			"x-ray",
+			[]string{"ATIS INFORMATION X-RAY RWY 09"},
+			"X", []string{"09"},
+		},
+		{
+			"closed",
+			[]string{"INFORMATION C", "RWY 27L IN USE", "RWY 09R CLOSED", "RWY 09L/27R CLSD FOR WORKS"},
+			"C", []string{"27L"},
+		},
+		{
+			"condition codes",
+			[]string{"INFORMATION D", "LDG RWY 27L 5/5/5", "RWY 5/5/5 WET WET WET"},
+			"D", []string{"27L"},
+		},
+		{
+			"nothing",
+			[]string{"FOR INFO CONTACT LONDON CONTROL", "RWY 99"},
+			"", nil,
+		},
+	}
+	for _, tt := range tests {
+		info := Parse(tt.text)
+		if info.Letter != tt.letter || !slices.Equal(info.Runways, tt.runways) {
+			t.Errorf("%s: got %q %v, want %q %v", tt.name, info.Letter, info.Runways, tt.letter, tt.runways)
+		}
+	}
+}
+
+func TestInfoEqual(t *testing.T) {
+	a := Info{Letter: "A", Runways: []string{"27L"}}
+	if !a.Equal(Info{Letter: "A", Runways: []string{"27L"}}) {
+		t.Error("identical broadcasts differ")
+	}
+	if a.Equal(Info{Letter: "A", Runways: []string{"09R"}}) || a.Equal(Info{Letter: "B", Runways: []string{"27L"}}) {
+		t.Error("different broadcasts are equal")
+	}
+}
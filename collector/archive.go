@@ -0,0 +1,125 @@
+package collector
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// archiveTimeFormat sorts lexically in chronological order
+const archiveTimeFormat = "20060102T150405Z"
+
+// Archive records raw VATSIM payloads as gzip files named by update timestamp
+type Archive struct {
+	Dir string
+}
+
+// NewArchive creates an archive rooted at dir, creating the directory if needed
+func NewArchive(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating archive directory: %v", err)
+	}
+	return &Archive{Dir: dir}, nil
+}
+
+// Write stores a raw payload under the given snapshot timestamp
+func (a *Archive) Write(timestamp time.Time, raw []byte) error {
+	name := fmt.Sprintf("vatsim-data-%s.json.gz", timestamp.UTC().Format(archiveTimeFormat))
+	path := filepath.Join(a.Dir, name)
+
+	// Write to a temporary file first so a crash never leaves a truncated snapshot
+	tmp, err := os.CreateTemp(a.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	if _, err := gz.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReplaySource paces an underlying source by the update timestamps of the
+// snapshots it returns. A speed of 1 replays in real time, 10 ten times
+// faster, and 0 or less as fast as possible.
+type ReplaySource struct {
+	source DataSource
+	speed  float64
+	last   time.Time
+}
+
+func NewReplaySource(source DataSource, speed float64) *ReplaySource {
+	return &ReplaySource{source: source, speed: speed}
+}
+
+func (s *ReplaySource) Fetch() ([]byte, error) {
+	raw, err := s.source.Fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.speed <= 0 {
+		return raw, nil
+	}
+
+	var header struct {
+		General struct {
+			UpdateTimestamp time.Time `json:"update_timestamp"`
+		} `json:"general"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return nil, err
+	}
+
+	timestamp := header.General.UpdateTimestamp
+	if !s.last.IsZero() {
+		if delay := time.Duration(float64(timestamp.Sub(s.last)) / s.speed); delay > 0 {
+			time.Sleep(delay)
+		}
+	}
+	s.last = timestamp
+
+	return raw, nil
+}
+
+func (s *ReplaySource) String() string {
+	return fmt.Sprintf("replay of %s", s.source)
+}
+
+// Replay feeds every snapshot from the collector's source through
+// FetchAndStore until the source is exhausted. It returns the number of
+// snapshots processed.
+func Replay(c *Collector) (int, error) {
+	count := 0
+	for {
+		err := c.FetchAndStore()
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+
+		if count%100 == 0 {
+			log.Printf("Replayed %d snapshots", count)
+		}
+	}
+}
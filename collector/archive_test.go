@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+// recordingSource passes fetches through and keeps the update timestamp of
+// every payload returned
+type recordingSource struct {
+	DataSource
+	timestamps []time.Time
+}
+
+func (s *recordingSource) Fetch() ([]byte, error) {
+	raw, err := s.DataSource.Fetch()
+	if err != nil {
+		return nil, err
+	}
+	var data types.VatsimData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	s.timestamps = append(s.timestamps, data.General.UpdateTimestamp)
+	return raw, nil
+}
+
+func TestArchiveReplayRoundTrip(t *testing.T) {
+	archive, err := NewArchive(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Written out of order, replayed by update timestamp
+	var want []time.Time
+	for _, n := range []int{3, 1, 4, 2} {
+		snapshot := syntheticSnapshot(n, 10)
+		raw, err := json.Marshal(snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := archive.Write(snapshot.General.UpdateTimestamp, raw); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	for n := 1; n <= 4; n++ {
+		want = append(want, syntheticSnapshot(n, 10).General.UpdateTimestamp)
+	}
+
+	entries, err := os.ReadDir(archive.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json.gz") {
+			t.Errorf("unexpected file %s left in the archive", entry.Name())
+		}
+	}
+
+	dir, err := NewDirSource(archive.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &recordingSource{DataSource: dir}
+	c := NewCollector(NewReplaySource(source, 0))
+
+	count, err := Replay(c)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if count != len(want) {
+		t.Fatalf("replayed %d snapshots, want %d", count, len(want))
+	}
+	for i, ts := range source.timestamps {
+		if !ts.Equal(want[i]) {
+			t.Errorf("snapshot %d replayed at %v, want %v", i, ts, want[i])
+		}
+	}
+
+	current, err := c.GetCurrentData()
+	if err != nil || !current.General.UpdateTimestamp.Equal(want[len(want)-1]) {
+		t.Errorf("current snapshot after replay = %v, %v", current, err)
+	}
+	if stats := c.GetStats(); stats.TotalSnapshots != int64(len(want)) {
+		t.Errorf("collector counted %d snapshots", stats.TotalSnapshots)
+	}
+}
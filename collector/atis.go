@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/api"
+)
+
+// atisUpdate is a new letter or change of runways broadcast by an ATIS
+type atisUpdate struct {
+	connectionID int64
+	time         time.Time
+	letter       string
+	runways      []string
+}
+
+// detectATISUpdates finds the ATIS stations present in the snapshot whose
+// broadcast changed since it was last recorded, stamped with the snapshot
+// time so a station records at most one update per snapshot. Broadcasts
+// without a letter are ignored.
+func detectATISUpdates(sessions []*activeConnection, now time.Time) []atisUpdate {
+	var updates []atisUpdate
+	for _, session := range sessions {
+		if session.connectionType != api.TypeATIS || session.atisInfo.Letter == "" {
+			continue
+		}
+		if session.recordedATIS != nil && session.recordedATIS.Equal(session.atisInfo) {
+			continue
+		}
+
+		updates = append(updates, atisUpdate{
+			connectionID: session.id,
+			time:         now,
+			letter:       session.atisInfo.Letter,
+			runways:      session.atisInfo.Runways,
+		})
+		recorded := session.atisInfo
+		session.recordedATIS = &recorded
+		session.atisUpdates++
+	}
+	return updates
+}
+
+// insertATISUpdates records ATIS updates in a single statement
+func insertATISUpdates(tx *sql.Tx, updates []atisUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	var (
+		ids     []int64
+		times   []string
+		letters []string
+		runways []string
+	)
+	for _, u := range updates {
+		ids = append(ids, u.connectionID)
+		times = append(times, u.time.Format(time.RFC3339Nano))
+		letters = append(letters, u.letter)
+		// Runways travel as array literals, as unnest cannot take a
+		// jagged two-dimensional array
+		literal, _ := pq.Array(u.runways).Value()
+		if literal == nil {
+			literal = "{}"
+		}
+		runways = append(runways, literal.(string))
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO atis_updates (connection_id, updated_at, letter, runways)
+		SELECT id, updated_at, letter, runways::text[]
+		FROM unnest($1::bigint[], $2::timestamptz[], $3::varchar[], $4::text[])
+			AS u(id, updated_at, letter, runways)
+	`, pq.Array(ids), pq.Array(times), pq.Array(letters), pq.Array(runways))
+	return err
+}
@@ -0,0 +1,42 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/vainnor/vatsim-stats/api"
+	"github.com/vainnor/vatsim-stats/atis"
+)
+
+func TestDetectATISUpdates(t *testing.T) {
+	session := &activeConnection{id: 7, callsign: "EGLL_ATIS", connectionType: api.TypeATIS, lastSeen: at(0)}
+	broadcast := func(n int, letter string, runways ...string) []atisUpdate {
+		// Stations report their own update time, which may lag the snapshot
+		session.lastSeen = at(0)
+		session.atisInfo = atis.Info{Letter: letter, Runways: runways}
+		return detectATISUpdates([]*activeConnection{session}, at(n))
+	}
+
+	if updates := broadcast(0, "A", "27L", "27R"); len(updates) != 1 || updates[0].letter != "A" || updates[0].connectionID != 7 {
+		t.Fatalf("first broadcast: %+v", updates)
+	}
+	if updates := broadcast(1, "A", "27L", "27R"); len(updates) != 0 {
+		t.Errorf("unchanged broadcast recorded: %+v", updates)
+	}
+	if updates := broadcast(2, ""); len(updates) != 0 {
+		t.Errorf("broadcast without a letter recorded: %+v", updates)
+	}
+	if updates := broadcast(3, "A", "09L", "09R"); len(updates) != 1 || !updates[0].time.Equal(at(3)) {
+		t.Errorf("runway change: %+v", updates)
+	}
+	if updates := broadcast(4, "B", "09L", "09R"); len(updates) != 1 || updates[0].letter != "B" {
+		t.Errorf("letter change: %+v", updates)
+	}
+	if session.atisUpdates != 3 || session.recordedATIS.Letter != "B" {
+		t.Errorf("session recorded %d updates, last %+v", session.atisUpdates, session.recordedATIS)
+	}
+
+	controller := &activeConnection{connectionType: api.TypeATC, atisInfo: atis.Info{Letter: "C"}}
+	if updates := detectATISUpdates([]*activeConnection{controller}, at(5)); len(updates) != 0 {
+		t.Errorf("controller recorded as ATIS: %+v", updates)
+	}
+}
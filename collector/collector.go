@@ -3,30 +3,63 @@ package collector
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lib/pq"
 	"github.com/vainnor/vatsim-stats/api"
+	"github.com/vainnor/vatsim-stats/atis"
 	"github.com/vainnor/vatsim-stats/db"
 	"github.com/vainnor/vatsim-stats/types"
 )
 
-const vatsimDataURL = "https://data.vatsim.net/v3/vatsim-data.json"
+// ErrNoSnapshot is returned when no snapshot has been collected yet
+var ErrNoSnapshot = errors.New("no snapshot collected yet")
 
 type Collector struct {
-	lastUpdate string
-	client     *http.Client
-	// Track active connections by CID and callsign
-	activeConnections map[string]activeConnection
-	// Collection stats
-	stats types.CollectionStats
+	source  DataSource
+	archive *Archive
+	// mu serializes collection cycles and guards the fields below it
+	mu           sync.Mutex
+	lastUpdate   string
+	partitionDay time.Time
+	// Open sessions, loaded from the database on the first snapshot
+	sessions    *sessionTracker
+	gracePeriod time.Duration
+	// Optional airport positions used for flight phase detection
+	airports AirportLocator
+	// Optional facility definitions for classifying controller sessions
+	facilities FacilityClassifier
+	// Optional FIR boundaries pilot positions are attributed to
+	firs FIRLocator
+	// Optional server list, its latest contents and when it was last
+	// fetched, and the known servers merged with those of the data feed.
+	// servers is replaced, never modified, and also under currentMu so
+	// the API can read it.
+	serverSource   DataSource
+	listedServers  []types.Server
+	serversFetched time.Time
+	servers        map[string]types.Server
+	// Leaderboard refresh interval, zero when disabled, and the snapshot
+	// time of the last refresh
+	leaderboardInterval    time.Duration
+	leaderboardsRefreshed  time.Time
+	refreshingLeaderboards atomic.Bool
+	// Latest parsed snapshot, served to the API without refetching
+	currentMu sync.RWMutex
+	current   *types.VatsimData
+	// Collection stats, read concurrently by the API
+	statsMu sync.RWMutex
+	stats   types.CollectionStats
 }
 
 type activeConnection struct {
+	id             int64
 	cid            string
 	callsign       string
 	connectionType api.ConnectionType
@@ -34,6 +67,10 @@ type activeConnection struct {
 	server         string
 	startTime      time.Time
 	lastSeen       time.Time
+	// Network logon time of the latest connection, which changes on reconnect
+	logonTime time.Time
+	// When the client was first missing from the feed, zero while present
+	missingSince time.Time
 	// Statistics tracking
 	aircraftTracked    int
 	aircraftSeen       int
@@ -45,105 +82,181 @@ type activeConnection struct {
 	cruiseAltsModified int
 	tempAltsModified   int
 	scratchpadMods     int
+	// Controller frequency and the facility the session was classified into
+	frequency string
+	facility  string
+	// Latest broadcast of an ATIS station, the last one recorded and how
+	// many were recorded during the session
+	atisInfo     atis.Info
+	recordedATIS *atis.Info
+	atisUpdates  int
 	// Pilot specific stats
 	hasFlightPlan bool
+	// Great-circle distance flown between successive positions
+	distanceNM float64
+	// Latest position and filed plan of a pilot, and the flight phase
+	// derived from its positions
+	position *positionSample
+	filed    filedPlan
+	flight   *flightState
 }
 
-func NewCollector() *Collector {
+func NewCollector(source DataSource) *Collector {
 	return &Collector{
-		client: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		activeConnections: make(map[string]activeConnection),
+		source:      source,
+		gracePeriod: defaultGracePeriod,
 		stats: types.CollectionStats{
 			StartTime: time.Now(),
 		},
 	}
 }
 
+// SetArchive enables recording of every new raw payload to the archive
+func (c *Collector) SetArchive(archive *Archive) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.archive = archive
+}
+
+// GetStats returns a copy of the current collection stats
 func (c *Collector) GetStats() types.CollectionStats {
+	c.statsMu.RLock()
+	defer c.statsMu.RUnlock()
 	return c.stats
 }
 
-// GetCurrentData returns the most recently fetched VATSIM data
+// GetCurrentData returns the most recently fetched VATSIM data. The returned
+// snapshot is shared and must be treated as read-only.
 func (c *Collector) GetCurrentData() (*types.VatsimData, error) {
-	return c.fetchData()
+	c.currentMu.RLock()
+	defer c.currentMu.RUnlock()
+
+	if c.current == nil {
+		return nil, ErrNoSnapshot
+	}
+	return c.current, nil
+}
+
+// GetServers returns the known servers keyed by ident. The returned map is
+// shared and must be treated as read-only.
+func (c *Collector) GetServers() map[string]types.Server {
+	c.currentMu.RLock()
+	defer c.currentMu.RUnlock()
+	return c.servers
 }
 
+// FetchAndStore runs one collection cycle. Concurrent calls are serialized.
 func (c *Collector) FetchAndStore() error {
-	data, err := c.fetchData()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	data, raw, err := c.fetchData()
 	if err != nil {
-		return fmt.Errorf("error fetching data: %v", err)
+		return fmt.Errorf("error fetching data: %w", err)
 	}
 
+	c.currentMu.Lock()
+	c.current = data
+	c.currentMu.Unlock()
+
 	// Check if data has changed
 	if data.General.Update == c.lastUpdate {
 		return nil
 	}
 
+	// Archive the raw payload before storing so it can be replayed later
+	if c.archive != nil {
+		if err := c.archive.Write(data.General.UpdateTimestamp, raw); err != nil {
+			log.Printf("Error archiving snapshot: %v", err)
+		}
+	}
+
+	// Make sure the day's partitions exist before inserting into them
+	if day := data.General.UpdateTimestamp.UTC().Truncate(24 * time.Hour); !day.Equal(c.partitionDay) {
+		if err := db.EnsureSnapshotPartitions(day); err != nil {
+			return fmt.Errorf("error preparing partitions: %v", err)
+		}
+		c.partitionDay = day
+	}
+
 	// Store new data
 	if err := c.storeData(data); err != nil {
+		// The in-memory sessions may be ahead of the rolled back
+		// transaction, so reload them from the database next time
+		c.sessions = nil
 		return fmt.Errorf("error storing data: %v", err)
 	}
 
+	// Update the server metadata the server statistics are stored with
+	if err := c.updateServers(data, data.General.UpdateTimestamp); err != nil {
+		log.Printf("Error updating servers: %v", err)
+	}
+
 	// Store network and related statistics
 	if err := c.storeNetworkStats(data); err != nil {
 		log.Printf("Error storing network stats: %v", err)
 	}
 
 	// Store airport statistics
-	if err := c.storeAirportStats(); err != nil {
+	if err := c.storeAirportStats(data.General.UpdateTimestamp); err != nil {
 		log.Printf("Error storing airport stats: %v", err)
 	}
 
+	c.refreshLeaderboards(data.General.UpdateTimestamp)
+
 	c.lastUpdate = data.General.Update
-	c.stats.LastUpdate = time.Now()
-	c.stats.TotalSnapshots++
-	c.stats.ActivePilots = len(data.Pilots)
 
-	// Count ATC and ATIS controllers
-	c.stats.ActiveATCs = 0
-	c.stats.ActiveATIS = 0
+	// Count controllers, ATIS and observers
+	activeATCs, activeATIS, activeObservers := 0, 0, 0
 	for _, controller := range data.Controllers {
-		if len(controller.TextAtis) > 0 {
-			c.stats.ActiveATIS++
-		} else {
-			c.stats.ActiveATCs++
+		switch api.ControllerType(controller.Callsign, controller.Facility) {
+		case api.TypeATC:
+			activeATCs++
+		case api.TypeATIS:
+			activeATIS++
+		case api.TypeObserver:
+			activeObservers++
 		}
 	}
 
+	c.statsMu.Lock()
+	c.stats.LastUpdate = time.Now()
+	c.stats.TotalSnapshots++
+	c.stats.ActivePilots = len(data.Pilots)
+	c.stats.ActiveATCs = activeATCs
+	c.stats.ActiveATIS = activeATIS
+	c.stats.ActiveObservers = activeObservers
 	c.stats.ProcessedPilots += int64(len(data.Pilots))
+	stats := c.stats
+	c.statsMu.Unlock()
 
-	log.Printf("Collection update: Active pilots: %d, Active ATCs: %d, Active ATIS: %d, Total snapshots: %d, Running for: %v",
-		c.stats.ActivePilots,
-		c.stats.ActiveATCs,
-		c.stats.ActiveATIS,
-		c.stats.TotalSnapshots,
-		time.Since(c.stats.StartTime).Round(time.Second))
+	log.Printf("Collection update: Active pilots: %d, Active ATCs: %d, Active ATIS: %d, Active observers: %d, Total snapshots: %d, Running for: %v",
+		stats.ActivePilots,
+		stats.ActiveATCs,
+		stats.ActiveATIS,
+		stats.ActiveObservers,
+		stats.TotalSnapshots,
+		time.Since(stats.StartTime).Round(time.Second))
 
 	return nil
 }
 
-func (c *Collector) fetchData() (*types.VatsimData, error) {
-	resp, err := c.client.Get(vatsimDataURL)
+func (c *Collector) fetchData() (*types.VatsimData, []byte, error) {
+	body, err := c.source.Fetch()
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var data types.VatsimData
 	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return &data, nil
+	return &data, body, nil
 }
 
+// storeData ingests a snapshot in a single transaction using a fixed number
+// of round trips regardless of how many clients are connected
 func (c *Collector) storeData(data *types.VatsimData) error {
 	tx, err := db.DB.Begin()
 	if err != nil {
@@ -151,30 +264,9 @@ func (c *Collector) storeData(data *types.VatsimData) error {
 	}
 	defer tx.Rollback()
 
-	// Store facilities
-	for _, facility := range data.Facilities {
-		_, err = tx.Exec(`
-			INSERT INTO facilities (id, short_name, long_name)
-			VALUES ($1, $2, $3)
-			ON CONFLICT (id) DO UPDATE
-			SET short_name = $2, long_name = $3
-		`, facility.ID, facility.Short, facility.Long)
-		if err != nil {
-			return err
-		}
-	}
-
-	// Store ratings
-	for _, rating := range data.Ratings {
-		_, err = tx.Exec(`
-			INSERT INTO ratings (id, short_name, long_name)
-			VALUES ($1, $2, $3)
-			ON CONFLICT (id) DO UPDATE
-			SET short_name = $2, long_name = $3
-		`, rating.ID, rating.Short, rating.Long)
-		if err != nil {
-			return err
-		}
+	// Store facilities and ratings
+	if err := storeReferenceData(tx, data); err != nil {
+		return err
 	}
 
 	// Insert snapshot
@@ -193,273 +285,48 @@ func (c *Collector) storeData(data *types.VatsimData) error {
 		return err
 	}
 
-	// Track current connections to detect disconnects
-	currentConnections := make(map[string]bool)
-
-	// Process pilots
-	for _, pilot := range data.Pilots {
-		key := fmt.Sprintf("%d-%s", pilot.CID, pilot.Callsign)
-		currentConnections[key] = true
-
-		// Store pilot data
-		var pilotID int
-		err = tx.QueryRow(`
-			INSERT INTO pilots (
-				snapshot_id, cid, name, callsign, server,
-				pilot_rating, military_rating, latitude, longitude,
-				altitude, groundspeed, transponder, heading,
-				qnh_i_hg, qnh_mb, logon_time, last_updated
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
-			RETURNING id
-		`, snapshotID, pilot.CID, pilot.Name, pilot.Callsign, pilot.Server,
-			pilot.PilotRating, pilot.MilitaryRating, pilot.Latitude, pilot.Longitude,
-			pilot.Altitude, pilot.Groundspeed, pilot.Transponder, pilot.Heading,
-			pilot.QNHiHg, pilot.QNHMb, pilot.LogonTime, pilot.LastUpdated).Scan(&pilotID)
-		if err != nil {
-			return err
-		}
-
-		if pilot.FlightPlan != nil {
-			_, err = tx.Exec(`
-				INSERT INTO flight_plans (
-					pilot_id, flight_rules, aircraft, aircraft_faa,
-					aircraft_short, departure, arrival, alternate,
-					cruise_tas, altitude, deptime, enroute_time,
-					fuel_time, remarks, route, revision_id,
-					assigned_transponder
-				) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
-			`, pilotID, pilot.FlightPlan.FlightRules, pilot.FlightPlan.Aircraft,
-				pilot.FlightPlan.AircraftFaa, pilot.FlightPlan.AircraftShort,
-				pilot.FlightPlan.Departure, pilot.FlightPlan.Arrival,
-				pilot.FlightPlan.Alternate, pilot.FlightPlan.CruiseTAS,
-				pilot.FlightPlan.Altitude, pilot.FlightPlan.DepTime,
-				pilot.FlightPlan.EnrouteTime, pilot.FlightPlan.FuelTime,
-				pilot.FlightPlan.Remarks, pilot.FlightPlan.Route,
-				pilot.FlightPlan.RevisionID, pilot.FlightPlan.AssignedTransponder)
-			if err != nil {
-				return err
-			}
-		}
-
-		// Check for existing active connection
-		var existingConnID int64
-		err = tx.QueryRow(`
-			SELECT id FROM connections 
-			WHERE vatsim_id = $1 
-			AND callsign = $2 
-			AND type = $3 
-			AND end_time > $4
-			ORDER BY start_time DESC 
-			LIMIT 1
-		`, fmt.Sprintf("%d", pilot.CID), pilot.Callsign, api.TypePilot,
-			time.Now().Add(-5*time.Minute)).Scan(&existingConnID)
-
-		if err != nil && err != sql.ErrNoRows {
-			return err
-		}
-
-		if err == sql.ErrNoRows {
-			// No existing active connection found, create new one
-			var connID int64
-			err = tx.QueryRow(`
-				INSERT INTO connections (
-					vatsim_id, type, rating, callsign,
-					start_time, end_time, server
-				) VALUES ($1, $2, $3, $4, $5, $6, $7)
-				RETURNING id
-			`, fmt.Sprintf("%d", pilot.CID), api.TypePilot, pilot.PilotRating, pilot.Callsign,
-				pilot.LogonTime, pilot.LastUpdated, pilot.Server).Scan(&connID)
-			if err != nil {
-				return err
-			}
-
-			// Track the new connection in memory
-			c.activeConnections[key] = activeConnection{
-				cid:            fmt.Sprintf("%d", pilot.CID),
-				callsign:       pilot.Callsign,
-				connectionType: api.TypePilot,
-				rating:         pilot.PilotRating,
-				server:         pilot.Server,
-				startTime:      pilot.LogonTime,
-				lastSeen:       pilot.LastUpdated,
-				hasFlightPlan:  pilot.FlightPlan != nil,
-			}
-		} else {
-			// Update existing connection's end time
-			_, err = tx.Exec(`
-				UPDATE connections 
-				SET end_time = $1, rating = $2
-				WHERE id = $3
-			`, pilot.LastUpdated, pilot.PilotRating, existingConnID)
-			if err != nil {
-				return err
-			}
-
-			// Update active connection tracking
-			if active, exists := c.activeConnections[key]; exists {
-				active.lastSeen = pilot.LastUpdated
-				active.rating = pilot.PilotRating
-				active.hasFlightPlan = pilot.FlightPlan != nil
-				c.activeConnections[key] = active
-			} else {
-				// Restore active connection state
-				c.activeConnections[key] = activeConnection{
-					cid:            fmt.Sprintf("%d", pilot.CID),
-					callsign:       pilot.Callsign,
-					connectionType: api.TypePilot,
-					rating:         pilot.PilotRating,
-					server:         pilot.Server,
-					startTime:      pilot.LogonTime,
-					lastSeen:       pilot.LastUpdated,
-					hasFlightPlan:  pilot.FlightPlan != nil,
-				}
-			}
-		}
+	// Store pilot, flight plan and controller rows
+	if err := copyPilots(tx, snapshotID, data, c.firs); err != nil {
+		return fmt.Errorf("error storing pilots: %v", err)
 	}
-
-	// Process controllers (ATC and ATIS)
-	for _, controller := range data.Controllers {
-		key := fmt.Sprintf("%d-%s", controller.CID, controller.Callsign)
-		currentConnections[key] = true
-
-		// Store controller data
-		_, err = tx.Exec(`
-			INSERT INTO controllers (
-				snapshot_id, cid, name, callsign, frequency,
-				facility, rating, server, visual_range, text_atis,
-				last_updated, logon_time
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-		`, snapshotID, controller.CID, controller.Name, controller.Callsign,
-			controller.Frequency, controller.Facility, controller.Rating,
-			controller.Server, controller.VisualRange, pq.Array(controller.TextAtis),
-			controller.LastUpdated, controller.LogonTime)
-		if err != nil {
-			return err
-		}
-
-		// Determine if this is an ATC or ATIS position
-		var connType api.ConnectionType
-		if len(controller.TextAtis) > 0 {
-			connType = api.TypeATIS
-		} else {
-			connType = api.TypeATC
-		}
-
-		// Check for existing active connection
-		var existingConnID int64
-		err = tx.QueryRow(`
-			SELECT id FROM connections 
-			WHERE vatsim_id = $1 
-			AND callsign = $2 
-			AND type = $3 
-			AND end_time > $4
-			ORDER BY start_time DESC 
-			LIMIT 1
-		`, fmt.Sprintf("%d", controller.CID), controller.Callsign, connType,
-			time.Now().Add(-5*time.Minute)).Scan(&existingConnID)
-
-		if err != nil && err != sql.ErrNoRows {
-			return err
-		}
-
-		if err == sql.ErrNoRows {
-			// New connection
-			var connID int64
-			err = tx.QueryRow(`
-				INSERT INTO connections (
-					vatsim_id, type, rating, callsign,
-					start_time, end_time, server
-				) VALUES ($1, $2, $3, $4, $5, $6, $7)
-				RETURNING id
-			`, fmt.Sprintf("%d", controller.CID), connType, controller.Rating, controller.Callsign,
-				controller.LogonTime, controller.LastUpdated, controller.Server).Scan(&connID)
-			if err != nil {
-				return err
-			}
-
-			c.activeConnections[key] = activeConnection{
-				cid:            fmt.Sprintf("%d", controller.CID),
-				callsign:       controller.Callsign,
-				connectionType: connType,
-				rating:         controller.Rating,
-				server:         controller.Server,
-				startTime:      controller.LogonTime,
-				lastSeen:       controller.LastUpdated,
-			}
-		} else {
-			// Update existing connection's end time
-			_, err = tx.Exec(`
-				UPDATE connections 
-				SET end_time = $1, rating = $2
-				WHERE id = $3
-			`, controller.LastUpdated, controller.Rating, existingConnID)
-			if err != nil {
-				return err
-			}
-
-			// Update active connection tracking
-			if active, exists := c.activeConnections[key]; exists {
-				active.lastSeen = controller.LastUpdated
-				active.rating = controller.Rating
-				c.activeConnections[key] = active
-			} else {
-				// Restore active connection state
-				c.activeConnections[key] = activeConnection{
-					cid:            fmt.Sprintf("%d", controller.CID),
-					callsign:       controller.Callsign,
-					connectionType: connType,
-					rating:         controller.Rating,
-					server:         controller.Server,
-					startTime:      controller.LogonTime,
-					lastSeen:       controller.LastUpdated,
-				}
-			}
-		}
+	if err := copyControllers(tx, snapshotID, data); err != nil {
+		return fmt.Errorf("error storing controllers: %v", err)
 	}
 
-	// Check for disconnections
-	for key, conn := range c.activeConnections {
-		if !currentConnections[key] {
-			// Connection ended, store the stats
-			err = c.storeConnectionStats(tx, conn)
-			if err != nil {
-				return err
-			}
-			delete(c.activeConnections, key)
-		}
+	// Open, extend and close sessions for the clients in the snapshot
+	if err := c.syncConnections(tx, data); err != nil {
+		return fmt.Errorf("error updating connections: %v", err)
 	}
 
 	return tx.Commit()
 }
 
-func (c *Collector) storeConnectionStats(tx *sql.Tx, conn activeConnection) error {
-	// Insert final connection record
-	var connID int64
-	err := tx.QueryRow(`
-		INSERT INTO connections (
-			vatsim_id, type, rating, callsign,
-			start_time, end_time, server
-		) VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id
-	`, conn.cid, conn.connectionType, conn.rating, conn.callsign,
-		conn.startTime, conn.lastSeen, conn.server).Scan(&connID)
-	if err != nil {
-		return err
+// sessionSeconds returns the whole seconds a session was online
+func sessionSeconds(conn activeConnection) int64 {
+	d := conn.lastSeen.Sub(conn.startTime)
+	if d < 0 {
+		return 0
 	}
+	return int64(d / time.Second)
+}
+
+// storeConnectionStats records the statistics of a closed session against
+// its connection row. Observers have none.
+func (c *Collector) storeConnectionStats(tx *sql.Tx, conn activeConnection) error {
+	connID := conn.id
+	seconds := sessionSeconds(conn)
+	var err error
 
 	// Store type-specific stats
 	switch conn.connectionType {
 	case api.TypePilot:
-		// Calculate flight time in hours
-		flightTime := int(conn.lastSeen.Sub(conn.startTime).Minutes() / 60)
-
 		// Store individual connection stats
 		_, err = tx.Exec(`
 			INSERT INTO pilot_stats (
-				connection_id, flight_time, pilot_rating,
-				has_flight_plan
-			) VALUES ($1, $2, $3, $4)
-		`, connID, flightTime, conn.rating, conn.hasFlightPlan)
+				connection_id, flight_seconds, pilot_rating,
+				has_flight_plan, distance_nm
+			) VALUES ($1, $2, $3, $4, $5)
+		`, connID, seconds, conn.rating, conn.hasFlightPlan, conn.distanceNM)
 		if err != nil {
 			return err
 		}
@@ -467,9 +334,9 @@ func (c *Collector) storeConnectionStats(tx *sql.Tx, conn activeConnection) erro
 		// Update total stats
 		_, err = tx.Exec(`
 			INSERT INTO pilot_total_stats (
-				vatsim_id, total_hours, total_flights,
-				student_hours, ppl_hours, instrument_hours,
-				cpl_hours, atpl_hours, last_updated
+				vatsim_id, total_seconds, total_flights,
+				student_seconds, ppl_seconds, instrument_seconds,
+				cpl_seconds, atpl_seconds, total_distance_nm, last_updated
 			) VALUES (
 				$1, $2, 1,
 				CASE WHEN $3 = 1 THEN $2 ELSE 0 END,
@@ -477,18 +344,19 @@ func (c *Collector) storeConnectionStats(tx *sql.Tx, conn activeConnection) erro
 				CASE WHEN $3 = 3 THEN $2 ELSE 0 END,
 				CASE WHEN $3 = 4 THEN $2 ELSE 0 END,
 				CASE WHEN $3 = 5 THEN $2 ELSE 0 END,
-				$4
+				$5, $4
 			)
 			ON CONFLICT (vatsim_id) DO UPDATE SET
-				total_hours = pilot_total_stats.total_hours + $2,
+				total_seconds = pilot_total_stats.total_seconds + $2,
 				total_flights = pilot_total_stats.total_flights + 1,
-				student_hours = pilot_total_stats.student_hours + CASE WHEN $3 = 1 THEN $2 ELSE 0 END,
-				ppl_hours = pilot_total_stats.ppl_hours + CASE WHEN $3 = 2 THEN $2 ELSE 0 END,
-				instrument_hours = pilot_total_stats.instrument_hours + CASE WHEN $3 = 3 THEN $2 ELSE 0 END,
-				cpl_hours = pilot_total_stats.cpl_hours + CASE WHEN $3 = 4 THEN $2 ELSE 0 END,
-				atpl_hours = pilot_total_stats.atpl_hours + CASE WHEN $3 = 5 THEN $2 ELSE 0 END,
+				student_seconds = pilot_total_stats.student_seconds + CASE WHEN $3 = 1 THEN $2 ELSE 0 END,
+				ppl_seconds = pilot_total_stats.ppl_seconds + CASE WHEN $3 = 2 THEN $2 ELSE 0 END,
+				instrument_seconds = pilot_total_stats.instrument_seconds + CASE WHEN $3 = 3 THEN $2 ELSE 0 END,
+				cpl_seconds = pilot_total_stats.cpl_seconds + CASE WHEN $3 = 4 THEN $2 ELSE 0 END,
+				atpl_seconds = pilot_total_stats.atpl_seconds + CASE WHEN $3 = 5 THEN $2 ELSE 0 END,
+				total_distance_nm = pilot_total_stats.total_distance_nm + $5,
 				last_updated = $4
-		`, conn.cid, flightTime, conn.rating, conn.lastSeen)
+		`, conn.cid, seconds, conn.rating, conn.lastSeen, conn.distanceNM)
 
 	case api.TypeATC:
 		_, err = tx.Exec(`
@@ -496,21 +364,71 @@ func (c *Collector) storeConnectionStats(tx *sql.Tx, conn activeConnection) erro
 				connection_id, aircraft_tracked, aircraft_seen,
 				flights_amended, handoffs_initiated, handoffs_received,
 				handoffs_refused, squawks_assigned, cruise_alts_modified,
-				temp_alts_modified, scratchpad_mods
-			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+				temp_alts_modified, scratchpad_mods, online_seconds
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 		`, connID,
 			conn.aircraftTracked, conn.aircraftSeen,
 			conn.flightsAmended, conn.handoffsInitiated,
 			conn.handoffsReceived, conn.handoffsRefused,
 			conn.squawksAssigned, conn.cruiseAltsModified,
-			conn.tempAltsModified, conn.scratchpadMods)
+			conn.tempAltsModified, conn.scratchpadMods, seconds)
+		if err != nil {
+			return err
+		}
+
+		// Update totals by position type and by rating
+		_, err = tx.Exec(`
+			INSERT INTO controller_total_stats (
+				vatsim_id, total_seconds, sessions,
+				del_seconds, gnd_seconds, twr_seconds, app_seconds,
+				ctr_seconds, fss_seconds, other_seconds, last_updated
+			)
+			SELECT
+				$1::varchar, $2::bigint, 1,
+				CASE WHEN p = 'DEL' THEN $2::bigint ELSE 0 END,
+				CASE WHEN p = 'GND' THEN $2::bigint ELSE 0 END,
+				CASE WHEN p = 'TWR' THEN $2::bigint ELSE 0 END,
+				CASE WHEN p = 'APP' THEN $2::bigint ELSE 0 END,
+				CASE WHEN p = 'CTR' THEN $2::bigint ELSE 0 END,
+				CASE WHEN p = 'FSS' THEN $2::bigint ELSE 0 END,
+				CASE WHEN p = 'OTHER' THEN $2::bigint ELSE 0 END,
+				$4::timestamptz
+			FROM controller_position($3::text) AS p
+			ON CONFLICT (vatsim_id) DO UPDATE SET
+				total_seconds = controller_total_stats.total_seconds + EXCLUDED.total_seconds,
+				sessions = controller_total_stats.sessions + 1,
+				del_seconds = controller_total_stats.del_seconds + EXCLUDED.del_seconds,
+				gnd_seconds = controller_total_stats.gnd_seconds + EXCLUDED.gnd_seconds,
+				twr_seconds = controller_total_stats.twr_seconds + EXCLUDED.twr_seconds,
+				app_seconds = controller_total_stats.app_seconds + EXCLUDED.app_seconds,
+				ctr_seconds = controller_total_stats.ctr_seconds + EXCLUDED.ctr_seconds,
+				fss_seconds = controller_total_stats.fss_seconds + EXCLUDED.fss_seconds,
+				other_seconds = controller_total_stats.other_seconds + EXCLUDED.other_seconds,
+				last_updated = EXCLUDED.last_updated
+		`, conn.cid, seconds, conn.callsign, conn.lastSeen)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO controller_rating_stats (
+				vatsim_id, rating, total_seconds, sessions
+			) VALUES ($1, $2, $3, 1)
+			ON CONFLICT (vatsim_id, rating) DO UPDATE SET
+				total_seconds = controller_rating_stats.total_seconds + EXCLUDED.total_seconds,
+				sessions = controller_rating_stats.sessions + 1
+		`, conn.cid, conn.rating, seconds)
 
 	case api.TypeATIS:
+		var last atis.Info
+		if conn.recordedATIS != nil {
+			last = *conn.recordedATIS
+		}
 		_, err = tx.Exec(`
 			INSERT INTO atis_stats (
-				connection_id, updates
-			) VALUES ($1, $2)
-		`, connID, conn.aircraftTracked) // Using aircraftTracked as updates counter
+				connection_id, updates, frequency, letter, runways
+			) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		`, connID, conn.atisUpdates, conn.frequency, last.Letter, pq.Array(last.Runways))
 	}
 
 	return err
@@ -518,41 +436,58 @@ func (c *Collector) storeConnectionStats(tx *sql.Tx, conn activeConnection) erro
 
 // storeNetworkStats stores current network-wide statistics
 func (c *Collector) storeNetworkStats(data *types.VatsimData) error {
+	// Stats are stamped with the snapshot time so replayed archives land in the right place
+	snapshotTime := data.General.UpdateTimestamp
+
 	// Store network stats
 	_, err := db.DB.Exec(`
 		INSERT INTO network_stats (
 			timestamp, total_pilots, total_atcs, active_pilots
 		) VALUES (
-			NOW(), 
-			(SELECT COUNT(DISTINCT cid) FROM pilots WHERE last_updated > NOW() - INTERVAL '24 hours'),
-			(SELECT COUNT(DISTINCT cid) FROM controllers WHERE last_updated > NOW() - INTERVAL '24 hours'),
-			(SELECT COUNT(DISTINCT cid) FROM pilots WHERE last_updated > NOW() - INTERVAL '5 minutes')
+			$1,
+			(SELECT COUNT(DISTINCT cid) FROM pilots WHERE last_updated > $1::timestamptz - INTERVAL '24 hours'),
+			(SELECT COUNT(DISTINCT cid) FROM controllers
+				WHERE last_updated > $1::timestamptz - INTERVAL '24 hours'
+				AND facility > 0 AND UPPER(callsign) NOT LIKE '%\_ATIS' AND UPPER(callsign) NOT LIKE '%\_OBS'),
+			(SELECT COUNT(DISTINCT cid) FROM pilots WHERE last_updated > $1::timestamptz - INTERVAL '5 minutes')
 		)
-	`)
+	`, snapshotTime)
 	if err != nil {
 		return fmt.Errorf("failed to store network stats: %v", err)
 	}
 
-	// Store server stats
-	servers := make(map[string]int)
+	// Store server stats with the server's current metadata
+	counts := make(map[string]int)
 	for _, pilot := range data.Pilots {
-		servers[pilot.Server]++
+		counts[pilot.Server]++
 	}
 	for _, controller := range data.Controllers {
-		servers[controller.Server]++
+		counts[controller.Server]++
 	}
 
-	for server, count := range servers {
-		_, err = db.DB.Exec(`
-			INSERT INTO server_stats (
-				timestamp, server_name, connected_users
-			) VALUES (
-				NOW(), $1, $2
-			)
-		`, server, count)
-		if err != nil {
-			return fmt.Errorf("failed to store server stats: %v", err)
-		}
+	var names, hostnames, locations []string
+	var users []int64
+	var allowed []sql.NullBool
+	for name, count := range counts {
+		server, known := api.LookupServer(c.servers, name)
+		names = append(names, name)
+		users = append(users, int64(count))
+		hostnames = append(hostnames, server.Hostname)
+		locations = append(locations, server.Location)
+		allowed = append(allowed, sql.NullBool{Bool: server.ClientConnectionsAllowed, Valid: known})
+	}
+
+	_, err = db.DB.Exec(`
+		INSERT INTO server_stats (
+			timestamp, server_name, connected_users,
+			hostname, location, client_connections_allowed
+		)
+		SELECT $1, name, users, NULLIF(hostname, ''), NULLIF(location, ''), allowed
+		FROM unnest($2::text[], $3::integer[], $4::text[], $5::text[], $6::boolean[])
+			AS s(name, users, hostname, location, allowed)
+	`, snapshotTime, pq.Array(names), pq.Array(users), pq.Array(hostnames), pq.Array(locations), pq.Array(allowed))
+	if err != nil {
+		return fmt.Errorf("failed to store server stats: %v", err)
 	}
 
 	// Store rating stats
@@ -561,14 +496,14 @@ func (c *Collector) storeNetworkStats(data *types.VatsimData) error {
 			timestamp, rating, pilot_count, atc_count
 		)
 		SELECT 
-			NOW(),
+			$1,
 			rating,
 			COUNT(DISTINCT CASE WHEN type = 1 THEN vatsim_id END),
 			COUNT(DISTINCT CASE WHEN type = 2 THEN vatsim_id END)
 		FROM connections
-		WHERE end_time > NOW() - INTERVAL '24 hours'
+		WHERE end_time > $1::timestamptz - INTERVAL '24 hours'
 		GROUP BY rating
-	`)
+	`, snapshotTime)
 	if err != nil {
 		return fmt.Errorf("failed to store rating stats: %v", err)
 	}
@@ -579,14 +514,14 @@ func (c *Collector) storeNetworkStats(data *types.VatsimData) error {
 			timestamp, aircraft_type, count
 		)
 		SELECT 
-			NOW(),
+			$1,
 			aircraft_short,
 			COUNT(*)
 		FROM flight_plans fp
 		JOIN pilots p ON p.id = fp.pilot_id
-		WHERE p.last_updated > NOW() - INTERVAL '5 minutes'
+		WHERE p.last_updated > $1::timestamptz - INTERVAL '5 minutes'
 		GROUP BY aircraft_short
-	`)
+	`, snapshotTime)
 	if err != nil {
 		return fmt.Errorf("failed to store aircraft stats: %v", err)
 	}
@@ -595,7 +530,7 @@ func (c *Collector) storeNetworkStats(data *types.VatsimData) error {
 }
 
 // storeAirportStats stores traffic statistics for airports
-func (c *Collector) storeAirportStats() error {
+func (c *Collector) storeAirportStats(snapshotTime time.Time) error {
 	// Get current traffic for all airports
 	_, err := db.DB.Exec(`
 		INSERT INTO airport_stats (
@@ -603,7 +538,7 @@ func (c *Collector) storeAirportStats() error {
 		)
 		SELECT 
 			airport,
-			NOW(),
+			$1,
 			COUNT(*) as hourly_movements,
 			COUNT(CASE WHEN type = 'arrival' THEN 1 END) as arrival_count,
 			COUNT(CASE WHEN type = 'departure' THEN 1 END) as departure_count
@@ -613,17 +548,17 @@ func (c *Collector) storeAirportStats() error {
 				'departure' as type
 			FROM flight_plans fp
 			JOIN pilots p ON p.id = fp.pilot_id
-			WHERE p.last_updated > NOW() - INTERVAL '1 hour'
+			WHERE p.last_updated > $1::timestamptz - INTERVAL '1 hour'
 			UNION ALL
 			SELECT 
 				arrival as airport,
 				'arrival' as type
 			FROM flight_plans fp
 			JOIN pilots p ON p.id = fp.pilot_id
-			WHERE p.last_updated > NOW() - INTERVAL '1 hour'
+			WHERE p.last_updated > $1::timestamptz - INTERVAL '1 hour'
 		) movements
 		GROUP BY airport
-	`)
+	`, snapshotTime)
 	if err != nil {
 		return fmt.Errorf("failed to store airport stats: %v", err)
 	}
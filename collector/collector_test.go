@@ -0,0 +1,213 @@
+package collector
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/db"
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+// TestMain runs the tests against the fake driver, or against a real
+// PostgreSQL database when TEST_DATABASE_URL is set. The database is
+// migrated to the latest schema and must be disposable.
+func TestMain(m *testing.M) {
+	var err error
+	if url := os.Getenv("TEST_DATABASE_URL"); url != "" {
+		db.DB, err = sql.Open("postgres", url)
+		if err == nil {
+			err = db.MigrateUp()
+		}
+	} else {
+		db.DB, err = openFakeDB()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error opening test database: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Keep benchmark output readable
+	log.SetOutput(io.Discard)
+
+	os.Exit(m.Run())
+}
+
+// sequenceSource returns a new synthetic snapshot on every fetch
+type sequenceSource struct {
+	mu      sync.Mutex
+	n       int
+	clients int
+}
+
+func (s *sequenceSource) Fetch() ([]byte, error) {
+	s.mu.Lock()
+	s.n++
+	n := s.n
+	s.mu.Unlock()
+
+	return json.Marshal(syntheticSnapshot(n, s.clients))
+}
+
+func (s *sequenceSource) String() string { return "sequence" }
+
+// syntheticSnapshot builds a feed with the given number of pilots and a
+// tenth as many controllers, half of which publish an ATIS
+func syntheticSnapshot(n, clients int) *types.VatsimData {
+	updated := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC).Add(time.Duration(n) * 15 * time.Second)
+	logon := updated.Add(-time.Hour)
+
+	data := &types.VatsimData{
+		General: types.General{
+			Version:          3,
+			Update:           updated.Format("20060102150405"),
+			UpdateTimestamp:  updated,
+			ConnectedClients: clients,
+			UniqueUsers:      clients,
+		},
+	}
+
+	for i := 0; i < clients; i++ {
+		data.Pilots = append(data.Pilots, types.Pilot{
+			CID:         1000000 + i,
+			Name:        fmt.Sprintf("Pilot %d", i),
+			Callsign:    fmt.Sprintf("TST%d", i),
+			Server:      "TEST",
+			PilotRating: i % 6,
+			Latitude:    51.0 + float64(i%100)/100,
+			Longitude:   -0.5 + float64(n)/100,
+			Altitude:    35000,
+			Groundspeed: 450,
+			Transponder: "2000",
+			FlightPlan: &types.FlightPlan{
+				FlightRules:   "I",
+				Aircraft:      "B738/M-SDE2E3FGHIRWY/LB1",
+				AircraftShort: "B738",
+				Departure:     "EGLL",
+				Arrival:       "KJFK",
+				Altitude:      "35000",
+				DepTime:       "1200",
+				EnrouteTime:   "0730",
+				FuelTime:      "0900",
+			},
+			LogonTime:   logon,
+			LastUpdated: updated,
+		})
+	}
+
+	for i := 0; i < clients/10; i++ {
+		controller := types.Controller{
+			CID:         2000000 + i,
+			Name:        fmt.Sprintf("Controller %d", i),
+			Callsign:    fmt.Sprintf("TS%02d_CTR", i),
+			Frequency:   "132.600",
+			Facility:    6,
+			Rating:      5,
+			Server:      "TEST",
+			VisualRange: 300,
+			LogonTime:   logon,
+			LastUpdated: updated,
+		}
+		if i%2 == 1 {
+			controller.Callsign = fmt.Sprintf("TS%02d_ATIS", i)
+			controller.Facility = 4
+			controller.TextAtis = []string{"INFORMATION A"}
+		}
+		data.Controllers = append(data.Controllers, controller)
+	}
+
+	return data
+}
+
+func TestFetchAndStoreConcurrentWithGetStats(t *testing.T) {
+	c := NewCollector(&sequenceSource{clients: 50})
+
+	const cycles = 20
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				stats := c.GetStats()
+				if stats.TotalSnapshots > cycles {
+					t.Errorf("unexpected snapshot count %d", stats.TotalSnapshots)
+				}
+				if data, err := c.GetCurrentData(); err == nil && len(data.Pilots) != 50 {
+					t.Errorf("unexpected pilot count %d", len(data.Pilots))
+				}
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for i := 0; i < cycles; i++ {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			if err := c.FetchAndStore(); err != nil {
+				t.Errorf("FetchAndStore: %v", err)
+			}
+		}()
+	}
+	writers.Wait()
+	close(done)
+	readers.Wait()
+
+	stats := c.GetStats()
+	if stats.TotalSnapshots != cycles {
+		t.Errorf("TotalSnapshots = %d, want %d", stats.TotalSnapshots, cycles)
+	}
+	if stats.ProcessedPilots != cycles*50 {
+		t.Errorf("ProcessedPilots = %d, want %d", stats.ProcessedPilots, cycles*50)
+	}
+	if stats.ActivePilots != 50 || stats.ActiveATCs != 3 || stats.ActiveATIS != 2 {
+		t.Errorf("unexpected active counts: %+v", stats)
+	}
+}
+
+// repeatSource always returns the same snapshot
+type repeatSource struct {
+	data []byte
+}
+
+func (s *repeatSource) Fetch() ([]byte, error) { return s.data, nil }
+func (s *repeatSource) String() string         { return "repeat" }
+
+func TestFetchAndStoreSkipsUnchangedSnapshot(t *testing.T) {
+	raw, err := json.Marshal(syntheticSnapshot(1, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCollector(&repeatSource{data: raw})
+
+	for i := 0; i < 3; i++ {
+		if err := c.FetchAndStore(); err != nil {
+			t.Fatalf("FetchAndStore: %v", err)
+		}
+	}
+
+	if got := c.GetStats().TotalSnapshots; got != 1 {
+		t.Errorf("TotalSnapshots = %d, want 1", got)
+	}
+}
+
+func TestGetCurrentDataBeforeFirstSnapshot(t *testing.T) {
+	c := NewCollector(&repeatSource{})
+	if _, err := c.GetCurrentData(); err != ErrNoSnapshot {
+		t.Errorf("GetCurrentData error = %v, want ErrNoSnapshot", err)
+	}
+}
@@ -0,0 +1,22 @@
+package collector
+
+import "github.com/vainnor/vatsim-stats/geo"
+
+// maxLegSpeed is the groundspeed in knots above which the move between two
+// positions is treated as a reposition, such as a slew or a reconnect
+// elsewhere, and not counted as distance flown
+const maxLegSpeed = 2000
+
+// legDistanceNM returns the great-circle distance flown between two
+// successive positions of a pilot
+func legDistanceNM(from, to *positionSample) float64 {
+	if from == nil || to == nil || !to.time.After(from.time) {
+		return 0
+	}
+
+	distance := geo.DistanceNM(from.latitude, from.longitude, to.latitude, to.longitude)
+	if distance/to.time.Sub(from.time).Hours() > maxLegSpeed {
+		return 0
+	}
+	return distance
+}
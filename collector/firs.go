@@ -0,0 +1,31 @@
+package collector
+
+import "github.com/vainnor/vatsim-stats/types"
+
+// FIRLocator resolves a position to the ID of the FIR containing it, or ""
+// if none does
+type FIRLocator interface {
+	FIR(lat, lon float64) string
+}
+
+// SetFIRs enables attributing pilot positions to FIRs
+func (c *Collector) SetFIRs(firs FIRLocator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.firs = firs
+}
+
+// locateFIRs returns the FIR of every pilot as stored in the pilots and
+// live_positions rows: NULL outside every FIR or without boundaries
+func locateFIRs(firs FIRLocator, pilots []types.Pilot) []interface{} {
+	located := make([]interface{}, len(pilots))
+	if firs == nil {
+		return located
+	}
+	for i, pilot := range pilots {
+		if fir := firs.FIR(pilot.Latitude, pilot.Longitude); fir != "" {
+			located[i] = fir
+		}
+	}
+	return located
+}
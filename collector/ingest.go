@@ -0,0 +1,197 @@
+package collector
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+// storeReferenceData upserts facilities and ratings with one statement each
+func storeReferenceData(tx *sql.Tx, data *types.VatsimData) error {
+	var ids []int64
+	var shorts, longs []string
+	for _, facility := range data.Facilities {
+		ids = append(ids, int64(facility.ID))
+		shorts = append(shorts, facility.Short)
+		longs = append(longs, facility.Long)
+	}
+	_, err := tx.Exec(`
+		INSERT INTO facilities (id, short_name, long_name)
+		SELECT * FROM unnest($1::integer[], $2::text[], $3::text[])
+		ON CONFLICT (id) DO UPDATE
+		SET short_name = EXCLUDED.short_name, long_name = EXCLUDED.long_name
+	`, pq.Array(ids), pq.Array(shorts), pq.Array(longs))
+	if err != nil {
+		return err
+	}
+
+	ids, shorts, longs = nil, nil, nil
+	for _, rating := range data.Ratings {
+		ids = append(ids, int64(rating.ID))
+		shorts = append(shorts, rating.Short)
+		longs = append(longs, rating.Long)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO ratings (id, short_name, long_name)
+		SELECT * FROM unnest($1::integer[], $2::text[], $3::text[])
+		ON CONFLICT (id) DO UPDATE
+		SET short_name = EXCLUDED.short_name, long_name = EXCLUDED.long_name
+	`, pq.Array(ids), pq.Array(shorts), pq.Array(longs))
+	return err
+}
+
+// allocateIDs reserves n values from a sequence in a single round trip
+func allocateIDs(tx *sql.Tx, sequence string, n int) ([]int64, error) {
+	if n == 0 {
+		return nil, nil
+	}
+
+	rows, err := tx.Query(`SELECT nextval($1::regclass) FROM generate_series(1, $2)`, sequence, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make([]int64, 0, n)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) != n {
+		return nil, fmt.Errorf("allocated %d ids from %s, want %d", len(ids), sequence, n)
+	}
+
+	return ids, nil
+}
+
+// copyRows streams rows into a table with COPY
+func copyRows(tx *sql.Tx, table string, columns []string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		if _, err := stmt.Exec(row...); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+
+	// Flush the buffered rows
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+
+	return stmt.Close()
+}
+
+// copyPilots stores the snapshot's pilots, located in their FIRs, and their
+// flight plans, and replaces the live positions with theirs. Pilot ids are
+// allocated up front so flight plans can reference them without RETURNING
+// a row per pilot.
+func copyPilots(tx *sql.Tx, snapshotID int, data *types.VatsimData, firs FIRLocator) error {
+	ids, err := allocateIDs(tx, "pilots_id_seq", len(data.Pilots))
+	if err != nil {
+		return err
+	}
+
+	snapshotTime := data.General.UpdateTimestamp
+	located := locateFIRs(firs, data.Pilots)
+	pilotRows := make([][]interface{}, 0, len(data.Pilots))
+	var planRows [][]interface{}
+
+	for i, pilot := range data.Pilots {
+		pilotRows = append(pilotRows, []interface{}{
+			ids[i], snapshotID, snapshotTime, pilot.CID, pilot.Name, pilot.Callsign, pilot.Server,
+			pilot.PilotRating, pilot.MilitaryRating, pilot.Latitude, pilot.Longitude,
+			pilot.Altitude, pilot.Groundspeed, pilot.Transponder, pilot.Heading,
+			pilot.QNHiHg, pilot.QNHMb, pilot.LogonTime, pilot.LastUpdated, located[i],
+		})
+
+		if fp := pilot.FlightPlan; fp != nil {
+			planRows = append(planRows, []interface{}{
+				ids[i], snapshotTime, fp.FlightRules, fp.Aircraft, fp.AircraftFaa,
+				fp.AircraftShort, fp.Departure, fp.Arrival, fp.Alternate,
+				fp.CruiseTAS, fp.Altitude, fp.DepTime, fp.EnrouteTime,
+				fp.FuelTime, fp.Remarks, fp.Route, fp.RevisionID,
+				fp.AssignedTransponder,
+			})
+		}
+	}
+
+	err = copyRows(tx, "pilots", []string{
+		"id", "snapshot_id", "snapshot_time", "cid", "name", "callsign", "server",
+		"pilot_rating", "military_rating", "latitude", "longitude",
+		"altitude", "groundspeed", "transponder", "heading",
+		"qnh_i_hg", "qnh_mb", "logon_time", "last_updated", "fir",
+	}, pilotRows)
+	if err != nil {
+		return err
+	}
+
+	err = copyRows(tx, "flight_plans", []string{
+		"pilot_id", "snapshot_time", "flight_rules", "aircraft", "aircraft_faa",
+		"aircraft_short", "departure", "arrival", "alternate",
+		"cruise_tas", "altitude", "deptime", "enroute_time",
+		"fuel_time", "remarks", "route", "revision_id",
+		"assigned_transponder",
+	}, planRows)
+	if err != nil {
+		return err
+	}
+
+	return replaceLivePositions(tx, ids, located, data)
+}
+
+// replaceLivePositions swaps the live positions for the snapshot's pilots
+func replaceLivePositions(tx *sql.Tx, ids []int64, located []interface{}, data *types.VatsimData) error {
+	if _, err := tx.Exec(`DELETE FROM live_positions`); err != nil {
+		return err
+	}
+
+	rows := make([][]interface{}, 0, len(data.Pilots))
+	for i, pilot := range data.Pilots {
+		rows = append(rows, []interface{}{
+			ids[i], data.General.UpdateTimestamp,
+			fmt.Sprintf("(%f,%f)", pilot.Longitude, pilot.Latitude),
+			pilot.Altitude, pilot.Groundspeed, located[i],
+		})
+	}
+
+	return copyRows(tx, "live_positions", []string{
+		"pilot_id", "snapshot_time", "position", "altitude", "groundspeed", "fir",
+	}, rows)
+}
+
+// copyControllers stores the snapshot's controllers
+func copyControllers(tx *sql.Tx, snapshotID int, data *types.VatsimData) error {
+	rows := make([][]interface{}, 0, len(data.Controllers))
+	for _, controller := range data.Controllers {
+		rows = append(rows, []interface{}{
+			snapshotID, data.General.UpdateTimestamp, controller.CID, controller.Name, controller.Callsign,
+			controller.Frequency, controller.Facility, controller.Rating,
+			controller.Server, controller.VisualRange, pq.Array(controller.TextAtis),
+			controller.LastUpdated, controller.LogonTime,
+		})
+	}
+
+	return copyRows(tx, "controllers", []string{
+		"snapshot_id", "snapshot_time", "cid", "name", "callsign",
+		"frequency", "facility", "rating", "server", "visual_range", "text_atis",
+		"last_updated", "logon_time",
+	}, rows)
+}
@@ -0,0 +1,63 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+func TestStoreDataRoundTripsIndependentOfClients(t *testing.T) {
+	if _, ok := db.DB.Driver().(*fakeDriver); !ok {
+		t.Skip("round trips are only counted by the fake driver")
+	}
+
+	roundTrips := func(clients int) int64 {
+		c := NewCollector(&repeatSource{})
+		before := fakeDB.roundTrips.Load()
+		if err := c.storeData(syntheticSnapshot(1, clients)); err != nil {
+			t.Fatalf("storeData: %v", err)
+		}
+		return fakeDB.roundTrips.Load() - before
+	}
+
+	// 20 clients is the smallest snapshot with both a controller and an ATIS
+	small, large := roundTrips(20), roundTrips(2000)
+	if small != large {
+		t.Errorf("storeData used %d round trips for 20 clients and %d for 2000", small, large)
+	}
+}
+
+// BenchmarkStoreData measures ingestion of one snapshot with 2,000 pilots
+// and 200 controllers. Set TEST_DATABASE_URL to measure against PostgreSQL.
+func BenchmarkStoreData(b *testing.B) {
+	const clients = 2000
+
+	c := NewCollector(&repeatSource{})
+	var roundTrips int64
+	var partitionDay time.Time
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		snapshot := syntheticSnapshot(i+1, clients)
+		if day := snapshot.General.UpdateTimestamp.Truncate(24 * time.Hour); !day.Equal(partitionDay) {
+			if err := db.EnsureSnapshotPartitions(day); err != nil {
+				b.Fatal(err)
+			}
+			partitionDay = day
+		}
+		before := fakeDB.roundTrips.Load()
+		b.StartTimer()
+
+		if err := c.storeData(snapshot); err != nil {
+			b.Fatal(err)
+		}
+
+		roundTrips += fakeDB.roundTrips.Load() - before
+	}
+
+	if _, ok := db.DB.Driver().(*fakeDriver); ok {
+		b.ReportMetric(float64(roundTrips)/float64(b.N), "roundtrips/op")
+	}
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"log"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// SetLeaderboardRefresh enables refreshing the leaderboard rankings in the
+// background, at most once per interval of snapshot time
+func (c *Collector) SetLeaderboardRefresh(interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.leaderboardInterval = interval
+}
+
+// refreshLeaderboards starts a leaderboard refresh if the interval has
+// passed since the last one and none is still running. It does not wait,
+// so a slow refresh never delays collection.
+func (c *Collector) refreshLeaderboards(now time.Time) {
+	if c.leaderboardInterval <= 0 || now.Sub(c.leaderboardsRefreshed) < c.leaderboardInterval {
+		return
+	}
+	if !c.refreshingLeaderboards.CompareAndSwap(false, true) {
+		return
+	}
+	c.leaderboardsRefreshed = now
+
+	go func() {
+		defer c.refreshingLeaderboards.Store(false)
+
+		start := time.Now()
+		if err := db.RefreshLeaderboards(); err != nil {
+			log.Printf("Error refreshing leaderboards: %v", err)
+			return
+		}
+		log.Printf("Refreshed leaderboards in %v", time.Since(start).Round(time.Millisecond))
+	}()
+}
@@ -0,0 +1,425 @@
+package collector
+
+import (
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/api"
+	"github.com/vainnor/vatsim-stats/geo"
+)
+
+// Thresholds used to classify flight phases
+const (
+	// Groundspeed in knots above which an aircraft is taxiing
+	taxiSpeed = 5
+	// Groundspeed in knots separating ground movement from takeoff and
+	// flight: faster than any taxi, slower than any liftoff
+	airborneSpeed = 40
+	// Height in feet above the departure field at which takeoff becomes climb
+	liftoffHeight = 1000
+	// Height in feet above the departure field below which level flight is
+	// not counted as cruise
+	cruiseHeight = 3000
+	// Vertical rates in feet per minute
+	climbRate   = 500
+	descentRate = -500
+	levelRate   = 300
+	// Distance in nautical miles and height in feet from the destination
+	// within which a descending aircraft is on approach
+	approachRange  = 20
+	approachHeight = 5000
+	// Height in feet above the destination at which an aircraft on
+	// approach has touched down
+	touchdownHeight = 50
+)
+
+// AirportLocator resolves an airport code to its position and elevation in
+// feet
+type AirportLocator interface {
+	Locate(icao string) (lat, lon float64, elevation int, ok bool)
+}
+
+// SetAirports enables airport proximity in flight phase detection
+func (c *Collector) SetAirports(airports AirportLocator) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.airports = airports
+}
+
+// positionSample is a pilot's reported position at one point in time
+type positionSample struct {
+	time        time.Time
+	latitude    float64
+	longitude   float64
+	altitude    int
+	groundspeed int
+}
+
+// airportPosition is the location of a flight's destination
+type airportPosition struct {
+	latitude  float64
+	longitude float64
+	elevation int
+}
+
+// filedPlan holds the flight plan fields recorded per flight
+type filedPlan struct {
+	departure   string
+	arrival     string
+	depTime     string
+	enrouteTime string
+}
+
+// flightState tracks the phase of one pilot session across snapshots
+type flightState struct {
+	phase api.FlightPhase
+	last  *positionSample
+	// Altitude at which the aircraft was last seen on the ground
+	fieldElevation int
+	fieldKnown     bool
+	// Whether the aircraft has taken off and not landed since
+	airborne bool
+	// Movement events detected since they were last collected
+	events flightEvents
+	// Flight plan as last recorded for the flight
+	recorded *filedPlan
+}
+
+// flightEvents are the actual movement times of a flight. Zero times were
+// not observed.
+type flightEvents struct {
+	offBlock time.Time
+	takeoff  time.Time
+	landing  time.Time
+	onBlock  time.Time
+}
+
+func (e flightEvents) empty() bool {
+	return e.offBlock.IsZero() && e.takeoff.IsZero() && e.landing.IsZero() && e.onBlock.IsZero()
+}
+
+// flightRecord is the per-flight row of a session to be stored
+type flightRecord struct {
+	connectionID int64
+	filed        filedPlan
+	events       flightEvents
+}
+
+// phaseTransition is a change of phase to be recorded for a session
+type phaseTransition struct {
+	connectionID int64
+	phase        api.FlightPhase
+	sample       positionSample
+}
+
+// observe classifies a new position and reports whether the phase changed.
+// Positions that are not newer than the previous one are ignored.
+func (f *flightState) observe(s positionSample, dest *airportPosition) bool {
+	prev := f.last
+	if prev != nil && !s.time.After(prev.time) {
+		return false
+	}
+	f.last = &s
+
+	rate, hasRate := 0.0, false
+	if prev != nil {
+		rate = float64(s.altitude-prev.altitude) / s.time.Sub(prev.time).Minutes()
+		hasRate = true
+	}
+
+	next := f.next(s, rate, hasRate, dest)
+	f.detectEvents(prev, s, next)
+
+	if s.groundspeed < airborneSpeed {
+		f.fieldElevation, f.fieldKnown = s.altitude, true
+	}
+
+	if next == f.phase {
+		return false
+	}
+	f.phase = next
+	return true
+}
+
+// next returns the phase for a position given the current phase and the
+// vertical rate since the previous position, if there was one
+func (f *flightState) next(s positionSample, rate float64, hasRate bool, dest *airportPosition) api.FlightPhase {
+	slow := s.groundspeed < airborneSpeed
+
+	switch f.phase {
+	case "":
+		// First sighting, possibly already in flight
+		switch {
+		case s.groundspeed < taxiSpeed:
+			return api.PhasePreflight
+		case slow:
+			return api.PhaseTaxiOut
+		case nearDestination(s, dest):
+			return api.PhaseApproach
+		default:
+			return api.PhaseCruise
+		}
+
+	case api.PhasePreflight, api.PhaseTaxiOut:
+		switch {
+		case !slow:
+			return api.PhaseTakeoff
+		case s.groundspeed >= taxiSpeed:
+			return api.PhaseTaxiOut
+		default:
+			return f.phase
+		}
+
+	case api.PhaseTakeoff:
+		switch {
+		case slow:
+			// Rejected takeoff
+			return api.PhaseTaxiOut
+		case !f.fieldKnown || s.altitude-f.fieldElevation >= liftoffHeight:
+			return api.PhaseClimb
+		default:
+			return api.PhaseTakeoff
+		}
+
+	case api.PhaseClimb, api.PhaseCruise, api.PhaseDescent, api.PhaseApproach:
+		if slow || (f.phase == api.PhaseApproach && dest != nil && s.altitude-dest.elevation <= touchdownHeight) {
+			return api.PhaseLanded
+		}
+		if !hasRate {
+			return f.phase
+		}
+
+		switch {
+		case rate >= climbRate:
+			// Includes go-arounds and step climbs
+			return api.PhaseClimb
+		case f.phase == api.PhaseApproach:
+			return api.PhaseApproach
+		case rate < levelRate && nearDestination(s, dest):
+			return api.PhaseApproach
+		case rate <= descentRate:
+			if dest == nil && f.phase == api.PhaseDescent && s.altitude-f.fieldElevation <= approachHeight {
+				// Without a known destination, a low descent is an approach
+				return api.PhaseApproach
+			}
+			return api.PhaseDescent
+		case math.Abs(rate) < levelRate && f.phase == api.PhaseClimb &&
+			(!f.fieldKnown || s.altitude-f.fieldElevation >= cruiseHeight):
+			return api.PhaseCruise
+		default:
+			return f.phase
+		}
+
+	case api.PhaseLanded, api.PhaseTaxiIn:
+		switch {
+		case !slow:
+			// Touch and go, or departing again
+			return api.PhaseTakeoff
+		case s.groundspeed >= taxiSpeed:
+			return api.PhaseTaxiIn
+		default:
+			return f.phase
+		}
+	}
+
+	return f.phase
+}
+
+// airbornePhase reports whether a phase is flown off the ground
+func airbornePhase(phase api.FlightPhase) bool {
+	switch phase {
+	case api.PhaseClimb, api.PhaseCruise, api.PhaseDescent, api.PhaseApproach:
+		return true
+	}
+	return false
+}
+
+// detectEvents records the movement events implied by moving to the next
+// phase at position s. It runs before the field elevation is updated.
+func (f *flightState) detectEvents(prev *positionSample, s positionSample, next api.FlightPhase) {
+	switch {
+	case f.phase == "":
+		// First seen in flight, the takeoff was not observed
+		f.airborne = airbornePhase(next)
+
+	case f.phase == api.PhasePreflight && (next == api.PhaseTaxiOut || next == api.PhaseTakeoff):
+		f.events.offBlock = s.time
+	}
+
+	// Wheels off once clear of the field, or when climbing if the field
+	// elevation is unknown
+	if !f.airborne && (next == api.PhaseClimb ||
+		(next == api.PhaseTakeoff && f.fieldKnown && s.altitude-f.fieldElevation > touchdownHeight)) {
+		f.airborne = true
+		f.events.takeoff = s.time
+	}
+
+	if f.airborne && next == api.PhaseLanded {
+		f.airborne = false
+		f.events.landing = s.time
+	}
+
+	// On block when coming to a stop after landing. The last stop wins.
+	if (next == api.PhaseLanded || next == api.PhaseTaxiIn) && s.groundspeed < taxiSpeed &&
+		prev != nil && (prev.groundspeed >= taxiSpeed || f.phase != next) {
+		f.events.onBlock = s.time
+	}
+}
+
+// nearDestination reports whether a position is within approach range of
+// the destination
+func nearDestination(s positionSample, dest *airportPosition) bool {
+	if dest == nil {
+		return false
+	}
+	return s.altitude-dest.elevation <= approachHeight &&
+		geo.DistanceNM(s.latitude, s.longitude, dest.latitude, dest.longitude) <= approachRange
+}
+
+// detectPhases advances the flight phase of every pilot session that was
+// present in the snapshot. It returns the phase changes and the flights
+// whose plan or movement times need to be stored.
+func (c *Collector) detectPhases(sessions []*activeConnection) ([]phaseTransition, []flightRecord) {
+	var transitions []phaseTransition
+	var flights []flightRecord
+
+	for _, session := range sessions {
+		if session.connectionType != api.TypePilot || session.position == nil {
+			continue
+		}
+		if session.flight == nil {
+			session.flight = &flightState{}
+		}
+
+		var dest *airportPosition
+		if c.airports != nil && session.filed.arrival != "" {
+			if lat, lon, elevation, ok := c.airports.Locate(session.filed.arrival); ok {
+				dest = &airportPosition{latitude: lat, longitude: lon, elevation: elevation}
+			}
+		}
+
+		if session.flight.observe(*session.position, dest) {
+			transitions = append(transitions, phaseTransition{
+				connectionID: session.id,
+				phase:        session.flight.phase,
+				sample:       *session.position,
+			})
+		}
+
+		flight := session.flight
+		if !flight.events.empty() || flight.recorded == nil || *flight.recorded != session.filed {
+			flights = append(flights, flightRecord{
+				connectionID: session.id,
+				filed:        session.filed,
+				events:       flight.events,
+			})
+			filed := session.filed
+			flight.recorded = &filed
+			flight.events = flightEvents{}
+		}
+	}
+
+	return transitions, flights
+}
+
+// insertPhaseTransitions records phase changes in a single statement
+func insertPhaseTransitions(tx *sql.Tx, transitions []phaseTransition) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+
+	var (
+		ids          []int64
+		phases       []string
+		times        []string
+		latitudes    []float64
+		longitudes   []float64
+		altitudes    []int64
+		groundspeeds []int64
+	)
+	for _, t := range transitions {
+		ids = append(ids, t.connectionID)
+		phases = append(phases, string(t.phase))
+		times = append(times, t.sample.time.Format(time.RFC3339Nano))
+		latitudes = append(latitudes, t.sample.latitude)
+		longitudes = append(longitudes, t.sample.longitude)
+		altitudes = append(altitudes, int64(t.sample.altitude))
+		groundspeeds = append(groundspeeds, int64(t.sample.groundspeed))
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO flight_phases (
+			connection_id, phase, started_at,
+			latitude, longitude, altitude, groundspeed
+		)
+		SELECT * FROM unnest(
+			$1::bigint[], $2::varchar[], $3::timestamptz[],
+			$4::double precision[], $5::double precision[], $6::integer[], $7::integer[]
+		)
+	`, pq.Array(ids), pq.Array(phases), pq.Array(times),
+		pq.Array(latitudes), pq.Array(longitudes), pq.Array(altitudes), pq.Array(groundspeeds))
+	return err
+}
+
+// upsertFlights stores the filed plan and actual movement times of flights
+// in a single statement. The first off-block and takeoff and the last
+// landing and on-block of a session are kept.
+func upsertFlights(tx *sql.Tx, flights []flightRecord) error {
+	if len(flights) == 0 {
+		return nil
+	}
+
+	var (
+		ids                                     []int64
+		departures, arrivals                    []string
+		depTimes, enrouteTimes                  []string
+		offBlocks, takeoffs, landings, onBlocks []string
+	)
+	for _, f := range flights {
+		ids = append(ids, f.connectionID)
+		departures = append(departures, f.filed.departure)
+		arrivals = append(arrivals, f.filed.arrival)
+		depTimes = append(depTimes, f.filed.depTime)
+		enrouteTimes = append(enrouteTimes, f.filed.enrouteTime)
+		offBlocks = append(offBlocks, eventTime(f.events.offBlock))
+		takeoffs = append(takeoffs, eventTime(f.events.takeoff))
+		landings = append(landings, eventTime(f.events.landing))
+		onBlocks = append(onBlocks, eventTime(f.events.onBlock))
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO flights (
+			connection_id, departure, arrival, filed_deptime, filed_enroute_time,
+			off_block_time, takeoff_time, landing_time, on_block_time
+		)
+		SELECT
+			id, NULLIF(dep, ''), NULLIF(arr, ''), NULLIF(deptime, ''), NULLIF(enroute, ''),
+			NULLIF(off_block, '')::timestamptz, NULLIF(takeoff, '')::timestamptz,
+			NULLIF(landing, '')::timestamptz, NULLIF(on_block, '')::timestamptz
+		FROM unnest(
+			$1::bigint[], $2::varchar[], $3::varchar[], $4::varchar[], $5::varchar[],
+			$6::text[], $7::text[], $8::text[], $9::text[]
+		) AS u(id, dep, arr, deptime, enroute, off_block, takeoff, landing, on_block)
+		ON CONFLICT (connection_id) DO UPDATE SET
+			departure = COALESCE(EXCLUDED.departure, flights.departure),
+			arrival = COALESCE(EXCLUDED.arrival, flights.arrival),
+			filed_deptime = COALESCE(EXCLUDED.filed_deptime, flights.filed_deptime),
+			filed_enroute_time = COALESCE(EXCLUDED.filed_enroute_time, flights.filed_enroute_time),
+			off_block_time = COALESCE(flights.off_block_time, EXCLUDED.off_block_time),
+			takeoff_time = COALESCE(flights.takeoff_time, EXCLUDED.takeoff_time),
+			landing_time = COALESCE(EXCLUDED.landing_time, flights.landing_time),
+			on_block_time = COALESCE(EXCLUDED.on_block_time, flights.on_block_time)
+	`, pq.Array(ids), pq.Array(departures), pq.Array(arrivals), pq.Array(depTimes), pq.Array(enrouteTimes),
+		pq.Array(offBlocks), pq.Array(takeoffs), pq.Array(landings), pq.Array(onBlocks))
+	return err
+}
+
+// eventTime formats an event time for storage, empty if not observed
+func eventTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,128 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/api"
+)
+
+// flightProfile is a sequence of (altitude, groundspeed) reports one
+// minute apart, moving east along the equator at the given groundspeed
+func flightProfile(points [][2]int) []positionSample {
+	var samples []positionSample
+	lon := 0.0
+	for i, p := range points {
+		lon += float64(p[1]) / 60 / 60
+		samples = append(samples, positionSample{
+			time:        sessionEpoch.Add(time.Duration(i) * time.Minute),
+			longitude:   lon,
+			altitude:    p[0],
+			groundspeed: p[1],
+		})
+	}
+	return samples
+}
+
+func phasesOf(samples []positionSample, dest *airportPosition) []api.FlightPhase {
+	var f flightState
+	var phases []api.FlightPhase
+	for _, s := range samples {
+		if f.observe(s, dest) {
+			phases = append(phases, f.phase)
+		}
+	}
+	return phases
+}
+
+var fullFlight = [][2]int{
+	{500, 0}, {500, 0}, // parked
+	{500, 15}, {500, 20}, // taxi
+	{520, 140},                            // takeoff roll and liftoff
+	{2500, 180}, {5000, 250}, {9000, 280}, // climb
+	{12000, 300}, {12050, 310}, {12000, 310}, // level
+	{9000, 300}, {6000, 280}, {3000, 220}, // descent
+	{1500, 160}, {600, 140}, // approach
+	{100, 30}, {100, 15}, {100, 0}, // rollout and taxi in
+}
+
+func TestFlightPhasesWithoutDestination(t *testing.T) {
+	got := phasesOf(flightProfile(fullFlight), nil)
+	want := []api.FlightPhase{
+		api.PhasePreflight, api.PhaseTaxiOut, api.PhaseTakeoff, api.PhaseClimb,
+		api.PhaseCruise, api.PhaseDescent, api.PhaseApproach, api.PhaseLanded,
+		api.PhaseTaxiIn,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("phases = %v, want %v", got, want)
+	}
+}
+
+func TestFlightPhasesNearDestination(t *testing.T) {
+	samples := flightProfile(fullFlight)
+	last := samples[len(samples)-1]
+	dest := &airportPosition{latitude: 0, longitude: last.longitude, elevation: 100}
+
+	got := phasesOf(samples, dest)
+	if got[len(got)-3] != api.PhaseApproach || got[len(got)-2] != api.PhaseLanded {
+		t.Errorf("expected approach then landed near the destination, got %v", got)
+	}
+}
+
+func TestFlightPhasesGoAround(t *testing.T) {
+	got := phasesOf(flightProfile([][2]int{
+		{20000, 400}, {15000, 350}, {6000, 250}, {4000, 180}, {2000, 150}, {3500, 170},
+	}), nil)
+	want := []api.FlightPhase{
+		api.PhaseCruise, api.PhaseDescent, api.PhaseApproach, api.PhaseClimb,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("phases = %v, want %v", got, want)
+	}
+}
+
+func TestFlightPhasesIgnoreStalePositions(t *testing.T) {
+	var f flightState
+	s := positionSample{time: sessionEpoch, altitude: 500}
+	if !f.observe(s, nil) {
+		t.Fatal("first position should set a phase")
+	}
+	s.groundspeed = 20
+	if f.observe(s, nil) {
+		t.Error("a position with the same timestamp should be ignored")
+	}
+}
+
+func TestFlightEvents(t *testing.T) {
+	samples := flightProfile(fullFlight)
+
+	var f flightState
+	for _, s := range samples {
+		f.observe(s, nil)
+	}
+
+	minute := func(i int) time.Time { return sessionEpoch.Add(time.Duration(i) * time.Minute) }
+	want := flightEvents{
+		offBlock: minute(2),
+		takeoff:  minute(5),
+		landing:  minute(16),
+		onBlock:  minute(18),
+	}
+	if f.events != want {
+		t.Errorf("events = %+v, want %+v", f.events, want)
+	}
+	if f.airborne {
+		t.Error("aircraft should be on the ground after landing")
+	}
+}
+
+func TestFlightEventsFirstSeenInFlight(t *testing.T) {
+	var f flightState
+	for _, s := range flightProfile([][2]int{{35000, 450}, {35000, 450}, {30000, 420}}) {
+		f.observe(s, nil)
+	}
+	if !f.airborne || !f.events.empty() {
+		t.Errorf("a flight joined airborne has no observed events: %+v", f)
+	}
+}
@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/db"
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+const vatsimServersURL = "https://data.vatsim.net/v3/vatsim-servers.json"
+
+// serverRefreshInterval is how often the server list is fetched again
+const serverRefreshInterval = time.Hour
+
+// NewServerSource creates the source of the server list from a
+// configuration string: an http:// or https:// URL, file:<path> or a bare
+// path. An empty string selects the live VATSIM server list, and "off"
+// returns nil so only the servers in the data feed are known.
+func NewServerSource(spec string) (DataSource, error) {
+	switch {
+	case spec == "":
+		return NewHTTPSource(vatsimServersURL), nil
+	case spec == "off":
+		return nil, nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return NewHTTPSource(spec), nil
+	case strings.HasPrefix(spec, "file:"):
+		return NewFileSource(strings.TrimPrefix(spec, "file:")), nil
+	}
+
+	if _, err := os.Stat(spec); err != nil {
+		return nil, fmt.Errorf("unknown server list %q: %v", spec, err)
+	}
+	return NewFileSource(spec), nil
+}
+
+// SetServerSource sets where the server list is read from, nil to know
+// only the servers listed in the data feed
+func (c *Collector) SetServerSource(source DataSource) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serverSource = source
+	c.serversFetched = time.Time{}
+}
+
+// updateServers fetches the server list when it is due and merges in the
+// servers of the data feed, writing any change to the servers table. A
+// failed fetch keeps the previous list.
+func (c *Collector) updateServers(data *types.VatsimData, now time.Time) error {
+	var fetchErr error
+	if c.serverSource != nil && now.Sub(c.serversFetched) >= serverRefreshInterval {
+		c.serversFetched = now
+		listed, err := fetchServerList(c.serverSource)
+		if err != nil {
+			fetchErr = fmt.Errorf("error fetching server list from %s: %v", c.serverSource, err)
+		} else {
+			c.listedServers = listed
+		}
+	}
+
+	servers := mergeServers(c.listedServers, data.Servers)
+	if maps.Equal(servers, c.servers) {
+		return fetchErr
+	}
+	if err := storeServers(servers, now); err != nil {
+		return err
+	}
+	c.currentMu.Lock()
+	c.servers = servers
+	c.currentMu.Unlock()
+	return fetchErr
+}
+
+// fetchServerList reads a vatsim-servers.json style list
+func fetchServerList(source DataSource) ([]types.Server, error) {
+	raw, err := source.Fetch()
+	if err != nil {
+		return nil, err
+	}
+	var servers []types.Server
+	if err := json.Unmarshal(raw, &servers); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// mergeServers keys servers by ident, preferring the server list's entry
+// over the data feed's
+func mergeServers(listed, feed []types.Server) map[string]types.Server {
+	servers := make(map[string]types.Server, len(listed)+len(feed))
+	for _, list := range [][]types.Server{feed, listed} {
+		for _, server := range list {
+			if server.Ident != "" {
+				servers[server.Ident] = server
+			}
+		}
+	}
+	return servers
+}
+
+// storeServers upserts server metadata with one statement
+func storeServers(servers map[string]types.Server, now time.Time) error {
+	var idents, names, hostnames, locations []string
+	var allowed, sweatbox []bool
+	for _, server := range servers {
+		name := server.Name
+		if name == "" {
+			name = server.Ident
+		}
+		idents = append(idents, server.Ident)
+		names = append(names, name)
+		hostnames = append(hostnames, server.Hostname)
+		locations = append(locations, server.Location)
+		allowed = append(allowed, server.ClientConnectionsAllowed)
+		sweatbox = append(sweatbox, server.IsSweatbox)
+	}
+
+	_, err := db.DB.Exec(`
+		INSERT INTO servers (
+			ident, name, hostname, location,
+			client_connections_allowed, is_sweatbox, updated_at
+		)
+		SELECT ident, name, NULLIF(hostname, ''), NULLIF(location, ''), allowed, sweatbox, $7
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::boolean[], $6::boolean[])
+			AS s(ident, name, hostname, location, allowed, sweatbox)
+		ON CONFLICT (ident) DO UPDATE
+		SET name = EXCLUDED.name,
+			hostname = EXCLUDED.hostname,
+			location = EXCLUDED.location,
+			client_connections_allowed = EXCLUDED.client_connections_allowed,
+			is_sweatbox = EXCLUDED.is_sweatbox,
+			updated_at = EXCLUDED.updated_at
+	`, pq.Array(idents), pq.Array(names), pq.Array(hostnames), pq.Array(locations),
+		pq.Array(allowed), pq.Array(sweatbox), now)
+	if err != nil {
+		return fmt.Errorf("error storing servers: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/api"
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+const sampleServerList = `[
+  {"ident": "USA-EAST", "hostname_or_ip": "usa-east.vatsim.net", "location": "New York, USA", "name": "USA-EAST", "clients_connection_allowed": 1, "client_connections_allowed": true, "is_sweatbox": false},
+  {"ident": "SWEATBOX", "hostname_or_ip": "sweatbox.vatsim.net", "location": "Sweatbox", "name": "SWEATBOX", "clients_connection_allowed": 1, "client_connections_allowed": true, "is_sweatbox": true}
+]`
+
+func TestServerList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vatsim-servers.json")
+	if err := os.WriteFile(path, []byte(sampleServerList), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := NewServerSource(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed, err := fetchServerList(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 2 || listed[0].Location != "New York, USA" || !listed[0].ClientConnectionsAllowed || !listed[1].IsSweatbox {
+		t.Fatalf("unexpected server list %+v", listed)
+	}
+
+	// The list's entry wins over the feed's, and feed-only servers are kept
+	servers := mergeServers(listed, []types.Server{
+		{Ident: "USA-EAST", Name: "USA-EAST"},
+		{Ident: "GERMANY", Name: "Germany", Location: "Frankfurt"},
+	})
+	if len(servers) != 3 || servers["USA-EAST"].Hostname != "usa-east.vatsim.net" {
+		t.Errorf("unexpected merged servers %+v", servers)
+	}
+	if server, ok := api.LookupServer(servers, "germany"); !ok || server.Location != "Frankfurt" {
+		t.Errorf("lookup by name failed: %+v, %v", server, ok)
+	}
+	if _, ok := api.LookupServer(servers, "UK-1"); ok {
+		t.Error("unknown servers should not be found")
+	}
+
+	if source, err := NewServerSource("off"); err != nil || source != nil {
+		t.Errorf("off should disable the server list, got %v, %v", source, err)
+	}
+}
+
+func TestUpdateServersPublishesServers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vatsim-servers.json")
+	if err := os.WriteFile(path, []byte(sampleServerList), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollector(nil)
+	c.SetServerSource(NewFileSource(path))
+	if servers := c.GetServers(); len(servers) != 0 {
+		t.Fatalf("no servers should be known before the first snapshot, got %+v", servers)
+	}
+
+	data := &types.VatsimData{Servers: []types.Server{{Ident: "GERMANY", Name: "Germany"}}}
+	if err := c.updateServers(data, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	servers := c.GetServers()
+	if len(servers) != 3 || servers["GERMANY"].Name != "Germany" || !servers["SWEATBOX"].IsSweatbox {
+		t.Errorf("unexpected servers %+v", servers)
+	}
+}
@@ -0,0 +1,384 @@
+package collector
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/api"
+	"github.com/vainnor/vatsim-stats/atis"
+	"github.com/vainnor/vatsim-stats/facilities"
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+// defaultGracePeriod is how long a client may be missing from the feed
+// before its session is closed. Reappearing within it continues the session.
+const defaultGracePeriod = 2 * time.Minute
+
+// sessionTracker holds the open sessions and applies snapshots to them.
+//
+// A session is opened when a client first appears, updated every snapshot
+// it is present in, enters a grace period when it goes missing and is
+// closed once it has been missing for longer than the grace period. A
+// client that reappears during the grace period continues its session,
+// even after reconnecting to the network.
+type sessionTracker struct {
+	gracePeriod time.Duration
+	sessions    map[string]*activeConnection
+}
+
+// sessionChanges are the transitions caused by one snapshot
+type sessionChanges struct {
+	opened  []*activeConnection
+	updated []*activeConnection
+	closed  []*activeConnection
+}
+
+func newSessionTracker(gracePeriod time.Duration) *sessionTracker {
+	return &sessionTracker{
+		gracePeriod: gracePeriod,
+		sessions:    make(map[string]*activeConnection),
+	}
+}
+
+// FacilityClassifier assigns controller sessions to facilities
+type FacilityClassifier interface {
+	Classify(callsign, frequency string) string
+}
+
+// SetFacilities classifies new controller sessions with a facility
+// registry instead of by callsign prefix
+func (c *Collector) SetFacilities(facilities FacilityClassifier) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.facilities = facilities
+}
+
+// classifyFacility returns the facility of a controller session
+func (c *Collector) classifyFacility(session *activeConnection) string {
+	if c.facilities == nil {
+		return facilities.Prefix(session.callsign)
+	}
+	return c.facilities.Classify(session.callsign, session.frequency)
+}
+
+// sessionKey identifies a session by client, callsign and connection type
+func sessionKey(cid, callsign string, connType api.ConnectionType) string {
+	return fmt.Sprintf("%s-%s-%d", cid, callsign, connType)
+}
+
+func (s *activeConnection) key() string {
+	return sessionKey(s.cid, s.callsign, s.connectionType)
+}
+
+// apply moves every session through its lifecycle for a snapshot taken at
+// now, given the connections present in it
+func (t *sessionTracker) apply(current map[string]activeConnection, now time.Time) sessionChanges {
+	var changes sessionChanges
+
+	for key, conn := range current {
+		session, exists := t.sessions[key]
+
+		// A client that reconnected after its grace period starts over
+		if exists && !session.logonTime.Equal(conn.logonTime) &&
+			!session.missingSince.IsZero() && now.Sub(session.missingSince) >= t.gracePeriod {
+			changes.closed = append(changes.closed, session)
+			delete(t.sessions, key)
+			exists = false
+		}
+
+		if !exists {
+			opened := conn
+			t.sessions[key] = &opened
+			changes.opened = append(changes.opened, &opened)
+			continue
+		}
+
+		session.lastSeen = conn.lastSeen
+		session.logonTime = conn.logonTime
+		session.rating = conn.rating
+		session.server = conn.server
+		session.hasFlightPlan = session.hasFlightPlan || conn.hasFlightPlan
+		session.distanceNM += legDistanceNM(session.position, conn.position)
+		session.position = conn.position
+		session.filed = conn.filed
+		session.atisInfo = conn.atisInfo
+		session.missingSince = time.Time{}
+		changes.updated = append(changes.updated, session)
+	}
+
+	for key, session := range t.sessions {
+		if _, present := current[key]; present {
+			continue
+		}
+
+		// Enter the grace period on the first missed snapshot
+		if session.missingSince.IsZero() {
+			session.missingSince = now
+		}
+
+		if now.Sub(session.missingSince) >= t.gracePeriod {
+			changes.closed = append(changes.closed, session)
+			delete(t.sessions, key)
+		}
+	}
+
+	return changes
+}
+
+// snapshotConnections converts every client in a snapshot into the
+// connection state it represents, keyed by session
+func snapshotConnections(data *types.VatsimData) map[string]activeConnection {
+	conns := make(map[string]activeConnection, len(data.Pilots)+len(data.Controllers))
+
+	for _, pilot := range data.Pilots {
+		conn := activeConnection{
+			cid:            fmt.Sprintf("%d", pilot.CID),
+			callsign:       pilot.Callsign,
+			connectionType: api.TypePilot,
+			rating:         pilot.PilotRating,
+			server:         pilot.Server,
+			startTime:      pilot.LogonTime,
+			logonTime:      pilot.LogonTime,
+			lastSeen:       pilot.LastUpdated,
+			hasFlightPlan:  pilot.FlightPlan != nil,
+			position: &positionSample{
+				time:        pilot.LastUpdated,
+				latitude:    pilot.Latitude,
+				longitude:   pilot.Longitude,
+				altitude:    pilot.Altitude,
+				groundspeed: pilot.Groundspeed,
+			},
+		}
+		if fp := pilot.FlightPlan; fp != nil {
+			conn.filed = filedPlan{
+				departure:   fp.Departure,
+				arrival:     fp.Arrival,
+				depTime:     fp.DepTime,
+				enrouteTime: fp.EnrouteTime,
+			}
+		}
+		conns[conn.key()] = conn
+	}
+
+	for _, controller := range data.Controllers {
+		conn := activeConnection{
+			cid:            fmt.Sprintf("%d", controller.CID),
+			callsign:       controller.Callsign,
+			connectionType: api.ControllerType(controller.Callsign, controller.Facility),
+			rating:         controller.Rating,
+			server:         controller.Server,
+			startTime:      controller.LogonTime,
+			logonTime:      controller.LogonTime,
+			lastSeen:       controller.LastUpdated,
+			frequency:      controller.Frequency,
+		}
+		if conn.connectionType == api.TypeATIS {
+			conn.atisInfo = atis.Parse(controller.TextAtis)
+		}
+		conns[conn.key()] = conn
+	}
+
+	return conns
+}
+
+// loadOpenSessions restores the sessions left open by a previous run. They
+// start in the grace period from the last stored snapshot, so clients that
+// are still online continue their sessions and the rest are closed. The
+// grace period is measured in snapshot time, not in the time clients were
+// last seen, which is stamped by their own clocks.
+// Pilots resume from their last recorded flight phase and distance, ATIS
+// stations from their last recorded broadcast.
+func loadOpenSessions(tx *sql.Tx, tracker *sessionTracker) error {
+	rows, err := tx.Query(`
+		SELECT
+			c.id, c.vatsim_id, c.type, c.rating, c.callsign,
+			c.start_time, c.end_time, c.server, c.distance_nm,
+			COALESCE(c.frequency, ''), COALESCE(c.facility, ''), COALESCE(p.phase, ''),
+			COALESCE(a.letter, ''), COALESCE(a.runways, '{}'), COALESCE(a.updates, 0),
+			(SELECT MAX(timestamp) FROM snapshots)
+		FROM connections c
+		LEFT JOIN LATERAL (
+			SELECT phase FROM flight_phases
+			WHERE connection_id = c.id
+			ORDER BY started_at DESC
+			LIMIT 1
+		) p ON true
+		LEFT JOIN LATERAL (
+			SELECT letter, runways, COUNT(*) OVER () AS updates
+			FROM atis_updates
+			WHERE connection_id = c.id
+			ORDER BY updated_at DESC
+			LIMIT 1
+		) a ON true
+		WHERE NOT c.closed
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var session activeConnection
+		var phase string
+		var broadcast atis.Info
+		var lastSnapshot sql.NullTime
+		err := rows.Scan(
+			&session.id, &session.cid, &session.connectionType, &session.rating,
+			&session.callsign, &session.startTime, &session.lastSeen, &session.server,
+			&session.distanceNM, &session.frequency, &session.facility, &phase,
+			&broadcast.Letter, pq.Array(&broadcast.Runways), &session.atisUpdates,
+			&lastSnapshot,
+		)
+		if err != nil {
+			return err
+		}
+		if broadcast.Letter != "" {
+			session.atisInfo = broadcast
+			session.recordedATIS = &broadcast
+		}
+		if phase != "" {
+			session.flight = &flightState{
+				phase:    api.FlightPhase(phase),
+				airborne: airbornePhase(api.FlightPhase(phase)),
+			}
+		}
+		session.logonTime = session.startTime
+		session.missingSince = lastSnapshot.Time
+		tracker.sessions[session.key()] = &session
+	}
+
+	return rows.Err()
+}
+
+// syncConnections applies a snapshot to the open sessions and persists the
+// resulting transitions with a fixed number of statements
+func (c *Collector) syncConnections(tx *sql.Tx, data *types.VatsimData) error {
+	if c.sessions == nil {
+		tracker := newSessionTracker(c.gracePeriod)
+		if err := loadOpenSessions(tx, tracker); err != nil {
+			return fmt.Errorf("error loading open sessions: %v", err)
+		}
+		c.sessions = tracker
+	}
+
+	changes := c.sessions.apply(snapshotConnections(data), data.General.UpdateTimestamp)
+	for _, session := range changes.opened {
+		if session.connectionType == api.TypeATC || session.connectionType == api.TypeATIS {
+			session.facility = c.classifyFacility(session)
+		}
+	}
+
+	if err := insertSessions(tx, changes.opened); err != nil {
+		return err
+	}
+	if err := updateSessions(tx, changes.updated, false); err != nil {
+		return err
+	}
+	if err := updateSessions(tx, changes.closed, true); err != nil {
+		return err
+	}
+
+	// Follow the pilots present in the snapshot through their flight phases
+	present := make([]*activeConnection, 0, len(changes.opened)+len(changes.updated))
+	present = append(present, changes.opened...)
+	present = append(present, changes.updated...)
+	transitions, flights := c.detectPhases(present)
+	if err := insertPhaseTransitions(tx, transitions); err != nil {
+		return fmt.Errorf("error storing flight phases: %v", err)
+	}
+	if err := upsertFlights(tx, flights); err != nil {
+		return fmt.Errorf("error storing flights: %v", err)
+	}
+
+	// Record every new letter and runway change of the ATIS stations
+	if err := insertATISUpdates(tx, detectATISUpdates(present, data.General.UpdateTimestamp)); err != nil {
+		return fmt.Errorf("error storing ATIS updates: %v", err)
+	}
+
+	// Record the statistics of every finished session
+	for _, session := range changes.closed {
+		if err := c.storeConnectionStats(tx, *session); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertSessions creates connection rows for newly opened sessions
+func insertSessions(tx *sql.Tx, sessions []*activeConnection) error {
+	ids, err := allocateIDs(tx, "connections_id_seq", len(sessions))
+	if err != nil || len(sessions) == 0 {
+		return err
+	}
+
+	var (
+		cids        []string
+		connTypes   []int64
+		ratings     []int64
+		callsigns   []string
+		starts      []string
+		ends        []string
+		servers     []string
+		frequencies []string
+		facilityIDs []string
+	)
+	for i, session := range sessions {
+		session.id = ids[i]
+		cids = append(cids, session.cid)
+		connTypes = append(connTypes, int64(session.connectionType))
+		ratings = append(ratings, int64(session.rating))
+		callsigns = append(callsigns, session.callsign)
+		starts = append(starts, session.startTime.Format(time.RFC3339Nano))
+		ends = append(ends, session.lastSeen.Format(time.RFC3339Nano))
+		servers = append(servers, session.server)
+		frequencies = append(frequencies, session.frequency)
+		facilityIDs = append(facilityIDs, session.facility)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO connections (
+			id, vatsim_id, type, rating, callsign,
+			start_time, end_time, server, frequency, facility
+		)
+		SELECT
+			id, vatsim_id, type, rating, callsign,
+			start_time, end_time, server, NULLIF(frequency, ''), NULLIF(facility, '')
+		FROM unnest(
+			$1::bigint[], $2::varchar[], $3::integer[], $4::integer[], $5::varchar[],
+			$6::timestamptz[], $7::timestamptz[], $8::varchar[], $9::varchar[], $10::varchar[]
+		) AS u(id, vatsim_id, type, rating, callsign, start_time, end_time, server, frequency, facility)
+	`, pq.Array(ids), pq.Array(cids), pq.Array(connTypes), pq.Array(ratings), pq.Array(callsigns),
+		pq.Array(starts), pq.Array(ends), pq.Array(servers), pq.Array(frequencies), pq.Array(facilityIDs))
+	return err
+}
+
+// updateSessions extends existing connection rows and their distance
+// flown, closing them if asked
+func updateSessions(tx *sql.Tx, sessions []*activeConnection, closed bool) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(sessions))
+	ends := make([]string, 0, len(sessions))
+	ratings := make([]int64, 0, len(sessions))
+	distances := make([]float64, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.id)
+		ends = append(ends, session.lastSeen.Format(time.RFC3339Nano))
+		ratings = append(ratings, int64(session.rating))
+		distances = append(distances, session.distanceNM)
+	}
+
+	_, err := tx.Exec(`
+		UPDATE connections c
+		SET end_time = u.end_time, rating = u.rating, distance_nm = u.distance_nm, closed = $5
+		FROM unnest($1::bigint[], $2::timestamptz[], $3::integer[], $4::double precision[])
+			AS u(id, end_time, rating, distance_nm)
+		WHERE c.id = u.id
+	`, pq.Array(ids), pq.Array(ends), pq.Array(ratings), pq.Array(distances), closed)
+	return err
+}
@@ -0,0 +1,273 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/api"
+	"github.com/vainnor/vatsim-stats/types"
+)
+
+var sessionEpoch = time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+
+// at returns the snapshot time after n 15 second intervals
+func at(n int) time.Time {
+	return sessionEpoch.Add(time.Duration(n) * 15 * time.Second)
+}
+
+// pilotConn builds the connection a pilot represents in a snapshot at n
+func pilotConn(callsign string, logon time.Time, n int) activeConnection {
+	return activeConnection{
+		cid:            "1234567",
+		callsign:       callsign,
+		connectionType: api.TypePilot,
+		rating:         1,
+		server:         "TEST",
+		startTime:      logon,
+		logonTime:      logon,
+		lastSeen:       at(n),
+	}
+}
+
+func snapshotOf(conns ...activeConnection) map[string]activeConnection {
+	current := make(map[string]activeConnection)
+	for _, conn := range conns {
+		current[conn.key()] = conn
+	}
+	return current
+}
+
+func TestSessionOpenUpdateClose(t *testing.T) {
+	tracker := newSessionTracker(time.Minute)
+	logon := at(0)
+
+	changes := tracker.apply(snapshotOf(pilotConn("TST1", logon, 0)), at(0))
+	if len(changes.opened) != 1 || len(changes.updated) != 0 || len(changes.closed) != 0 {
+		t.Fatalf("first snapshot: %+v", changes)
+	}
+
+	changes = tracker.apply(snapshotOf(pilotConn("TST1", logon, 1)), at(1))
+	if len(changes.opened) != 0 || len(changes.updated) != 1 {
+		t.Fatalf("second snapshot: %+v", changes)
+	}
+
+	// Missing, but still within the grace period
+	for n := 2; n < 5; n++ {
+		changes = tracker.apply(snapshotOf(), at(n))
+		if len(changes.closed) != 0 {
+			t.Fatalf("closed during grace period at snapshot %d", n)
+		}
+	}
+
+	changes = tracker.apply(snapshotOf(), at(6))
+	if len(changes.closed) != 1 {
+		t.Fatalf("expected session to close after grace period: %+v", changes)
+	}
+	closed := changes.closed[0]
+	if !closed.startTime.Equal(logon) || !closed.lastSeen.Equal(at(1)) {
+		t.Errorf("closed session spans %v to %v, want %v to %v", closed.startTime, closed.lastSeen, logon, at(1))
+	}
+	if len(tracker.sessions) != 0 {
+		t.Errorf("%d sessions still open", len(tracker.sessions))
+	}
+}
+
+func TestSessionReconnectWithinGracePeriod(t *testing.T) {
+	tracker := newSessionTracker(time.Minute)
+	first := tracker.apply(snapshotOf(pilotConn("TST1", at(0), 0)), at(0)).opened[0]
+	first.id = 42
+
+	tracker.apply(snapshotOf(), at(1))
+	tracker.apply(snapshotOf(), at(2))
+
+	// Reconnected to the network with a new logon time
+	changes := tracker.apply(snapshotOf(pilotConn("TST1", at(3), 3)), at(3))
+	if len(changes.opened) != 0 || len(changes.closed) != 0 || len(changes.updated) != 1 {
+		t.Fatalf("reconnect within grace period: %+v", changes)
+	}
+	if session := changes.updated[0]; session.id != 42 || !session.startTime.Equal(at(0)) {
+		t.Errorf("reconnect continued session %d starting %v, want 42 starting %v", session.id, session.startTime, at(0))
+	}
+}
+
+func TestSessionReconnectAfterGracePeriod(t *testing.T) {
+	tracker := newSessionTracker(time.Minute)
+	tracker.apply(snapshotOf(pilotConn("TST1", at(0), 0)), at(0))
+	tracker.apply(snapshotOf(), at(1))
+
+	changes := tracker.apply(snapshotOf(), at(10))
+	if len(changes.closed) != 1 {
+		t.Fatalf("expected close after grace period: %+v", changes)
+	}
+
+	changes = tracker.apply(snapshotOf(pilotConn("TST1", at(11), 11)), at(11))
+	if len(changes.opened) != 1 || len(changes.updated) != 0 {
+		t.Fatalf("reconnect after grace period: %+v", changes)
+	}
+	if !changes.opened[0].startTime.Equal(at(11)) {
+		t.Errorf("new session starts %v, want %v", changes.opened[0].startTime, at(11))
+	}
+}
+
+func TestSessionReconnectAtGracePeriod(t *testing.T) {
+	tracker := newSessionTracker(time.Minute)
+	tracker.apply(snapshotOf(pilotConn("TST1", at(0), 0)), at(0)).opened[0].id = 42
+	tracker.apply(snapshotOf(), at(1))
+
+	// Back with a new logon exactly one grace period after going missing,
+	// when an absent client would be closed
+	changes := tracker.apply(snapshotOf(pilotConn("TST1", at(5), 5)), at(5))
+	if len(changes.closed) != 1 || changes.closed[0].id != 42 {
+		t.Fatalf("expected the old session to close at the grace boundary: %+v", changes)
+	}
+	if len(changes.opened) != 1 || len(changes.updated) != 0 {
+		t.Fatalf("expected a new session at the grace boundary: %+v", changes)
+	}
+}
+
+func TestSessionCallsignChange(t *testing.T) {
+	tracker := newSessionTracker(time.Minute)
+	tracker.apply(snapshotOf(pilotConn("TST1", at(0), 0)), at(0))
+
+	changes := tracker.apply(snapshotOf(pilotConn("TST2", at(1), 1)), at(1))
+	if len(changes.opened) != 1 || changes.opened[0].callsign != "TST2" {
+		t.Fatalf("callsign change should open a new session: %+v", changes)
+	}
+	if len(changes.closed) != 0 {
+		t.Fatalf("old callsign closed before its grace period: %+v", changes)
+	}
+
+	changes = tracker.apply(snapshotOf(pilotConn("TST2", at(1), 6)), at(6))
+	if len(changes.closed) != 1 || changes.closed[0].callsign != "TST1" {
+		t.Fatalf("old callsign should close after its grace period: %+v", changes)
+	}
+	if len(changes.updated) != 1 || changes.updated[0].callsign != "TST2" {
+		t.Fatalf("new callsign should continue: %+v", changes)
+	}
+}
+
+func TestSessionRestoredAfterRestart(t *testing.T) {
+	tracker := newSessionTracker(time.Minute)
+
+	// Sessions as loaded from the database after a restart, last stored
+	// in the snapshot at(10). Their clients' clocks run a minute behind.
+	online := pilotConn("TST1", at(0), 10)
+	online.lastSeen = online.lastSeen.Add(-time.Minute)
+	online.id, online.missingSince = 1, at(10)
+	gone := pilotConn("TST2", at(0), 10)
+	gone.lastSeen = gone.lastSeen.Add(-time.Minute)
+	gone.id, gone.missingSince = 2, at(10)
+	tracker.sessions[online.key()] = &online
+	tracker.sessions[gone.key()] = &gone
+
+	// First snapshot after restart, three minutes later
+	changes := tracker.apply(snapshotOf(pilotConn("TST1", at(0), 22)), at(22))
+
+	if len(changes.opened) != 0 {
+		t.Errorf("restored session reopened: %+v", changes.opened)
+	}
+	if len(changes.updated) != 1 || changes.updated[0].id != 1 {
+		t.Errorf("client still online should continue its session: %+v", changes.updated)
+	}
+	if len(changes.closed) != 1 || changes.closed[0].id != 2 {
+		t.Errorf("client gone while stopped should be closed: %+v", changes.closed)
+	}
+}
+
+func TestSessionRestoredGracePeriodUsesSnapshotTime(t *testing.T) {
+	tracker := newSessionTracker(time.Minute)
+
+	// Last stored in the snapshot at(10) by a client whose clock runs two
+	// minutes behind
+	gone := pilotConn("TST1", at(0), 10)
+	gone.lastSeen = gone.lastSeen.Add(-2 * time.Minute)
+	gone.id, gone.missingSince = 1, at(10)
+	tracker.sessions[gone.key()] = &gone
+
+	if changes := tracker.apply(snapshotOf(), at(13)); len(changes.closed) != 0 {
+		t.Fatalf("session closed 45 seconds into its grace period: %+v", changes.closed)
+	}
+	if changes := tracker.apply(snapshotOf(), at(14)); len(changes.closed) != 1 {
+		t.Errorf("session should close a minute after the last snapshot: %+v", changes)
+	}
+}
+
+func TestSessionSecondsCountsShortSessions(t *testing.T) {
+	// 45 minutes used to be truncated to zero hours
+	conn := pilotConn("TST1", at(0), 180)
+	if got := sessionSeconds(conn); got != 45*60 {
+		t.Errorf("sessionSeconds = %d, want %d", got, 45*60)
+	}
+
+	conn.lastSeen = conn.startTime.Add(-time.Second)
+	if got := sessionSeconds(conn); got != 0 {
+		t.Errorf("negative session should count as 0, got %d", got)
+	}
+}
+
+func TestSessionAccumulatesDistance(t *testing.T) {
+	tracker := newSessionTracker(time.Minute)
+	logon := at(0)
+
+	// Due north at 240 knots, one nautical mile per snapshot
+	positioned := func(n int, lat float64) activeConnection {
+		conn := pilotConn("TST1", logon, n)
+		conn.position = &positionSample{time: at(n), latitude: lat, groundspeed: 240}
+		return conn
+	}
+
+	tracker.apply(snapshotOf(positioned(0, 0)), at(0))
+	tracker.apply(snapshotOf(positioned(1, 1.0/60)), at(1))
+	tracker.apply(snapshotOf(positioned(2, 2.0/60)), at(2))
+	// A stale repeat of the last report adds nothing
+	tracker.apply(snapshotOf(positioned(2, 2.0/60)), at(3))
+	// Repositioning across the world is not flown
+	changes := tracker.apply(snapshotOf(positioned(4, 45)), at(4))
+
+	if got := changes.updated[0].distanceNM; got < 1.99 || got > 2.01 {
+		t.Errorf("distance = %.3f nm, want 2", got)
+	}
+}
+
+type stubClassifier map[string]string
+
+func (s stubClassifier) Classify(callsign, frequency string) string {
+	return s[callsign+"/"+frequency]
+}
+
+func TestClassifyFacility(t *testing.T) {
+	c := NewCollector(nil)
+	session := &activeConnection{callsign: "EGTT_CTR", frequency: "127.825", connectionType: api.TypeATC}
+
+	if got := c.classifyFacility(session); got != "EGTT" {
+		t.Errorf("without a registry the facility is the prefix, got %s", got)
+	}
+
+	c.SetFacilities(stubClassifier{"EGTT_CTR/127.825": "LON"})
+	if got := c.classifyFacility(session); got != "LON" {
+		t.Errorf("facility = %s, want LON", got)
+	}
+}
+
+func TestSnapshotConnectionsControllerTypes(t *testing.T) {
+	data := &types.VatsimData{Controllers: []types.Controller{
+		{CID: 1, Callsign: "EGLL_TWR", Facility: 4, TextAtis: []string{"London Heathrow Tower"}},
+		{CID: 2, Callsign: "EGLL_ATIS", Facility: 4, TextAtis: []string{"INFORMATION A"}},
+		{CID: 3, Callsign: "JD_OBS", Facility: 0},
+	}}
+
+	want := map[string]api.ConnectionType{
+		"EGLL_TWR":  api.TypeATC,
+		"EGLL_ATIS": api.TypeATIS,
+		"JD_OBS":    api.TypeObserver,
+	}
+	conns := snapshotConnections(data)
+	if len(conns) != len(want) {
+		t.Fatalf("got %d connections, want %d", len(conns), len(want))
+	}
+	for _, conn := range conns {
+		if conn.connectionType != want[conn.callsign] {
+			t.Errorf("%s typed %d, want %d", conn.callsign, conn.connectionType, want[conn.callsign])
+		}
+	}
+}
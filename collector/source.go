@@ -0,0 +1,209 @@
+package collector
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const vatsimDataURL = "https://data.vatsim.net/v3/vatsim-data.json"
+
+// DataSource provides raw VATSIM v3 data feed payloads to the collector
+type DataSource interface {
+	// Fetch returns the next raw vatsim-data.json payload
+	Fetch() ([]byte, error)
+	// String describes the source for logging
+	String() string
+}
+
+// NewDataSource creates a data source from a configuration string.
+//
+// Supported forms:
+//   - http:// or https:// URL: fetch the feed over HTTP
+//   - file:<path>: read the same file on every fetch
+//   - dir:<path>: replay recorded snapshots from a directory in name order
+//   - stdin or -: read consecutive JSON documents from standard input
+//
+// An empty string selects the live VATSIM feed. A bare path is treated as a
+// directory or file depending on what exists on disk.
+func NewDataSource(spec string) (DataSource, error) {
+	switch {
+	case spec == "":
+		return NewHTTPSource(vatsimDataURL), nil
+	case spec == "-" || spec == "stdin":
+		return NewReaderSource("stdin", os.Stdin), nil
+	case strings.HasPrefix(spec, "http://"), strings.HasPrefix(spec, "https://"):
+		return NewHTTPSource(spec), nil
+	case strings.HasPrefix(spec, "file:"):
+		return NewFileSource(strings.TrimPrefix(spec, "file:")), nil
+	case strings.HasPrefix(spec, "dir:"):
+		return NewDirSource(strings.TrimPrefix(spec, "dir:"))
+	}
+
+	info, err := os.Stat(spec)
+	if err != nil {
+		return nil, fmt.Errorf("unknown data source %q: %v", spec, err)
+	}
+	if info.IsDir() {
+		return NewDirSource(spec)
+	}
+	return NewFileSource(spec), nil
+}
+
+// HTTPSource fetches the data feed from a URL
+type HTTPSource struct {
+	URL    string
+	client *http.Client
+}
+
+func NewHTTPSource(url string) *HTTPSource {
+	return &HTTPSource{
+		URL: url,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+func (s *HTTPSource) Fetch() ([]byte, error) {
+	resp, err := s.client.Get(s.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status from %s: %s", s.URL, resp.Status)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (s *HTTPSource) String() string {
+	return s.URL
+}
+
+// FileSource reads a single local file on every fetch
+type FileSource struct {
+	Path string
+}
+
+func NewFileSource(path string) *FileSource {
+	return &FileSource{Path: path}
+}
+
+func (s *FileSource) Fetch() ([]byte, error) {
+	return readSnapshotFile(s.Path)
+}
+
+func (s *FileSource) String() string {
+	return "file:" + s.Path
+}
+
+// DirSource replays recorded snapshot files from a directory, one file per
+// fetch in lexical order. It returns io.EOF once every file has been read.
+type DirSource struct {
+	Path  string
+	mu    sync.Mutex
+	files []string
+	next  int
+}
+
+func NewDirSource(path string) (*DirSource, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot directory: %v", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isSnapshotFile(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(path, entry.Name()))
+	}
+	sort.Strings(files)
+
+	return &DirSource{Path: path, files: files}, nil
+}
+
+func (s *DirSource) Fetch() ([]byte, error) {
+	s.mu.Lock()
+	if s.next >= len(s.files) {
+		s.mu.Unlock()
+		return nil, io.EOF
+	}
+	file := s.files[s.next]
+	s.next++
+	s.mu.Unlock()
+
+	return readSnapshotFile(file)
+}
+
+func (s *DirSource) String() string {
+	return "dir:" + s.Path
+}
+
+// ReaderSource reads consecutive JSON documents from a stream such as stdin
+type ReaderSource struct {
+	name    string
+	mu      sync.Mutex
+	decoder *json.Decoder
+}
+
+func NewReaderSource(name string, r io.Reader) *ReaderSource {
+	return &ReaderSource{
+		name:    name,
+		decoder: json.NewDecoder(bufio.NewReader(r)),
+	}
+}
+
+func (s *ReaderSource) Fetch() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var raw json.RawMessage
+	if err := s.decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+func (s *ReaderSource) String() string {
+	return s.name
+}
+
+// isSnapshotFile reports whether a file name looks like a recorded payload
+func isSnapshotFile(name string) bool {
+	return strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.gz")
+}
+
+// readSnapshotFile reads a payload from disk, decompressing .gz files
+func readSnapshotFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if !strings.HasSuffix(path, ".gz") {
+		return io.ReadAll(f)
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", path, err)
+	}
+	defer gz.Close()
+
+	return io.ReadAll(gz)
+}
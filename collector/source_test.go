@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSnapshot writes a payload to dir, gzipped if the name ends in .gz
+func writeSnapshot(t *testing.T, dir, name, payload string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if strings.HasSuffix(name, ".gz") {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+	if _, err := io.WriteString(w, payload); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewDataSource(t *testing.T) {
+	dir := t.TempDir()
+	file := writeSnapshot(t, dir, "a.json", `{}`)
+
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"", vatsimDataURL},
+		{"https://example.com/feed.json", "https://example.com/feed.json"},
+		{"file:" + file, "file:" + file},
+		{"dir:" + dir, "dir:" + dir},
+		{"-", "stdin"},
+		{"stdin", "stdin"},
+		{dir, "dir:" + dir},
+		{file, "file:" + file},
+	}
+	for _, tt := range tests {
+		source, err := NewDataSource(tt.spec)
+		if err != nil {
+			t.Errorf("NewDataSource(%q): %v", tt.spec, err)
+			continue
+		}
+		if source.String() != tt.want {
+			t.Errorf("NewDataSource(%q) = %s, want %s", tt.spec, source, tt.want)
+		}
+	}
+
+	if _, err := NewDataSource(filepath.Join(dir, "missing")); err == nil {
+		t.Error("a path that does not exist should be rejected")
+	}
+}
+
+func TestHTTPSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/feed.json" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"general":{}}`)
+	}))
+	defer server.Close()
+
+	raw, err := NewHTTPSource(server.URL + "/feed.json").Fetch()
+	if err != nil || string(raw) != `{"general":{}}` {
+		t.Errorf("Fetch = %q, %v", raw, err)
+	}
+	if _, err := NewHTTPSource(server.URL + "/missing").Fetch(); err == nil {
+		t.Error("a non-200 response should be an error")
+	}
+}
+
+func TestFileSourceReadsGzip(t *testing.T) {
+	path := writeSnapshot(t, t.TempDir(), "snapshot.json.gz", `{"pilots":[]}`)
+
+	source := NewFileSource(path)
+	for i := 0; i < 2; i++ {
+		raw, err := source.Fetch()
+		if err != nil || string(raw) != `{"pilots":[]}` {
+			t.Errorf("fetch %d = %q, %v", i, raw, err)
+		}
+	}
+}
+
+func TestDirSourceReplaysInOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeSnapshot(t, dir, "20240315120015.json", `2`)
+	writeSnapshot(t, dir, "20240315120000.json.gz", `1`)
+	writeSnapshot(t, dir, "20240315120030.json", `3`)
+	writeSnapshot(t, dir, "notes.txt", `ignored`)
+
+	source, err := NewDirSource(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1", "2", "3"} {
+		raw, err := source.Fetch()
+		if err != nil || string(raw) != want {
+			t.Fatalf("Fetch = %q, %v, want %s", raw, err, want)
+		}
+	}
+	if _, err := source.Fetch(); err != io.EOF {
+		t.Errorf("expected io.EOF after the last file, got %v", err)
+	}
+}
+
+func TestReaderSourceSplitsDocuments(t *testing.T) {
+	source := NewReaderSource("test", strings.NewReader(`{"n":1}
+{"n":2} {"n":3}`))
+
+	for _, want := range []string{`{"n":1}`, `{"n":2}`, `{"n":3}`} {
+		raw, err := source.Fetch()
+		if err != nil || string(raw) != want {
+			t.Fatalf("Fetch = %q, %v, want %s", raw, err, want)
+		}
+	}
+	if _, err := source.Fetch(); err != io.EOF {
+		t.Errorf("expected io.EOF at the end of the stream, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/api"
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// TestControllerTotalsMatchRecompute checks the controller totals kept up to
+// date as sessions close against those rebuilt from the stored sessions.
+// It needs PostgreSQL: set TEST_DATABASE_URL.
+func TestControllerTotalsMatchRecompute(t *testing.T) {
+	if _, ok := db.DB.Driver().(*fakeDriver); ok {
+		t.Skip("controller totals are computed by PostgreSQL; set TEST_DATABASE_URL")
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	const cid = "9999001"
+	controller := func(callsign string, rating int, start time.Time, d time.Duration) *activeConnection {
+		return &activeConnection{
+			cid:            cid,
+			callsign:       callsign,
+			connectionType: api.TypeATC,
+			rating:         rating,
+			server:         "TEST",
+			startTime:      start,
+			logonTime:      start,
+			lastSeen:       start.Add(d),
+		}
+	}
+	sessions := []*activeConnection{
+		controller("EGLL_TWR", 5, at(0), time.Hour),
+		controller("EGLL_DEP", 5, at(300), 30*time.Minute),
+		controller("EGTT_CTR", 7, at(500), 2*time.Hour),
+	}
+	if err := insertSessions(tx, sessions); err != nil {
+		t.Fatal(err)
+	}
+	c := NewCollector(nil)
+	for _, session := range sessions {
+		if err := c.storeConnectionStats(tx, *session); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(stage string) {
+		var total, count, twr, app, ctr, other int64
+		err := tx.QueryRow(`
+			SELECT total_seconds, sessions, twr_seconds, app_seconds, ctr_seconds, other_seconds
+			FROM controller_total_stats
+			WHERE vatsim_id = $1
+		`, cid).Scan(&total, &count, &twr, &app, &ctr, &other)
+		if err != nil {
+			t.Fatalf("%s: %v", stage, err)
+		}
+		// DEP counts as APP
+		if total != 12600 || count != 3 || twr != 3600 || app != 1800 || ctr != 7200 || other != 0 {
+			t.Errorf("%s totals: %d s over %d sessions, TWR %d, APP %d, CTR %d, other %d",
+				stage, total, count, twr, app, ctr, other)
+		}
+
+		rows, err := tx.Query(`
+			SELECT rating, total_seconds, sessions
+			FROM controller_rating_stats
+			WHERE vatsim_id = $1
+			ORDER BY rating
+		`, cid)
+		if err != nil {
+			t.Fatalf("%s: %v", stage, err)
+		}
+		defer rows.Close()
+
+		want := [][3]int64{{5, 5400, 2}, {7, 7200, 1}}
+		var got [][3]int64
+		for rows.Next() {
+			var r [3]int64
+			if err := rows.Scan(&r[0], &r[1], &r[2]); err != nil {
+				t.Fatalf("%s: %v", stage, err)
+			}
+			got = append(got, r)
+		}
+		if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("%s rating totals = %v, want %v", stage, got, want)
+		}
+	}
+
+	check("incremental")
+	if _, err := tx.Exec(`SELECT recompute_controller_total_stats()`); err != nil {
+		t.Fatal(err)
+	}
+	check("recomputed")
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/airports"
+	"github.com/vainnor/vatsim-stats/collector"
+	"github.com/vainnor/vatsim-stats/db"
+	"github.com/vainnor/vatsim-stats/firs"
+)
+
+// runCommand dispatches a command-line subcommand
+func runCommand(name string, args []string) error {
+	switch name {
+	case "migrate":
+		return runMigrate(args)
+	case "replay":
+		return runReplay(args)
+	case "recompute-totals":
+		return runRecomputeTotals(args)
+	case "import-airports":
+		return runImportAirports(args)
+	case "import-firs":
+		return runImportFIRs(args)
+	case "classify-facilities":
+		return runClassifyFacilities(args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
+
+// runMigrate applies, rolls back or reports schema migrations
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: vatsim-stats migrate <up|down [steps]|goto <version>|status>")
+	}
+	fs.Parse(args)
+
+	action := fs.Arg(0)
+	switch action {
+	case "", "up":
+		if err := db.MigrateUp(); err != nil {
+			return err
+		}
+
+	case "down":
+		steps := 1
+		if fs.NArg() > 1 {
+			n, err := strconv.Atoi(fs.Arg(1))
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step count %q", fs.Arg(1))
+			}
+			steps = n
+		}
+		if err := db.MigrateDown(steps); err != nil {
+			return err
+		}
+
+	case "goto":
+		version, err := strconv.Atoi(fs.Arg(1))
+		if err != nil {
+			return fmt.Errorf("invalid version %q", fs.Arg(1))
+		}
+		if err := db.MigrateTo(version); err != nil {
+			return err
+		}
+
+	case "status":
+		statuses, err := db.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-30s  %s\n", status.Version, status.Name, applied)
+		}
+
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+
+	version, err := db.CurrentVersion()
+	if err != nil {
+		return err
+	}
+	log.Printf("Database schema is at version %d", version)
+	return nil
+}
+
+// runReplay feeds an archive of recorded snapshots back through the collector
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 0, "replay speed relative to real time (0 replays as fast as possible)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: vatsim-stats replay [-speed N] <archive-dir>")
+		fmt.Fprintln(fs.Output(), "Replays into an empty database only: sessions and totals would be")
+		fmt.Fprintln(fs.Output(), "added to those already recorded and counted twice.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one archive directory")
+	}
+
+	source, err := collector.NewDirSource(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	recorded, err := db.HasRecordedData()
+	if err != nil {
+		return fmt.Errorf("error checking for recorded data: %v", err)
+	}
+	if recorded {
+		return fmt.Errorf("the database already has recorded data; replay into an empty database")
+	}
+
+	c := collector.NewCollector(collector.NewReplaySource(source, *speed))
+	if dir := loadAirports(); dir != nil {
+		c.SetAirports(dir)
+	}
+	if index := loadFIRs(); index != nil {
+		c.SetFIRs(index)
+	}
+	registry, err := loadFacilities()
+	if err != nil {
+		return err
+	}
+	if registry != nil {
+		c.SetFacilities(registry)
+	}
+
+	log.Printf("Replaying snapshots from %s (speed: %v)", source, *speed)
+	start := time.Now()
+
+	count, err := collector.Replay(c)
+	if err != nil {
+		return fmt.Errorf("replay stopped after %d snapshots: %v", count, err)
+	}
+
+	log.Printf("Replayed %d snapshots in %v", count, time.Since(start).Round(time.Second))
+	return db.RefreshLeaderboards()
+}
+
+// runRecomputeTotals rebuilds the aggregate pilot and controller totals
+// from the recorded connections
+func runRecomputeTotals(args []string) error {
+	fs := flag.NewFlagSet("recompute-totals", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: vatsim-stats recompute-totals")
+	}
+	fs.Parse(args)
+
+	start := time.Now()
+	pilots, controllers, err := db.RecomputeTotals()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Recomputed totals for %d pilots and %d controllers in %v",
+		pilots, controllers, time.Since(start).Round(time.Millisecond))
+
+	// The all-time rankings are built from the totals
+	return db.RefreshLeaderboards()
+}
+
+// runClassifyFacilities reassigns the recorded controller connections to
+// the facilities of the registry in FACILITIES_FILE, after it was changed
+func runClassifyFacilities(args []string) error {
+	fs := flag.NewFlagSet("classify-facilities", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: vatsim-stats classify-facilities")
+	}
+	fs.Parse(args)
+
+	registry, err := loadFacilities()
+	if err != nil {
+		return err
+	}
+	if registry == nil {
+		log.Printf("FACILITIES_FILE is not set, classifying by callsign prefix")
+	}
+
+	changed, err := db.ReclassifyFacilities(registry.Classify)
+	if err != nil {
+		return err
+	}
+	log.Printf("Reclassified %d connections", changed)
+
+	// The per-facility rankings are built from the classification
+	return db.RefreshLeaderboards()
+}
+
+// runImportAirports replaces the airport reference data with an OurAirports
+// style CSV read from a file, a URL or standard input
+func runImportAirports(args []string) error {
+	fs := flag.NewFlagSet("import-airports", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: vatsim-stats import-airports <file|url|->")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one CSV file, URL or -")
+	}
+
+	input, err := openInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	list, err := airports.ParseCSV(input)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", fs.Arg(0), err)
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("no airports in %s", fs.Arg(0))
+	}
+
+	if err := airports.Import(list); err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d airports from %s", len(list), fs.Arg(0))
+	return nil
+}
+
+// runImportFIRs replaces the FIR boundaries with a VATSpy style GeoJSON
+// read from a file, a URL or standard input
+func runImportFIRs(args []string) error {
+	fs := flag.NewFlagSet("import-firs", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: vatsim-stats import-firs <file|url|->")
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one GeoJSON file, URL or -")
+	}
+
+	input, err := openInput(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	boundaries, err := firs.ParseGeoJSON(input)
+	if err != nil {
+		return fmt.Errorf("error parsing %s: %v", fs.Arg(0), err)
+	}
+	if len(boundaries) == 0 {
+		return fmt.Errorf("no boundaries in %s", fs.Arg(0))
+	}
+
+	if err := firs.Import(boundaries); err != nil {
+		return err
+	}
+
+	log.Printf("Imported %d FIR boundaries from %s", len(boundaries), fs.Arg(0))
+	return nil
+}
+
+// importClient downloads import files. The timeout covers reading the
+// whole body, so it allows for large files.
+var importClient = &http.Client{Timeout: 2 * time.Minute}
+
+// openInput opens a local file, an http(s) URL or standard input for "-"
+func openInput(name string) (io.ReadCloser, error) {
+	switch {
+	case name == "-":
+		return io.NopCloser(os.Stdin), nil
+
+	case strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://"):
+		resp, err := importClient.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error fetching %s: %s", name, resp.Status)
+		}
+		return resp.Body, nil
+
+	default:
+		return os.Open(name)
+	}
+}
@@ -0,0 +1,50 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ReclassifyFacilities assigns every recorded controller and ATIS
+// connection to the facility classify returns for its callsign and
+// frequency. It returns the number of connections that changed facility.
+func ReclassifyFacilities(classify func(callsign, frequency string) string) (int64, error) {
+	rows, err := DB.Query(`
+		SELECT DISTINCT callsign, COALESCE(frequency, '')
+		FROM connections
+		WHERE type IN (2, 3)
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("error querying controller callsigns: %v", err)
+	}
+	defer rows.Close()
+
+	var callsigns, frequencies, facilities []string
+	for rows.Next() {
+		var callsign, frequency string
+		if err := rows.Scan(&callsign, &frequency); err != nil {
+			return 0, fmt.Errorf("error scanning controller callsign: %v", err)
+		}
+		callsigns = append(callsigns, callsign)
+		frequencies = append(frequencies, frequency)
+		facilities = append(facilities, classify(callsign, frequency))
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	result, err := DB.Exec(`
+		UPDATE connections c
+		SET facility = u.facility
+		FROM unnest($1::text[], $2::text[], $3::text[]) AS u(callsign, frequency, facility)
+		WHERE c.type IN (2, 3)
+		AND c.callsign = u.callsign
+		AND COALESCE(c.frequency, '') = u.frequency
+		AND c.facility IS DISTINCT FROM u.facility
+	`, pq.Array(callsigns), pq.Array(frequencies), pq.Array(facilities))
+	if err != nil {
+		return 0, fmt.Errorf("error updating connection facilities: %v", err)
+	}
+	return result.RowsAffected()
+}
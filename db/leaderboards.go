@@ -0,0 +1,12 @@
+package db
+
+import "fmt"
+
+// RefreshLeaderboards recomputes the materialized leaderboard rankings.
+// Readers keep seeing the previous rankings until the refresh completes.
+func RefreshLeaderboards() error {
+	if _, err := DB.Exec(`REFRESH MATERIALIZED VIEW CONCURRENTLY leaderboard_rankings`); err != nil {
+		return fmt.Errorf("error refreshing leaderboards: %v", err)
+	}
+	return nil
+}
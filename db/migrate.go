@@ -0,0 +1,319 @@
+package db
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the advisory lock key held while applying migrations
+const migrationLockID = 7412094
+
+// Migration is a single versioned schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus describes a known migration and whether it is applied
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// LoadMigrations returns all embedded migrations ordered by version.
+// Files are named NNNN_name.up.sql and NNNN_name.down.sql.
+func LoadMigrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, label, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %q: %v", name, err)
+		}
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: label}
+			byVersion[version] = m
+		} else if m.Name != label {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, label)
+		}
+
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d (%s) has no up script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// LatestVersion returns the highest schema version known to this binary
+func LatestVersion() (int, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// createMigrationsTable makes sure the schema_migrations table exists
+func createMigrationsTable() error {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)
+	`)
+	return err
+}
+
+// CurrentVersion returns the highest applied schema version, or 0 for an
+// empty database
+func CurrentVersion() (int, error) {
+	if err := createMigrationsTable(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := DB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	return version, err
+}
+
+// Status lists every known migration with its applied time, if any
+func Status() ([]MigrationStatus, error) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return nil, err
+	}
+	if err := createMigrationsTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := DB.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Migration: m}
+		if appliedAt, ok := applied[m.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// CheckSchema returns an error if the database schema is newer than this
+// binary understands, or if migrations are pending
+func CheckSchema() error {
+	current, err := CurrentVersion()
+	if err != nil {
+		return err
+	}
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than the latest known version %d; refusing to run", current, latest)
+	}
+	if current < latest {
+		return fmt.Errorf("database schema version %d is behind the latest version %d; run the migrate command", current, latest)
+	}
+
+	return nil
+}
+
+// MigrateUp applies all pending migrations
+func MigrateUp() error {
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+	return MigrateTo(latest)
+}
+
+// MigrateDown rolls back the given number of applied migrations
+func MigrateDown(steps int) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+	current, err := CurrentVersion()
+	if err != nil {
+		return err
+	}
+
+	// Step back through the applied migrations
+	var applied []int
+	for _, m := range migrations {
+		if m.Version <= current {
+			applied = append(applied, m.Version)
+		}
+	}
+	if steps >= len(applied) {
+		return MigrateTo(0)
+	}
+
+	return MigrateTo(applied[len(applied)-1-steps])
+}
+
+// MigrateTo applies or rolls back migrations until the schema is at the
+// target version. Each migration runs in its own transaction.
+func MigrateTo(target int) error {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		return err
+	}
+
+	current, err := CurrentVersion()
+	if err != nil {
+		return err
+	}
+	latest := 0
+	if len(migrations) > 0 {
+		latest = migrations[len(migrations)-1].Version
+	}
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than the latest known version %d", current, latest)
+	}
+	if target > latest {
+		return fmt.Errorf("unknown target version %d (latest is %d)", target, latest)
+	}
+
+	if target >= current {
+		for _, m := range migrations {
+			if m.Version > current && m.Version <= target {
+				if err := applyMigration(m, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= current && m.Version > target {
+			if m.Down == "" {
+				return fmt.Errorf("migration %d (%s) cannot be rolled back", m.Version, m.Name)
+			}
+			if err := applyMigration(m, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyMigration runs one migration script and records the result
+func applyMigration(m Migration, up bool) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Serialize concurrent migrators and skip work another instance has done
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return err
+	}
+
+	var applied bool
+	err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied == up {
+		return nil
+	}
+
+	if up {
+		if _, err := tx.Exec(m.Up); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+	} else {
+		if _, err := tx.Exec(m.Down); err != nil {
+			return fmt.Errorf("rollback of migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+		_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,30 @@
+package db
+
+import "testing"
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations()
+	if err != nil {
+		t.Fatalf("LoadMigrations: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations found")
+	}
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("migration %q has version %d, want %d", m.Name, m.Version, i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("migration %d (%s) is missing an up or down script", m.Version, m.Name)
+		}
+	}
+
+	latest, err := LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != len(migrations) {
+		t.Errorf("LatestVersion = %d, want %d", latest, len(migrations))
+	}
+}
@@ -0,0 +1,23 @@
+DROP TABLE IF EXISTS network_trends_monthly;
+DROP TABLE IF EXISTS network_trends_weekly;
+DROP TABLE IF EXISTS network_trends_daily;
+DROP TABLE IF EXISTS route_stats;
+DROP TABLE IF EXISTS aircraft_stats;
+DROP TABLE IF EXISTS rating_stats;
+DROP TABLE IF EXISTS server_stats;
+DROP TABLE IF EXISTS network_stats;
+DROP TABLE IF EXISTS airport_stats;
+DROP TABLE IF EXISTS atis_stats;
+DROP TABLE IF EXISTS pilot_total_stats;
+DROP TABLE IF EXISTS pilot_stats;
+DROP TABLE IF EXISTS atc_stats;
+DROP TABLE IF EXISTS connections;
+DROP TABLE IF EXISTS flight_plans;
+DROP TABLE IF EXISTS controllers;
+DROP TABLE IF EXISTS pilots;
+DROP TABLE IF EXISTS military_ratings;
+DROP TABLE IF EXISTS pilot_ratings;
+DROP TABLE IF EXISTS ratings;
+DROP TABLE IF EXISTS facilities;
+DROP TABLE IF EXISTS snapshots;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,7 @@
+ALTER TABLE airport_stats
+	ALTER COLUMN icao TYPE VARCHAR(4) USING LEFT(icao, 4);
+
+ALTER TABLE flight_plans
+	ALTER COLUMN departure TYPE VARCHAR(4) USING LEFT(departure, 4),
+	ALTER COLUMN arrival TYPE VARCHAR(4) USING LEFT(arrival, 4),
+	ALTER COLUMN alternate TYPE VARCHAR(4) USING LEFT(alternate, 4);
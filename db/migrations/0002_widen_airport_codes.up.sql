@@ -0,0 +1,9 @@
+-- Filed airports are free text in the VATSIM feed and are not always
+-- four-letter ICAO codes.
+ALTER TABLE flight_plans
+	ALTER COLUMN departure TYPE VARCHAR(16),
+	ALTER COLUMN arrival TYPE VARCHAR(16),
+	ALTER COLUMN alternate TYPE VARCHAR(16);
+
+ALTER TABLE airport_stats
+	ALTER COLUMN icao TYPE VARCHAR(16);
@@ -0,0 +1,108 @@
+-- Convert the partitioned snapshot tables back into plain tables
+
+CREATE TABLE pilots_plain (
+	id SERIAL PRIMARY KEY,
+	snapshot_id INTEGER REFERENCES snapshots(id),
+	cid INTEGER NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	callsign VARCHAR(255) NOT NULL,
+	server VARCHAR(255) NOT NULL,
+	pilot_rating INTEGER NOT NULL,
+	military_rating INTEGER NOT NULL,
+	latitude DOUBLE PRECISION NOT NULL,
+	longitude DOUBLE PRECISION NOT NULL,
+	altitude INTEGER NOT NULL,
+	groundspeed INTEGER NOT NULL,
+	transponder VARCHAR(10) NOT NULL,
+	heading INTEGER NOT NULL,
+	qnh_i_hg DOUBLE PRECISION NOT NULL,
+	qnh_mb INTEGER NOT NULL,
+	logon_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	last_updated TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE controllers_plain (
+	id SERIAL PRIMARY KEY,
+	snapshot_id INTEGER REFERENCES snapshots(id),
+	cid INTEGER NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	callsign VARCHAR(255) NOT NULL,
+	frequency VARCHAR(10) NOT NULL,
+	facility INTEGER NOT NULL,
+	rating INTEGER NOT NULL,
+	server VARCHAR(255) NOT NULL,
+	visual_range INTEGER NOT NULL,
+	text_atis TEXT[],
+	last_updated TIMESTAMP WITH TIME ZONE NOT NULL,
+	logon_time TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE flight_plans_plain (
+	id SERIAL PRIMARY KEY,
+	pilot_id INTEGER REFERENCES pilots_plain(id),
+	flight_rules VARCHAR(2) NOT NULL,
+	aircraft VARCHAR(255) NOT NULL,
+	aircraft_faa VARCHAR(255) NOT NULL,
+	aircraft_short VARCHAR(255) NOT NULL,
+	departure VARCHAR(16) NOT NULL,
+	arrival VARCHAR(16) NOT NULL,
+	alternate VARCHAR(16),
+	cruise_tas VARCHAR(10) NOT NULL,
+	altitude VARCHAR(10) NOT NULL,
+	deptime VARCHAR(4) NOT NULL,
+	enroute_time VARCHAR(4) NOT NULL,
+	fuel_time VARCHAR(4) NOT NULL,
+	remarks TEXT,
+	route TEXT,
+	revision_id INTEGER NOT NULL,
+	assigned_transponder VARCHAR(10)
+);
+
+INSERT INTO pilots_plain
+SELECT
+	id, snapshot_id, cid, name, callsign, server,
+	pilot_rating, military_rating, latitude, longitude,
+	altitude, groundspeed, transponder, heading,
+	qnh_i_hg, qnh_mb, logon_time, last_updated
+FROM pilots;
+
+INSERT INTO controllers_plain
+SELECT
+	id, snapshot_id, cid, name, callsign, frequency,
+	facility, rating, server, visual_range, text_atis,
+	last_updated, logon_time
+FROM controllers;
+
+INSERT INTO flight_plans_plain
+SELECT
+	id, pilot_id, flight_rules, aircraft, aircraft_faa,
+	aircraft_short, departure, arrival, alternate,
+	cruise_tas, altitude, deptime, enroute_time,
+	fuel_time, remarks, route, revision_id,
+	assigned_transponder
+FROM flight_plans;
+
+DROP TABLE flight_plans;
+DROP TABLE pilots;
+DROP TABLE controllers;
+DROP TABLE snapshot_partitions;
+DROP FUNCTION create_snapshot_partitions(DATE);
+
+ALTER TABLE pilots_plain RENAME TO pilots;
+ALTER TABLE controllers_plain RENAME TO controllers;
+ALTER TABLE flight_plans_plain RENAME TO flight_plans;
+
+ALTER INDEX pilots_plain_pkey RENAME TO pilots_pkey;
+ALTER INDEX controllers_plain_pkey RENAME TO controllers_pkey;
+ALTER INDEX flight_plans_plain_pkey RENAME TO flight_plans_pkey;
+
+ALTER SEQUENCE pilots_plain_id_seq RENAME TO pilots_id_seq;
+ALTER SEQUENCE controllers_plain_id_seq RENAME TO controllers_id_seq;
+ALTER SEQUENCE flight_plans_plain_id_seq RENAME TO flight_plans_id_seq;
+
+SELECT setval('pilots_id_seq', COALESCE((SELECT MAX(id) FROM pilots), 0) + 1, false);
+SELECT setval('controllers_id_seq', COALESCE((SELECT MAX(id) FROM controllers), 0) + 1, false);
+SELECT setval('flight_plans_id_seq', COALESCE((SELECT MAX(id) FROM flight_plans), 0) + 1, false);
+
+CREATE INDEX idx_controllers_snapshot ON controllers(snapshot_id);
+CREATE INDEX idx_controllers_callsign ON controllers(callsign);
@@ -0,0 +1,181 @@
+-- Partition the raw per-snapshot tables by UTC day of the snapshot so old
+-- data can be downsampled and dropped one partition at a time. Existing
+-- rows are copied into the new tables.
+
+ALTER TABLE flight_plans RENAME TO flight_plans_legacy;
+ALTER TABLE pilots RENAME TO pilots_legacy;
+ALTER TABLE controllers RENAME TO controllers_legacy;
+
+ALTER INDEX flight_plans_pkey RENAME TO flight_plans_legacy_pkey;
+ALTER INDEX pilots_pkey RENAME TO pilots_legacy_pkey;
+ALTER INDEX controllers_pkey RENAME TO controllers_legacy_pkey;
+
+ALTER SEQUENCE flight_plans_id_seq RENAME TO flight_plans_legacy_id_seq;
+ALTER SEQUENCE pilots_id_seq RENAME TO pilots_legacy_id_seq;
+ALTER SEQUENCE controllers_id_seq RENAME TO controllers_legacy_id_seq;
+
+CREATE TABLE pilots (
+	id BIGSERIAL,
+	snapshot_id INTEGER REFERENCES snapshots(id),
+	snapshot_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	cid INTEGER NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	callsign VARCHAR(255) NOT NULL,
+	server VARCHAR(255) NOT NULL,
+	pilot_rating INTEGER NOT NULL,
+	military_rating INTEGER NOT NULL,
+	latitude DOUBLE PRECISION NOT NULL,
+	longitude DOUBLE PRECISION NOT NULL,
+	altitude INTEGER NOT NULL,
+	groundspeed INTEGER NOT NULL,
+	transponder VARCHAR(10) NOT NULL,
+	heading INTEGER NOT NULL,
+	qnh_i_hg DOUBLE PRECISION NOT NULL,
+	qnh_mb INTEGER NOT NULL,
+	logon_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	last_updated TIMESTAMP WITH TIME ZONE NOT NULL,
+	PRIMARY KEY (id, snapshot_time)
+) PARTITION BY RANGE (snapshot_time);
+
+CREATE TABLE controllers (
+	id BIGSERIAL,
+	snapshot_id INTEGER REFERENCES snapshots(id),
+	snapshot_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	cid INTEGER NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	callsign VARCHAR(255) NOT NULL,
+	frequency VARCHAR(10) NOT NULL,
+	facility INTEGER NOT NULL,
+	rating INTEGER NOT NULL,
+	server VARCHAR(255) NOT NULL,
+	visual_range INTEGER NOT NULL,
+	text_atis TEXT[],
+	last_updated TIMESTAMP WITH TIME ZONE NOT NULL,
+	logon_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	PRIMARY KEY (id, snapshot_time)
+) PARTITION BY RANGE (snapshot_time);
+
+-- A flight plan always shares its pilot row's snapshot_time and therefore
+-- its partition; the two are dropped together by the retention policy.
+CREATE TABLE flight_plans (
+	id BIGSERIAL,
+	pilot_id BIGINT NOT NULL,
+	snapshot_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	flight_rules VARCHAR(2) NOT NULL,
+	aircraft VARCHAR(255) NOT NULL,
+	aircraft_faa VARCHAR(255) NOT NULL,
+	aircraft_short VARCHAR(255) NOT NULL,
+	departure VARCHAR(16) NOT NULL,
+	arrival VARCHAR(16) NOT NULL,
+	alternate VARCHAR(16),
+	cruise_tas VARCHAR(10) NOT NULL,
+	altitude VARCHAR(10) NOT NULL,
+	deptime VARCHAR(4) NOT NULL,
+	enroute_time VARCHAR(4) NOT NULL,
+	fuel_time VARCHAR(4) NOT NULL,
+	remarks TEXT,
+	route TEXT,
+	revision_id INTEGER NOT NULL,
+	assigned_transponder VARCHAR(10),
+	PRIMARY KEY (id, snapshot_time)
+) PARTITION BY RANGE (snapshot_time);
+
+-- Creates the daily partitions of every snapshot table for a UTC day
+CREATE OR REPLACE FUNCTION create_snapshot_partitions(day DATE) RETURNS void AS $$
+DECLARE
+	parent TEXT;
+	from_ts TIMESTAMP WITH TIME ZONE := day::timestamp AT TIME ZONE 'UTC';
+	to_ts TIMESTAMP WITH TIME ZONE := (day + 1)::timestamp AT TIME ZONE 'UTC';
+BEGIN
+	FOREACH parent IN ARRAY ARRAY['pilots', 'controllers', 'flight_plans'] LOOP
+		EXECUTE format(
+			'CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+			parent || '_p' || to_char(day, 'YYYYMMDD'), parent, from_ts, to_ts
+		);
+	END LOOP;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Tracks which daily partitions have been downsampled
+CREATE TABLE snapshot_partitions (
+	day DATE PRIMARY KEY,
+	downsampled_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create partitions covering the existing data and today
+DO $$
+DECLARE
+	first_day DATE;
+	d DATE;
+BEGIN
+	SELECT LEAST(
+		(SELECT MIN(COALESCE(s.timestamp, p.last_updated)) FROM pilots_legacy p LEFT JOIN snapshots s ON s.id = p.snapshot_id),
+		(SELECT MIN(COALESCE(s.timestamp, c.last_updated)) FROM controllers_legacy c LEFT JOIN snapshots s ON s.id = c.snapshot_id)
+	) AT TIME ZONE 'UTC' INTO first_day;
+
+	d := COALESCE(first_day, (NOW() AT TIME ZONE 'UTC')::date);
+	WHILE d <= (NOW() AT TIME ZONE 'UTC')::date + 1 LOOP
+		PERFORM create_snapshot_partitions(d);
+		INSERT INTO snapshot_partitions (day) VALUES (d) ON CONFLICT DO NOTHING;
+		d := d + 1;
+	END LOOP;
+END;
+$$;
+
+INSERT INTO pilots (
+	id, snapshot_id, snapshot_time, cid, name, callsign, server,
+	pilot_rating, military_rating, latitude, longitude,
+	altitude, groundspeed, transponder, heading,
+	qnh_i_hg, qnh_mb, logon_time, last_updated
+)
+SELECT
+	p.id, p.snapshot_id, COALESCE(s.timestamp, p.last_updated), p.cid, p.name, p.callsign, p.server,
+	p.pilot_rating, p.military_rating, p.latitude, p.longitude,
+	p.altitude, p.groundspeed, p.transponder, p.heading,
+	p.qnh_i_hg, p.qnh_mb, p.logon_time, p.last_updated
+FROM pilots_legacy p
+LEFT JOIN snapshots s ON s.id = p.snapshot_id;
+
+INSERT INTO controllers (
+	id, snapshot_id, snapshot_time, cid, name, callsign, frequency,
+	facility, rating, server, visual_range, text_atis,
+	last_updated, logon_time
+)
+SELECT
+	c.id, c.snapshot_id, COALESCE(s.timestamp, c.last_updated), c.cid, c.name, c.callsign, c.frequency,
+	c.facility, c.rating, c.server, c.visual_range, c.text_atis,
+	c.last_updated, c.logon_time
+FROM controllers_legacy c
+LEFT JOIN snapshots s ON s.id = c.snapshot_id;
+
+INSERT INTO flight_plans (
+	id, pilot_id, snapshot_time, flight_rules, aircraft, aircraft_faa,
+	aircraft_short, departure, arrival, alternate,
+	cruise_tas, altitude, deptime, enroute_time,
+	fuel_time, remarks, route, revision_id,
+	assigned_transponder
+)
+SELECT
+	fp.id, fp.pilot_id, COALESCE(s.timestamp, p.last_updated), fp.flight_rules, fp.aircraft, fp.aircraft_faa,
+	fp.aircraft_short, fp.departure, fp.arrival, fp.alternate,
+	fp.cruise_tas, fp.altitude, fp.deptime, fp.enroute_time,
+	fp.fuel_time, fp.remarks, fp.route, fp.revision_id,
+	fp.assigned_transponder
+FROM flight_plans_legacy fp
+JOIN pilots_legacy p ON p.id = fp.pilot_id
+LEFT JOIN snapshots s ON s.id = p.snapshot_id;
+
+SELECT setval('pilots_id_seq', COALESCE((SELECT MAX(id) FROM pilots), 0) + 1, false);
+SELECT setval('controllers_id_seq', COALESCE((SELECT MAX(id) FROM controllers), 0) + 1, false);
+SELECT setval('flight_plans_id_seq', COALESCE((SELECT MAX(id) FROM flight_plans), 0) + 1, false);
+
+DROP TABLE flight_plans_legacy;
+DROP TABLE pilots_legacy;
+DROP TABLE controllers_legacy;
+
+CREATE INDEX idx_pilots_snapshot ON pilots(snapshot_id);
+CREATE INDEX idx_pilots_cid ON pilots(cid, last_updated);
+CREATE INDEX idx_pilots_last_updated ON pilots(last_updated);
+CREATE INDEX idx_controllers_snapshot ON controllers(snapshot_id);
+CREATE INDEX idx_controllers_callsign ON controllers(callsign);
+CREATE INDEX idx_flight_plans_pilot ON flight_plans(pilot_id);
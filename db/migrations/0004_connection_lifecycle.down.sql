@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_connections_open;
+ALTER TABLE connections DROP COLUMN closed;
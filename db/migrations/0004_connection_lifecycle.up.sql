@@ -0,0 +1,31 @@
+-- Sessions are now opened once and closed in place. Track which rows are
+-- still open so the collector can resume them after a restart.
+ALTER TABLE connections ADD COLUMN closed BOOLEAN NOT NULL DEFAULT false;
+
+-- Until now a disconnect inserted a second row for the session instead of
+-- finalizing the first. Remove those duplicates, keeping the row that
+-- carries the session statistics.
+DELETE FROM connections c
+USING connections keep
+WHERE c.vatsim_id = keep.vatsim_id
+AND c.type = keep.type
+AND c.callsign = keep.callsign
+AND c.start_time = keep.start_time
+AND c.id <> keep.id
+AND (
+	EXISTS (SELECT 1 FROM pilot_stats s WHERE s.connection_id = keep.id)
+	OR EXISTS (SELECT 1 FROM atc_stats s WHERE s.connection_id = keep.id)
+	OR EXISTS (SELECT 1 FROM atis_stats s WHERE s.connection_id = keep.id)
+)
+AND NOT EXISTS (SELECT 1 FROM pilot_stats s WHERE s.connection_id = c.id)
+AND NOT EXISTS (SELECT 1 FROM atc_stats s WHERE s.connection_id = c.id)
+AND NOT EXISTS (SELECT 1 FROM atis_stats s WHERE s.connection_id = c.id);
+
+-- Everything except sessions seen in the last few minutes is finished
+UPDATE connections SET closed = true
+WHERE end_time < NOW() - INTERVAL '5 minutes'
+OR EXISTS (SELECT 1 FROM pilot_stats s WHERE s.connection_id = connections.id)
+OR EXISTS (SELECT 1 FROM atc_stats s WHERE s.connection_id = connections.id)
+OR EXISTS (SELECT 1 FROM atis_stats s WHERE s.connection_id = connections.id);
+
+CREATE INDEX idx_connections_open ON connections(id) WHERE NOT closed;
@@ -0,0 +1,20 @@
+DROP FUNCTION IF EXISTS recompute_pilot_total_stats();
+
+ALTER TABLE pilot_total_stats
+	ALTER COLUMN total_seconds TYPE INTEGER USING total_seconds / 3600,
+	ALTER COLUMN student_seconds TYPE INTEGER USING student_seconds / 3600,
+	ALTER COLUMN ppl_seconds TYPE INTEGER USING ppl_seconds / 3600,
+	ALTER COLUMN instrument_seconds TYPE INTEGER USING instrument_seconds / 3600,
+	ALTER COLUMN cpl_seconds TYPE INTEGER USING cpl_seconds / 3600,
+	ALTER COLUMN atpl_seconds TYPE INTEGER USING atpl_seconds / 3600;
+ALTER TABLE pilot_total_stats RENAME COLUMN total_seconds TO total_hours;
+ALTER TABLE pilot_total_stats RENAME COLUMN student_seconds TO student_hours;
+ALTER TABLE pilot_total_stats RENAME COLUMN ppl_seconds TO ppl_hours;
+ALTER TABLE pilot_total_stats RENAME COLUMN instrument_seconds TO instrument_hours;
+ALTER TABLE pilot_total_stats RENAME COLUMN cpl_seconds TO cpl_hours;
+ALTER TABLE pilot_total_stats RENAME COLUMN atpl_seconds TO atpl_hours;
+
+ALTER TABLE atc_stats DROP COLUMN online_seconds;
+
+ALTER TABLE pilot_stats ALTER COLUMN flight_seconds TYPE INTEGER USING flight_seconds / 3600;
+ALTER TABLE pilot_stats RENAME COLUMN flight_seconds TO flight_time;
@@ -0,0 +1,61 @@
+-- Store session and total time in seconds instead of truncated hours
+
+ALTER TABLE pilot_stats RENAME COLUMN flight_time TO flight_seconds;
+ALTER TABLE pilot_stats ALTER COLUMN flight_seconds TYPE BIGINT;
+
+ALTER TABLE atc_stats ADD COLUMN online_seconds BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE pilot_total_stats RENAME COLUMN total_hours TO total_seconds;
+ALTER TABLE pilot_total_stats RENAME COLUMN student_hours TO student_seconds;
+ALTER TABLE pilot_total_stats RENAME COLUMN ppl_hours TO ppl_seconds;
+ALTER TABLE pilot_total_stats RENAME COLUMN instrument_hours TO instrument_seconds;
+ALTER TABLE pilot_total_stats RENAME COLUMN cpl_hours TO cpl_seconds;
+ALTER TABLE pilot_total_stats RENAME COLUMN atpl_hours TO atpl_seconds;
+ALTER TABLE pilot_total_stats
+	ALTER COLUMN total_seconds TYPE BIGINT,
+	ALTER COLUMN student_seconds TYPE BIGINT,
+	ALTER COLUMN ppl_seconds TYPE BIGINT,
+	ALTER COLUMN instrument_seconds TYPE BIGINT,
+	ALTER COLUMN cpl_seconds TYPE BIGINT,
+	ALTER COLUMN atpl_seconds TYPE BIGINT;
+
+-- Rebuilds session times and pilot_total_stats from closed connections
+CREATE OR REPLACE FUNCTION recompute_pilot_total_stats() RETURNS void AS $$
+BEGIN
+	LOCK TABLE pilot_total_stats IN EXCLUSIVE MODE;
+
+	UPDATE pilot_stats ps
+	SET flight_seconds = GREATEST(EXTRACT(EPOCH FROM c.end_time - c.start_time), 0)::bigint
+	FROM connections c
+	WHERE c.id = ps.connection_id;
+
+	UPDATE atc_stats s
+	SET online_seconds = GREATEST(EXTRACT(EPOCH FROM c.end_time - c.start_time), 0)::bigint
+	FROM connections c
+	WHERE c.id = s.connection_id;
+
+	DELETE FROM pilot_total_stats;
+
+	INSERT INTO pilot_total_stats (
+		vatsim_id, total_seconds, total_flights,
+		student_seconds, ppl_seconds, instrument_seconds,
+		cpl_seconds, atpl_seconds, last_updated
+	)
+	SELECT
+		c.vatsim_id,
+		SUM(ps.flight_seconds),
+		COUNT(*),
+		SUM(CASE WHEN ps.pilot_rating = 1 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 2 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 3 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 4 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 5 THEN ps.flight_seconds ELSE 0 END),
+		MAX(c.end_time)
+	FROM connections c
+	JOIN pilot_stats ps ON ps.connection_id = c.id
+	WHERE c.type = 1
+	GROUP BY c.vatsim_id;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT recompute_pilot_total_stats();
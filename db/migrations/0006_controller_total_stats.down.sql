@@ -0,0 +1,4 @@
+DROP FUNCTION IF EXISTS recompute_controller_total_stats();
+DROP FUNCTION IF EXISTS controller_position(TEXT);
+DROP TABLE IF EXISTS controller_rating_stats;
+DROP TABLE IF EXISTS controller_total_stats;
@@ -0,0 +1,78 @@
+-- Aggregated controller time per member, per position type and per rating
+
+CREATE TABLE controller_total_stats (
+	vatsim_id VARCHAR(20) PRIMARY KEY,
+	total_seconds BIGINT NOT NULL DEFAULT 0,
+	sessions INTEGER NOT NULL DEFAULT 0,
+	del_seconds BIGINT NOT NULL DEFAULT 0,
+	gnd_seconds BIGINT NOT NULL DEFAULT 0,
+	twr_seconds BIGINT NOT NULL DEFAULT 0,
+	app_seconds BIGINT NOT NULL DEFAULT 0,
+	ctr_seconds BIGINT NOT NULL DEFAULT 0,
+	fss_seconds BIGINT NOT NULL DEFAULT 0,
+	other_seconds BIGINT NOT NULL DEFAULT 0,
+	last_updated TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE TABLE controller_rating_stats (
+	vatsim_id VARCHAR(20) NOT NULL,
+	rating INTEGER NOT NULL,
+	total_seconds BIGINT NOT NULL DEFAULT 0,
+	sessions INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (vatsim_id, rating)
+);
+
+-- Position type of a controller callsign by its suffix (DEP counts as APP)
+CREATE OR REPLACE FUNCTION controller_position(callsign TEXT) RETURNS TEXT AS $$
+	SELECT CASE substring(upper(callsign) from '_([A-Z]+)$')
+		WHEN 'DEL' THEN 'DEL'
+		WHEN 'GND' THEN 'GND'
+		WHEN 'TWR' THEN 'TWR'
+		WHEN 'APP' THEN 'APP'
+		WHEN 'DEP' THEN 'APP'
+		WHEN 'CTR' THEN 'CTR'
+		WHEN 'FSS' THEN 'FSS'
+		ELSE 'OTHER'
+	END
+$$ LANGUAGE sql IMMUTABLE;
+
+-- Rebuilds controller totals from closed ATC connections
+CREATE OR REPLACE FUNCTION recompute_controller_total_stats() RETURNS void AS $$
+BEGIN
+	LOCK TABLE controller_total_stats, controller_rating_stats IN EXCLUSIVE MODE;
+
+	DELETE FROM controller_total_stats;
+	DELETE FROM controller_rating_stats;
+
+	INSERT INTO controller_total_stats (
+		vatsim_id, total_seconds, sessions,
+		del_seconds, gnd_seconds, twr_seconds, app_seconds,
+		ctr_seconds, fss_seconds, other_seconds, last_updated
+	)
+	SELECT
+		c.vatsim_id,
+		SUM(s.online_seconds),
+		COUNT(*),
+		SUM(CASE WHEN controller_position(c.callsign) = 'DEL' THEN s.online_seconds ELSE 0 END),
+		SUM(CASE WHEN controller_position(c.callsign) = 'GND' THEN s.online_seconds ELSE 0 END),
+		SUM(CASE WHEN controller_position(c.callsign) = 'TWR' THEN s.online_seconds ELSE 0 END),
+		SUM(CASE WHEN controller_position(c.callsign) = 'APP' THEN s.online_seconds ELSE 0 END),
+		SUM(CASE WHEN controller_position(c.callsign) = 'CTR' THEN s.online_seconds ELSE 0 END),
+		SUM(CASE WHEN controller_position(c.callsign) = 'FSS' THEN s.online_seconds ELSE 0 END),
+		SUM(CASE WHEN controller_position(c.callsign) = 'OTHER' THEN s.online_seconds ELSE 0 END),
+		MAX(c.end_time)
+	FROM connections c
+	JOIN atc_stats s ON s.connection_id = c.id
+	WHERE c.type = 2
+	GROUP BY c.vatsim_id;
+
+	INSERT INTO controller_rating_stats (vatsim_id, rating, total_seconds, sessions)
+	SELECT c.vatsim_id, c.rating, SUM(s.online_seconds), COUNT(*)
+	FROM connections c
+	JOIN atc_stats s ON s.connection_id = c.id
+	WHERE c.type = 2
+	GROUP BY c.vatsim_id, c.rating;
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT recompute_controller_total_stats();
@@ -0,0 +1,14 @@
+-- Flight phase transitions detected from pilot positions
+
+CREATE TABLE flight_phases (
+	id BIGSERIAL PRIMARY KEY,
+	connection_id BIGINT NOT NULL REFERENCES connections(id),
+	phase VARCHAR(16) NOT NULL,
+	started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	latitude DOUBLE PRECISION NOT NULL,
+	longitude DOUBLE PRECISION NOT NULL,
+	altitude INTEGER NOT NULL,
+	groundspeed INTEGER NOT NULL
+);
+
+CREATE INDEX idx_flight_phases_connection ON flight_phases(connection_id, started_at);
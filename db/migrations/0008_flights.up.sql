@@ -0,0 +1,26 @@
+-- One row per pilot session with the filed plan and actual movement times
+
+CREATE TABLE flights (
+	connection_id BIGINT PRIMARY KEY REFERENCES connections(id),
+	departure VARCHAR(16),
+	arrival VARCHAR(16),
+	filed_deptime VARCHAR(4),
+	filed_enroute_time VARCHAR(4),
+	off_block_time TIMESTAMP WITH TIME ZONE,
+	takeoff_time TIMESTAMP WITH TIME ZONE,
+	landing_time TIMESTAMP WITH TIME ZONE,
+	on_block_time TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_flights_departure ON flights(departure, takeoff_time);
+CREATE INDEX idx_flights_arrival ON flights(arrival, landing_time);
+
+-- Approximate the times of flights already followed through their phases
+INSERT INTO flights (connection_id, off_block_time, takeoff_time, landing_time)
+SELECT
+	connection_id,
+	MIN(started_at) FILTER (WHERE phase = 'taxi_out'),
+	MIN(started_at) FILTER (WHERE phase = 'climb'),
+	MAX(started_at) FILTER (WHERE phase = 'landed')
+FROM flight_phases
+GROUP BY connection_id;
@@ -0,0 +1,20 @@
+-- Airport reference data, imported from an OurAirports style CSV
+
+CREATE TABLE airports (
+	ident VARCHAR(16) PRIMARY KEY,
+	type VARCHAR(32) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	latitude DOUBLE PRECISION NOT NULL,
+	longitude DOUBLE PRECISION NOT NULL,
+	elevation_ft INTEGER,
+	continent VARCHAR(2),
+	iso_country VARCHAR(2),
+	iso_region VARCHAR(16),
+	municipality VARCHAR(255),
+	icao_code VARCHAR(4),
+	gps_code VARCHAR(16),
+	iata_code VARCHAR(3)
+);
+
+CREATE INDEX idx_airports_icao_code ON airports(icao_code);
+CREATE INDEX idx_airports_gps_code ON airports(gps_code);
@@ -0,0 +1,12 @@
+-- Latest position of every connected pilot, replaced each snapshot and
+-- spatially indexed for map queries. position is (longitude, latitude).
+
+CREATE TABLE live_positions (
+	pilot_id BIGINT PRIMARY KEY,
+	snapshot_time TIMESTAMP WITH TIME ZONE NOT NULL,
+	position POINT NOT NULL,
+	altitude INTEGER NOT NULL,
+	groundspeed INTEGER NOT NULL
+);
+
+CREATE INDEX idx_live_positions_position ON live_positions USING gist (position);
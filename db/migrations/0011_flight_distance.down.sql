@@ -0,0 +1,44 @@
+-- Restore the recompute function of 0005, which has no distances
+
+-- Rebuilds session times and pilot_total_stats from closed connections
+CREATE OR REPLACE FUNCTION recompute_pilot_total_stats() RETURNS void AS $$
+BEGIN
+	LOCK TABLE pilot_total_stats IN EXCLUSIVE MODE;
+
+	UPDATE pilot_stats ps
+	SET flight_seconds = GREATEST(EXTRACT(EPOCH FROM c.end_time - c.start_time), 0)::bigint
+	FROM connections c
+	WHERE c.id = ps.connection_id;
+
+	UPDATE atc_stats s
+	SET online_seconds = GREATEST(EXTRACT(EPOCH FROM c.end_time - c.start_time), 0)::bigint
+	FROM connections c
+	WHERE c.id = s.connection_id;
+
+	DELETE FROM pilot_total_stats;
+
+	INSERT INTO pilot_total_stats (
+		vatsim_id, total_seconds, total_flights,
+		student_seconds, ppl_seconds, instrument_seconds,
+		cpl_seconds, atpl_seconds, last_updated
+	)
+	SELECT
+		c.vatsim_id,
+		SUM(ps.flight_seconds),
+		COUNT(*),
+		SUM(CASE WHEN ps.pilot_rating = 1 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 2 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 3 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 4 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 5 THEN ps.flight_seconds ELSE 0 END),
+		MAX(c.end_time)
+	FROM connections c
+	JOIN pilot_stats ps ON ps.connection_id = c.id
+	WHERE c.type = 1
+	GROUP BY c.vatsim_id;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE pilot_total_stats DROP COLUMN total_distance_nm;
+ALTER TABLE pilot_stats DROP COLUMN distance_nm;
+ALTER TABLE connections DROP COLUMN distance_nm;
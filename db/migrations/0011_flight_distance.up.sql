@@ -0,0 +1,48 @@
+-- Great-circle distance flown per pilot session and in total. The
+-- collector accumulates it on the open connection every snapshot.
+
+ALTER TABLE connections ADD COLUMN distance_nm DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE pilot_stats ADD COLUMN distance_nm DOUBLE PRECISION NOT NULL DEFAULT 0;
+ALTER TABLE pilot_total_stats ADD COLUMN total_distance_nm DOUBLE PRECISION NOT NULL DEFAULT 0;
+
+-- Rebuilds session times and distances and pilot_total_stats from closed
+-- connections
+CREATE OR REPLACE FUNCTION recompute_pilot_total_stats() RETURNS void AS $$
+BEGIN
+	LOCK TABLE pilot_total_stats IN EXCLUSIVE MODE;
+
+	UPDATE pilot_stats ps
+	SET flight_seconds = GREATEST(EXTRACT(EPOCH FROM c.end_time - c.start_time), 0)::bigint,
+		distance_nm = c.distance_nm
+	FROM connections c
+	WHERE c.id = ps.connection_id;
+
+	UPDATE atc_stats s
+	SET online_seconds = GREATEST(EXTRACT(EPOCH FROM c.end_time - c.start_time), 0)::bigint
+	FROM connections c
+	WHERE c.id = s.connection_id;
+
+	DELETE FROM pilot_total_stats;
+
+	INSERT INTO pilot_total_stats (
+		vatsim_id, total_seconds, total_flights,
+		student_seconds, ppl_seconds, instrument_seconds,
+		cpl_seconds, atpl_seconds, total_distance_nm, last_updated
+	)
+	SELECT
+		c.vatsim_id,
+		SUM(ps.flight_seconds),
+		COUNT(*),
+		SUM(CASE WHEN ps.pilot_rating = 1 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 2 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 3 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 4 THEN ps.flight_seconds ELSE 0 END),
+		SUM(CASE WHEN ps.pilot_rating = 5 THEN ps.flight_seconds ELSE 0 END),
+		SUM(ps.distance_nm),
+		MAX(c.end_time)
+	FROM connections c
+	JOIN pilot_stats ps ON ps.connection_id = c.id
+	WHERE c.type = 1
+	GROUP BY c.vatsim_id;
+END;
+$$ LANGUAGE plpgsql;
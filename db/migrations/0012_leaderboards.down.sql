@@ -0,0 +1,3 @@
+DROP MATERIALIZED VIEW IF EXISTS leaderboard_rankings;
+DROP FUNCTION IF EXISTS callsign_facility(TEXT);
+DROP INDEX IF EXISTS idx_connections_end_time;
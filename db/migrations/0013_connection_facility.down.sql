@@ -0,0 +1,114 @@
+DROP MATERIALIZED VIEW leaderboard_rankings;
+
+-- One row per ranked member of each board, period and facility. Values
+-- are seconds for the hours boards, nautical miles for distance and counts
+-- otherwise. facility is empty except on per-facility controller boards.
+-- Rolling windows are computed from connections, clipped to the window,
+-- and include open sessions; all time uses the accumulated totals.
+CREATE MATERIALIZED VIEW leaderboard_rankings AS
+WITH periods (period, since) AS (
+	VALUES
+		('day', NOW() - INTERVAL '1 day'),
+		('week', NOW() - INTERVAL '7 days'),
+		('month', NOW() - INTERVAL '30 days')
+),
+windowed AS (
+	SELECT
+		p.period,
+		c.vatsim_id,
+		c.type,
+		CASE WHEN c.type = 2 THEN callsign_facility(c.callsign) ELSE '' END AS facility,
+		GREATEST(EXTRACT(EPOCH FROM c.end_time - GREATEST(c.start_time, p.since)), 0) AS seconds,
+		c.distance_nm
+	FROM periods p
+	JOIN connections c ON c.end_time >= p.since
+	WHERE c.type IN (1, 2)
+),
+visited AS (
+	SELECT c.vatsim_id, f.departure AS airport, f.takeoff_time AS visited_at
+	FROM flights f
+	JOIN connections c ON c.id = f.connection_id
+	WHERE f.takeoff_time IS NOT NULL AND f.departure <> ''
+	UNION ALL
+	SELECT c.vatsim_id, f.arrival, f.landing_time
+	FROM flights f
+	JOIN connections c ON c.id = f.connection_id
+	WHERE f.landing_time IS NOT NULL AND f.arrival <> ''
+),
+scores (board, period, facility, vatsim_id, value) AS (
+	SELECT 'pilot_hours', period, '', vatsim_id, SUM(seconds)
+	FROM windowed WHERE type = 1
+	GROUP BY period, vatsim_id
+	UNION ALL
+	SELECT 'pilot_hours', 'all', '', vatsim_id, total_seconds
+	FROM pilot_total_stats
+
+	UNION ALL
+	SELECT 'distance', period, '', vatsim_id, SUM(distance_nm)
+	FROM windowed WHERE type = 1
+	GROUP BY period, vatsim_id
+	UNION ALL
+	SELECT 'distance', 'all', '', vatsim_id, total_distance_nm
+	FROM pilot_total_stats
+
+	UNION ALL
+	SELECT 'controller_hours', period, '', vatsim_id, SUM(seconds)
+	FROM windowed WHERE type = 2
+	GROUP BY period, vatsim_id
+	UNION ALL
+	SELECT 'controller_hours', period, facility, vatsim_id, SUM(seconds)
+	FROM windowed WHERE type = 2 AND facility <> ''
+	GROUP BY period, facility, vatsim_id
+	UNION ALL
+	SELECT 'controller_hours', 'all', '', vatsim_id, total_seconds
+	FROM controller_total_stats
+	UNION ALL
+	SELECT 'controller_hours', 'all', callsign_facility(c.callsign), c.vatsim_id, SUM(s.online_seconds)
+	FROM atc_stats s
+	JOIN connections c ON c.id = s.connection_id
+	WHERE callsign_facility(c.callsign) <> ''
+	GROUP BY callsign_facility(c.callsign), c.vatsim_id
+
+	UNION ALL
+	SELECT 'sessions', period, '', vatsim_id, COUNT(*)
+	FROM windowed
+	GROUP BY period, vatsim_id
+	UNION ALL
+	SELECT 'sessions', 'all', '', vatsim_id, SUM(sessions)
+	FROM (
+		SELECT vatsim_id, total_flights AS sessions FROM pilot_total_stats
+		UNION ALL
+		SELECT vatsim_id, sessions FROM controller_total_stats
+	) totals
+	GROUP BY vatsim_id
+
+	UNION ALL
+	SELECT 'airports', p.period, '', v.vatsim_id, COUNT(DISTINCT v.airport)
+	FROM periods p
+	JOIN visited v ON v.visited_at >= p.since
+	GROUP BY p.period, v.vatsim_id
+	UNION ALL
+	SELECT 'airports', 'all', '', vatsim_id, COUNT(DISTINCT airport)
+	FROM visited
+	GROUP BY vatsim_id
+),
+ranked AS (
+	SELECT
+		board, period, facility, vatsim_id, value::double precision AS value,
+		RANK() OVER (PARTITION BY board, period, facility ORDER BY value DESC) AS rank
+	FROM scores
+	WHERE value > 0
+)
+SELECT board, period, facility, rank::integer AS rank, vatsim_id, value, NOW() AS refreshed_at
+FROM ranked
+WHERE rank <= 100;
+
+-- Required to refresh the view concurrently
+CREATE UNIQUE INDEX idx_leaderboard_rankings_member
+	ON leaderboard_rankings(board, period, facility, vatsim_id);
+CREATE INDEX idx_leaderboard_rankings_rank
+	ON leaderboard_rankings(board, period, facility, rank);
+
+DROP INDEX IF EXISTS idx_connections_facility;
+ALTER TABLE connections DROP COLUMN facility;
+ALTER TABLE connections DROP COLUMN frequency;
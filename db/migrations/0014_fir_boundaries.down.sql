@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_pilots_fir;
+
+ALTER TABLE live_positions DROP COLUMN IF EXISTS fir;
+ALTER TABLE pilots DROP COLUMN IF EXISTS fir;
+
+DROP TABLE IF EXISTS fir_boundaries;
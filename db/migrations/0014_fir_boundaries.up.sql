@@ -0,0 +1,18 @@
+-- FIR and UIR boundaries, imported from VATSpy style GeoJSON. geometry
+-- holds the coordinates of a GeoJSON MultiPolygon.
+
+CREATE TABLE fir_boundaries (
+	id VARCHAR(16) PRIMARY KEY,
+	name VARCHAR(255),
+	region VARCHAR(16),
+	division VARCHAR(16),
+	oceanic BOOLEAN NOT NULL DEFAULT false,
+	geometry JSONB NOT NULL
+);
+
+-- The boundary the collector located each pilot position in, NULL when
+-- outside every boundary or recorded before boundaries were imported
+ALTER TABLE pilots ADD COLUMN fir VARCHAR(16);
+ALTER TABLE live_positions ADD COLUMN fir VARCHAR(16);
+
+CREATE INDEX idx_pilots_fir ON pilots(fir, snapshot_time) WHERE fir IS NOT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE server_stats DROP COLUMN IF EXISTS client_connections_allowed;
+ALTER TABLE server_stats DROP COLUMN IF EXISTS location;
+ALTER TABLE server_stats DROP COLUMN IF EXISTS hostname;
+
+DROP TABLE IF EXISTS servers;
@@ -0,0 +1,16 @@
+-- Network server metadata from the server list and the data feed, and the
+-- metadata each server had when its user count was recorded
+
+CREATE TABLE servers (
+	ident VARCHAR(64) PRIMARY KEY,
+	name VARCHAR(64) NOT NULL,
+	hostname VARCHAR(255),
+	location VARCHAR(255),
+	client_connections_allowed BOOLEAN NOT NULL,
+	is_sweatbox BOOLEAN NOT NULL DEFAULT false,
+	updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+ALTER TABLE server_stats ADD COLUMN hostname TEXT;
+ALTER TABLE server_stats ADD COLUMN location TEXT;
+ALTER TABLE server_stats ADD COLUMN client_connections_allowed BOOLEAN;
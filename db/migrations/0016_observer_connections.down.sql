@@ -0,0 +1,13 @@
+-- This migration cannot be fully reversed: which controllers were recorded
+-- as ATIS depended on the controller info text, which is not kept.
+-- Sessions moved between ATC and ATIS keep their new type. Observers return
+-- to ATC with their session time, and the controller totals are rebuilt
+-- so they agree with the statistics left behind.
+INSERT INTO atc_stats (connection_id, online_seconds)
+SELECT id, GREATEST(EXTRACT(EPOCH FROM end_time - start_time), 0)::bigint
+FROM connections
+WHERE type = 4 AND closed;
+
+UPDATE connections SET type = 2, facility = callsign_facility(callsign) WHERE type = 4;
+
+SELECT recompute_controller_total_stats();
@@ -0,0 +1,31 @@
+-- Controllers were recorded as ATIS whenever they published controller
+-- info text, and observers as ATC. Sessions are retyped by callsign,
+-- ignoring case: _ATIS is ATIS, _OBS an observer (type 4) and anything
+-- else ATC. Observers recorded under other callsigns cannot be told apart
+-- and stay ATC.
+
+-- Statistics follow the new type: observers keep none, ATIS sessions
+-- keep ATIS statistics and controllers get their session time
+DELETE FROM atc_stats s USING connections c
+WHERE s.connection_id = c.id AND c.type = 2
+AND (UPPER(c.callsign) LIKE '%\_OBS' OR UPPER(c.callsign) LIKE '%\_ATIS');
+
+DELETE FROM atis_stats s USING connections c
+WHERE s.connection_id = c.id AND c.type = 3 AND UPPER(c.callsign) NOT LIKE '%\_ATIS';
+
+INSERT INTO atc_stats (connection_id, online_seconds)
+SELECT id, GREATEST(EXTRACT(EPOCH FROM end_time - start_time), 0)::bigint
+FROM connections
+WHERE type = 3 AND closed
+AND UPPER(callsign) NOT LIKE '%\_ATIS' AND UPPER(callsign) NOT LIKE '%\_OBS';
+
+INSERT INTO atis_stats (connection_id)
+SELECT id FROM connections
+WHERE type = 2 AND closed AND UPPER(callsign) LIKE '%\_ATIS';
+
+UPDATE connections SET type = 4, facility = NULL
+WHERE type IN (2, 3) AND UPPER(callsign) LIKE '%\_OBS';
+UPDATE connections SET type = 3 WHERE type = 2 AND UPPER(callsign) LIKE '%\_ATIS';
+UPDATE connections SET type = 2 WHERE type = 3 AND UPPER(callsign) NOT LIKE '%\_ATIS';
+
+SELECT recompute_controller_total_stats();
@@ -0,0 +1,3 @@
+ALTER TABLE atis_stats DROP COLUMN IF EXISTS runways;
+
+DROP TABLE IF EXISTS atis_updates;
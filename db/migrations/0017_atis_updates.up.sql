@@ -0,0 +1,15 @@
+-- Every information letter and change of runways an ATIS station
+-- broadcasts, as read from its controller info text. atis_stats keeps the
+-- last broadcast of each session and the number of updates recorded.
+CREATE TABLE atis_updates (
+	connection_id BIGINT NOT NULL REFERENCES connections(id),
+	updated_at TIMESTAMPTZ NOT NULL,
+	letter CHAR(1) NOT NULL,
+	runways TEXT[] NOT NULL DEFAULT '{}',
+	PRIMARY KEY (connection_id, updated_at)
+);
+
+ALTER TABLE atis_stats ADD COLUMN runways TEXT[];
+
+-- Updates were counted from an unused controller counter
+UPDATE atis_stats SET updates = 0;
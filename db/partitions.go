@@ -0,0 +1,227 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// snapshotTables are partitioned by UTC day of snapshot_time. flight_plans
+// must come before pilots so dependent rows are removed first.
+var snapshotTables = []string{"flight_plans", "pilots", "controllers"}
+
+// partitionDateFormat is the suffix format of daily partition names
+const partitionDateFormat = "20060102"
+
+// RetentionPolicy controls how long raw snapshot rows are kept
+type RetentionPolicy struct {
+	// DownsampleAfter thins partitions older than this to one row per
+	// client per minute. Zero disables downsampling.
+	DownsampleAfter time.Duration
+	// Retention drops partitions older than this. Zero keeps them forever.
+	Retention time.Duration
+}
+
+// EnsureSnapshotPartitions creates the daily partitions for the UTC day
+// containing t, if they do not exist yet
+func EnsureSnapshotPartitions(t time.Time) error {
+	day := t.UTC().Truncate(24 * time.Hour)
+
+	if _, err := DB.Exec(`SELECT create_snapshot_partitions($1::date)`, day.Format("2006-01-02")); err != nil {
+		return fmt.Errorf("error creating partitions for %s: %v", day.Format("2006-01-02"), err)
+	}
+
+	_, err := DB.Exec(`
+		INSERT INTO snapshot_partitions (day) VALUES ($1::date)
+		ON CONFLICT (day) DO NOTHING
+	`, day.Format("2006-01-02"))
+	return err
+}
+
+// ApplyRetention downsamples and drops daily snapshot partitions according
+// to the policy. now is the reference time, normally time.Now().
+func ApplyRetention(policy RetentionPolicy, now time.Time) error {
+	days, err := snapshotPartitionDays()
+	if err != nil {
+		return err
+	}
+
+	for _, day := range days {
+		// A partition is eligible once its whole day is older than the limit
+		age := now.Sub(day.Add(24 * time.Hour))
+
+		if policy.Retention > 0 && age >= policy.Retention {
+			if err := dropSnapshotPartitions(day); err != nil {
+				return err
+			}
+			log.Printf("Dropped snapshot partitions for %s", day.Format("2006-01-02"))
+			continue
+		}
+
+		if policy.DownsampleAfter > 0 && age >= policy.DownsampleAfter {
+			downsampled, err := downsampleSnapshotPartitions(day)
+			if err != nil {
+				return err
+			}
+			if downsampled {
+				log.Printf("Downsampled snapshot partitions for %s", day.Format("2006-01-02"))
+			}
+		}
+	}
+
+	return nil
+}
+
+// snapshotPartitionDays lists the days that have a pilots partition
+func snapshotPartitionDays() ([]time.Time, error) {
+	rows, err := DB.Query(`
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		JOIN pg_class p ON p.oid = i.inhparent
+		WHERE p.relname = 'pilots'
+		ORDER BY c.relname
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		day, err := time.Parse(partitionDateFormat, strings.TrimPrefix(name, "pilots_p"))
+		if err != nil {
+			// Not one of our daily partitions
+			continue
+		}
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
+
+// partitionName returns the name of a table's partition for a day
+func partitionName(table string, day time.Time) string {
+	return table + "_p" + day.Format(partitionDateFormat)
+}
+
+// dropSnapshotPartitions removes every snapshot table partition for a day
+// and the snapshots recorded up to the end of it
+func dropSnapshotPartitions(day time.Time) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range snapshotTables {
+		if _, err := tx.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS %s`, partitionName(table, day))); err != nil {
+			return fmt.Errorf("error dropping %s: %v", partitionName(table, day), err)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM snapshots WHERE timestamp < ($1::date + 1)::timestamp AT TIME ZONE 'UTC'`, day.Format("2006-01-02")); err != nil {
+		return fmt.Errorf("error deleting snapshots up to %s: %v", day.Format("2006-01-02"), err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM snapshot_partitions WHERE day = $1::date`, day.Format("2006-01-02")); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// downsampleSnapshotPartitions keeps the first pilot and controller row per
+// client, callsign and minute of a day, together with their flight plans.
+// It reports whether any work was done.
+func downsampleSnapshotPartitions(day time.Time) (bool, error) {
+	var done bool
+	err := DB.QueryRow(`
+		SELECT downsampled_at IS NOT NULL FROM snapshot_partitions WHERE day = $1::date
+	`, day.Format("2006-01-02")).Scan(&done)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if done {
+		return false, nil
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	pilots := partitionName("pilots", day)
+	controllers := partitionName("controllers", day)
+	flightPlans := partitionName("flight_plans", day)
+
+	queries := []string{
+		fmt.Sprintf(`
+			DELETE FROM %[1]s p
+			USING (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (
+						PARTITION BY cid, callsign, date_trunc('minute', snapshot_time)
+						ORDER BY snapshot_time
+					) AS rn
+					FROM %[1]s
+				) ranked
+				WHERE rn > 1
+			) dup
+			WHERE p.id = dup.id
+		`, pilots),
+		fmt.Sprintf(`
+			DELETE FROM %s fp
+			WHERE NOT EXISTS (SELECT 1 FROM %s p WHERE p.id = fp.pilot_id)
+		`, flightPlans, pilots),
+		fmt.Sprintf(`
+			DELETE FROM %[1]s c
+			USING (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (
+						PARTITION BY cid, callsign, date_trunc('minute', snapshot_time)
+						ORDER BY snapshot_time
+					) AS rn
+					FROM %[1]s
+				) ranked
+				WHERE rn > 1
+			) dup
+			WHERE c.id = dup.id
+		`, controllers),
+	}
+
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return false, fmt.Errorf("error downsampling %s: %v", day.Format("2006-01-02"), err)
+		}
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO snapshot_partitions (day, downsampled_at) VALUES ($1::date, NOW())
+		ON CONFLICT (day) DO UPDATE SET downsampled_at = NOW()
+	`, day.Format("2006-01-02"))
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	// Return the freed space to the operating system. The partitions are no
+	// longer written to, so the exclusive lock is harmless.
+	for _, table := range []string{pilots, controllers, flightPlans} {
+		if _, err := DB.Exec(fmt.Sprintf(`VACUUM FULL %s`, table)); err != nil {
+			log.Printf("Error vacuuming %s: %v", table, err)
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,43 @@
+package db
+
+import "fmt"
+
+// RecomputeTotals rebuilds pilot_total_stats and the controller totals, and
+// the per-session times they are derived from, out of the closed
+// connections. It returns the number of pilots and controllers with totals
+// afterwards.
+func RecomputeTotals() (pilots, controllers int, err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT recompute_pilot_total_stats()`); err != nil {
+		return 0, 0, fmt.Errorf("error recomputing pilot totals: %v", err)
+	}
+	if _, err := tx.Exec(`SELECT recompute_controller_total_stats()`); err != nil {
+		return 0, 0, fmt.Errorf("error recomputing controller totals: %v", err)
+	}
+
+	err = tx.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM pilot_total_stats),
+			(SELECT COUNT(*) FROM controller_total_stats)
+	`).Scan(&pilots, &controllers)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return pilots, controllers, tx.Commit()
+}
+
+// HasRecordedData reports whether any snapshot or connection has been
+// recorded
+func HasRecordedData() (bool, error) {
+	var recorded bool
+	err := DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM snapshots) OR EXISTS (SELECT 1 FROM connections)
+	`).Scan(&recorded)
+	return recorded, err
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/vainnor/vatsim-stats/facilities"
+)
+
+// loadFacilities loads the facility registry named by FACILITIES_FILE. It
+// returns nil if none is configured, in which case facilities are told
+// apart by callsign prefix.
+func loadFacilities() (*facilities.Registry, error) {
+	path := os.Getenv("FACILITIES_FILE")
+	if path == "" {
+		return nil, nil
+	}
+
+	registry, err := facilities.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("error loading facilities from %s: %v", path, err)
+	}
+	log.Printf("Loaded %d facilities from %s", registry.Len(), path)
+	return registry, nil
+}
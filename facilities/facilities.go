@@ -0,0 +1,240 @@
+// Package facilities maps controller callsigns, frequencies and airports
+// to named facilities, as defined in a YAML or JSON registry file.
+package facilities
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Facility is a named group of controller positions. A controller belongs
+// to it if its callsign matches one of the patterns and, when frequencies
+// are listed, it is on one of them. Airports are the airports the facility
+// provides service to.
+type Facility struct {
+	ID          string   `json:"id" yaml:"id"`
+	Name        string   `json:"name,omitempty" yaml:"name"`
+	Callsigns   []string `json:"callsigns" yaml:"callsigns"`
+	Frequencies []string `json:"frequencies,omitempty" yaml:"frequencies"`
+	Airports    []string `json:"airports,omitempty" yaml:"airports"`
+
+	patterns []*regexp.Regexp
+}
+
+// file is the layout of a registry file
+type file struct {
+	Facilities []Facility `json:"facilities" yaml:"facilities"`
+}
+
+// Registry holds the facility definitions. A nil registry has no
+// definitions, so every callsign falls back to its prefix facility.
+type Registry struct {
+	facilities []*Facility
+	byID       map[string]*Facility
+	byAirport  map[string][]*Facility
+}
+
+// Load reads a registry file, as JSON if its name ends in .json and as
+// YAML otherwise
+func Load(path string) (*Registry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return Parse(f, strings.EqualFold(filepath.Ext(path), ".json"))
+}
+
+// Parse reads a registry from JSON or YAML
+func Parse(r io.Reader, isJSON bool) (*Registry, error) {
+	var contents file
+	var err error
+	if isJSON {
+		err = json.NewDecoder(r).Decode(&contents)
+	} else {
+		err = yaml.NewDecoder(r).Decode(&contents)
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing facilities: %v", err)
+	}
+
+	return New(contents.Facilities)
+}
+
+// New builds a registry from facility definitions. IDs, airports and
+// callsign patterns are case-insensitive, and IDs must be unique.
+func New(facilities []Facility) (*Registry, error) {
+	r := &Registry{
+		byID:      make(map[string]*Facility),
+		byAirport: make(map[string][]*Facility),
+	}
+
+	for i := range facilities {
+		f := facilities[i]
+		f.ID = strings.ToUpper(strings.TrimSpace(f.ID))
+		if f.ID == "" {
+			return nil, fmt.Errorf("facility %d has no id", i+1)
+		}
+		if _, exists := r.byID[f.ID]; exists {
+			return nil, fmt.Errorf("facility %s is defined twice", f.ID)
+		}
+		if len(f.Callsigns) == 0 {
+			return nil, fmt.Errorf("facility %s has no callsigns", f.ID)
+		}
+
+		for _, pattern := range f.Callsigns {
+			f.patterns = append(f.patterns, compilePattern(pattern))
+		}
+		f.Airports = normalizeAll(f.Airports, normalizeAirport)
+		f.Frequencies = normalizeAll(f.Frequencies, normalizeFrequency)
+
+		r.facilities = append(r.facilities, &f)
+		r.byID[f.ID] = &f
+		for _, airport := range f.Airports {
+			r.byAirport[airport] = append(r.byAirport[airport], &f)
+		}
+	}
+
+	return r, nil
+}
+
+// normalizeAll returns a copy of values with each passed through fn, leaving
+// the caller's slice untouched
+func normalizeAll(values []string, fn func(string) string) []string {
+	normalized := make([]string, len(values))
+	for i, value := range values {
+		normalized[i] = fn(value)
+	}
+	return normalized
+}
+
+// normalizeAirport upper-cases an airport code
+func normalizeAirport(icao string) string {
+	return strings.ToUpper(strings.TrimSpace(icao))
+}
+
+// compilePattern turns a callsign pattern, in which * matches any run of
+// characters, into a case-insensitive regular expression
+func compilePattern(pattern string) *regexp.Regexp {
+	parts := strings.Split(strings.ToUpper(strings.TrimSpace(pattern)), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
+
+// Len returns the number of facilities defined
+func (r *Registry) Len() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.facilities)
+}
+
+// Get returns a facility by ID. Facilities not in the registry are the
+// positions whose callsign starts with the ID, serving the airport of the
+// same code.
+func (r *Registry) Get(id string) *Facility {
+	id = strings.ToUpper(strings.TrimSpace(id))
+	if r != nil {
+		if f, ok := r.byID[id]; ok {
+			return f
+		}
+	}
+	return prefixFacility(id)
+}
+
+// ForAirport returns the facilities providing service to an airport,
+// falling back to the airport's prefix facility if none list it
+func (r *Registry) ForAirport(icao string) []*Facility {
+	icao = strings.ToUpper(strings.TrimSpace(icao))
+	if r != nil {
+		if found := r.byAirport[icao]; len(found) > 0 {
+			return found
+		}
+	}
+	return []*Facility{prefixFacility(icao)}
+}
+
+// Classify returns the ID of the first facility in the registry a
+// controller belongs to, or else the prefix of its callsign
+func (r *Registry) Classify(callsign, frequency string) string {
+	if r != nil {
+		for _, f := range r.facilities {
+			if f.Matches(callsign, frequency) {
+				return f.ID
+			}
+		}
+	}
+	return Prefix(callsign)
+}
+
+// Prefix returns the part of a callsign before the first underscore, the
+// facility of callsigns not in the registry
+func Prefix(callsign string) string {
+	prefix, _, _ := strings.Cut(strings.ToUpper(strings.TrimSpace(callsign)), "_")
+	return prefix
+}
+
+func prefixFacility(id string) *Facility {
+	f := &Facility{ID: id, Callsigns: []string{id + "_*"}, Airports: []string{id}}
+	f.patterns = []*regexp.Regexp{compilePattern(f.Callsigns[0])}
+	return f
+}
+
+// Matches reports whether a controller belongs to the facility
+func (f *Facility) Matches(callsign, frequency string) bool {
+	callsign = strings.ToUpper(strings.TrimSpace(callsign))
+
+	matched := false
+	for _, pattern := range f.patterns {
+		if pattern.MatchString(callsign) {
+			matched = true
+			break
+		}
+	}
+	if !matched || len(f.Frequencies) == 0 {
+		return matched
+	}
+
+	frequency = normalizeFrequency(frequency)
+	for _, freq := range f.Frequencies {
+		if freq == frequency {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeFrequency writes a frequency in MHz with three decimals, as the
+// data feed does, so that 118.5 and 118.500 compare equal
+func normalizeFrequency(frequency string) string {
+	frequency = strings.TrimSpace(frequency)
+	if mhz, err := strconv.ParseFloat(frequency, 64); err == nil {
+		return strconv.FormatFloat(mhz, 'f', 3, 64)
+	}
+	return frequency
+}
+
+// LikePatterns returns the callsign patterns as SQL LIKE patterns, for
+// narrowing a query before matching its rows with Matches
+func (f *Facility) LikePatterns() []string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	patterns := make([]string, 0, len(f.Callsigns))
+	for _, pattern := range f.Callsigns {
+		patterns = append(patterns, replacer.Replace(strings.ToUpper(strings.TrimSpace(pattern))))
+	}
+	return patterns
+}
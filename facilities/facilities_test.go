@@ -0,0 +1,124 @@
+package facilities
+
+import (
+	"strings"
+	"testing"
+)
+
+const sampleYAML = `
+facilities:
+  - id: egll
+    name: London Heathrow
+    callsigns: ["EGLL_*"]
+    airports: [EGLL]
+  - id: LON
+    name: London Control
+    callsigns: ["LON_*CTR", "EGTT_*CTR"]
+    airports: [egll, EGKK]
+  - id: LTC
+    name: London Terminal Control
+    callsigns: ["LTC_*"]
+    frequencies: ["121.275", "118.825"]
+`
+
+func TestParseYAMLAndClassify(t *testing.T) {
+	r, err := Parse(strings.NewReader(sampleYAML), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 3 {
+		t.Fatalf("expected 3 facilities, got %d", r.Len())
+	}
+
+	tests := []struct {
+		callsign, frequency, want string
+	}{
+		{"EGLL_N_APP", "119.725", "EGLL"},
+		{"egll_twr", "118.500", "EGLL"},
+		{"EGTT_CTR", "127.825", "LON"},
+		{"LON_S_CTR", "129.425", "LON"},
+		{"LTC_S_CTR", "121.275", "LTC"},
+		// Positions not in the registry fall back to their prefix, which
+		// here happens to be the same facility
+		{"LTC_N_CTR", "119.780", "LTC"},
+		{"EGKK_TWR", "124.225", "EGKK"},
+	}
+	for _, tt := range tests {
+		if got := r.Classify(tt.callsign, tt.frequency); got != tt.want {
+			t.Errorf("Classify(%s, %s) = %s, want %s", tt.callsign, tt.frequency, got, tt.want)
+		}
+	}
+
+	if !r.Get("ltc").Matches("LTC_S_CTR", "121.2750") {
+		t.Error("frequencies should compare numerically")
+	}
+	if r.Get("LTC").Matches("LTC_S_CTR", "119.780") {
+		t.Error("frequency restriction not applied")
+	}
+}
+
+func TestForAirport(t *testing.T) {
+	r, err := Parse(strings.NewReader(sampleYAML), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serving := r.ForAirport("EGLL")
+	if len(serving) != 2 || serving[0].ID != "EGLL" || serving[1].ID != "LON" {
+		t.Errorf("EGLL should be served by EGLL and LON, got %+v", serving)
+	}
+
+	fallback := r.ForAirport("KJFK")
+	if len(fallback) != 1 || !fallback[0].Matches("KJFK_TWR", "") || fallback[0].Matches("KJFKX_TWR", "") {
+		t.Errorf("unlisted airport should fall back to its prefix facility, got %+v", fallback)
+	}
+
+	var empty *Registry
+	if got := empty.Classify("EDDF_TWR", ""); got != "EDDF" {
+		t.Errorf("nil registry Classify = %s", got)
+	}
+}
+
+func TestNewLeavesInputUnchanged(t *testing.T) {
+	airports := []string{" egll ", "eglc"}
+	frequencies := []string{"118.5"}
+	r, err := New([]Facility{{ID: "LON", Callsigns: []string{"LON_*"}, Airports: airports, Frequencies: frequencies}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if airports[0] != " egll " || airports[1] != "eglc" || frequencies[0] != "118.5" {
+		t.Errorf("New changed the caller's slices: %q %q", airports, frequencies)
+	}
+	if len(r.ForAirport("EGLL")) != 1 || !r.Get("LON").Matches("LON_CTR", "118.500") {
+		t.Error("the registry should use the normalised airports and frequencies")
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	r, err := Parse(strings.NewReader(`{"facilities": [{"id": "NY", "callsigns": ["NY_*", "N90_*"]}]}`), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Classify("N90_APP", "132.400"); got != "NY" {
+		t.Errorf("Classify = %s, want NY", got)
+	}
+
+	patterns := r.Get("NY").LikePatterns()
+	if len(patterns) != 2 || patterns[0] != `NY\_%` {
+		t.Errorf("LikePatterns = %q", patterns)
+	}
+}
+
+func TestParseRejectsInvalid(t *testing.T) {
+	invalid := []string{
+		`{"facilities": [{"callsigns": ["X_*"]}]}`,
+		`{"facilities": [{"id": "X"}]}`,
+		`{"facilities": [{"id": "X", "callsigns": ["X_*"]}, {"id": "x", "callsigns": ["Y_*"]}]}`,
+	}
+	for _, contents := range invalid {
+		if _, err := Parse(strings.NewReader(contents), true); err == nil {
+			t.Errorf("%s should be rejected", contents)
+		}
+	}
+}
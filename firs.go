@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/firs"
+)
+
+// firRefreshInterval is how often the FIR boundaries are reloaded to pick
+// up new imports
+const firRefreshInterval = time.Hour
+
+// loadFIRs loads the FIR boundaries pilot positions are attributed to. It
+// returns nil if the fir_boundaries table cannot be read.
+func loadFIRs() *firs.Index {
+	index, err := firs.LoadIndex()
+	if err != nil {
+		log.Printf("Error loading FIR boundaries: %v", err)
+		return nil
+	}
+	if index.Len() == 0 {
+		log.Printf("No FIR boundaries imported, positions are not attributed to FIRs")
+	} else {
+		log.Printf("Loaded %d FIR boundaries", index.Len())
+	}
+	return index
+}
+
+// refreshFIRs periodically reloads the FIR boundaries
+func refreshFIRs(index *firs.Index) {
+	for {
+		time.Sleep(firRefreshInterval)
+		if err := index.Reload(); err != nil {
+			log.Printf("Error reloading FIR boundaries: %v", err)
+		}
+	}
+}
+
+// loadRegions returns the grouping of network statistics regions: the
+// regions of REGIONS_FILE if set, and otherwise the boundary property named
+// by REGION_GROUPING (fir, division or region, default division)
+func loadRegions() (*firs.Grouping, error) {
+	if path := os.Getenv("REGIONS_FILE"); path != "" {
+		grouping, err := firs.LoadGrouping(path)
+		if err != nil {
+			return nil, fmt.Errorf("error loading regions from %s: %v", path, err)
+		}
+		return grouping, nil
+	}
+
+	property := os.Getenv("REGION_GROUPING")
+	if property == "" {
+		property = firs.GroupDivision
+	}
+	return firs.GroupBy(property)
+}
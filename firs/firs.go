@@ -0,0 +1,42 @@
+// Package firs provides flight information region boundaries: importing
+// them from VATSpy style GeoJSON and finding the region containing a
+// position.
+package firs
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Boundary is the lateral extent of a FIR or UIR
+type Boundary struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Division string `json:"division,omitempty"`
+	Oceanic  bool   `json:"oceanic"`
+	// Polygons of [longitude, latitude] rings, as in the coordinates of a
+	// GeoJSON MultiPolygon
+	Polygons [][][][2]float64 `json:"-"`
+}
+
+// boundaryColumns are the fir_boundaries columns in Boundary field order
+const boundaryColumns = `
+	id, COALESCE(name, ''), COALESCE(region, ''), COALESCE(division, ''),
+	oceanic, geometry`
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanBoundary(row scanner) (*Boundary, error) {
+	var b Boundary
+	var geometry []byte
+	if err := row.Scan(&b.ID, &b.Name, &b.Region, &b.Division, &b.Oceanic, &geometry); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(geometry, &b.Polygons); err != nil {
+		return nil, fmt.Errorf("error decoding boundary of %s: %v", b.ID, err)
+	}
+	return &b, nil
+}
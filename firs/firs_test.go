@@ -0,0 +1,128 @@
+package firs
+
+import (
+	"strings"
+	"testing"
+)
+
+// sampleGeoJSON has a FIR nested in a larger one, a FIR split into two
+// features and an oceanic FIR across the antimeridian
+const sampleGeoJSON = `{
+  "type": "FeatureCollection",
+  "features": [
+    {"type": "Feature", "properties": {"id": "OUTR", "region": "EMEA", "division": "EUD", "oceanic": "0"},
+     "geometry": {"type": "Polygon", "coordinates": [[[0, 40], [20, 40], [20, 60], [0, 60], [0, 40]]]}},
+    {"type": "Feature", "properties": {"id": "innr", "name": "Inner", "oceanic": "0"},
+     "geometry": {"type": "MultiPolygon", "coordinates": [[[[5, 45], [10, 45], [10, 50], [5, 50], [5, 45]]]]}},
+    {"type": "Feature", "properties": {"id": "SPLT", "oceanic": false},
+     "geometry": {"type": "Polygon", "coordinates": [[[30, 0], [31, 0], [31, 1], [30, 1], [30, 0]]]}},
+    {"type": "Feature", "properties": {"id": "SPLT"},
+     "geometry": {"type": "Polygon", "coordinates": [[[40, 0], [41, 0], [41, 1], [40, 1], [40, 0]]]}},
+    {"type": "Feature", "properties": {"id": "NZZO", "region": "APAC", "oceanic": "1"},
+     "geometry": {"type": "Polygon", "coordinates": [[[170, -30], [-170, -30], [-170, -10], [170, -10], [170, -30]]]}}
+  ]
+}`
+
+func TestParseGeoJSON(t *testing.T) {
+	boundaries, err := ParseGeoJSON(strings.NewReader(sampleGeoJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(boundaries) != 4 {
+		t.Fatalf("expected 4 boundaries, got %d", len(boundaries))
+	}
+
+	byID := make(map[string]Boundary)
+	for _, b := range boundaries {
+		byID[b.ID] = b
+	}
+	if b := byID["INNR"]; b.Name != "Inner" || b.Oceanic {
+		t.Errorf("INNR = %+v", b)
+	}
+	if b := byID["OUTR"]; b.Region != "EMEA" || b.Division != "EUD" {
+		t.Errorf("OUTR = %+v", b)
+	}
+	if b := byID["SPLT"]; len(b.Polygons) != 2 {
+		t.Errorf("SPLT has %d polygons, want 2", len(b.Polygons))
+	}
+	if b := byID["NZZO"]; !b.Oceanic {
+		t.Errorf("NZZO should be oceanic")
+	}
+}
+
+func TestParseGeoJSONInvalid(t *testing.T) {
+	for _, doc := range []string{
+		`{"features": [{"properties": {}, "geometry": {"type": "Polygon", "coordinates": []}}]}`,
+		`{"features": [{"properties": {"id": "X"}, "geometry": {"type": "LineString", "coordinates": []}}]}`,
+		`not json`,
+	} {
+		if _, err := ParseGeoJSON(strings.NewReader(doc)); err == nil {
+			t.Errorf("expected an error for %s", doc)
+		}
+	}
+}
+
+func TestIndexLocate(t *testing.T) {
+	boundaries, err := ParseGeoJSON(strings.NewReader(sampleGeoJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := NewIndex(boundaries)
+
+	for _, tc := range []struct {
+		lat, lon float64
+		want     string
+	}{
+		{47, 7, "INNR"},
+		{55, 15, "OUTR"},
+		{0.5, 40.5, "SPLT"},
+		{-20, 179, "NZZO"},
+		{-20, -179, "NZZO"},
+		{0, 0, ""},
+	} {
+		if got := x.FIR(tc.lat, tc.lon); got != tc.want {
+			t.Errorf("FIR(%v, %v) = %q, want %q", tc.lat, tc.lon, got, tc.want)
+		}
+	}
+
+	if b, ok := x.Get("innr"); !ok || b.ID != "INNR" {
+		t.Errorf("Get(innr) = %+v, %v", b, ok)
+	}
+}
+
+func TestGrouping(t *testing.T) {
+	b := &Boundary{ID: "EGTT", Region: "EMEA", Division: "EUD"}
+
+	for property, want := range map[string]string{"fir": "EGTT", "Division": "EUD", "region": "EMEA"} {
+		g, err := GroupBy(property)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := g.Region(b); got != want {
+			t.Errorf("GroupBy(%s).Region = %q, want %q", property, got, want)
+		}
+	}
+	if _, err := GroupBy("country"); err == nil {
+		t.Error("expected an error for an unknown grouping")
+	}
+
+	g, err := ParseGrouping(strings.NewReader(`
+regions:
+  - name: United Kingdom
+    firs: [EGTT, EGPX]
+  - name: Europe
+    firs: ["E*", "L*"]
+`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]string{"EGTT": "United Kingdom", "EDGG": "Europe", "LFFF": "Europe", "KZNY": ""} {
+		if got := g.Region(&Boundary{ID: id}); got != want {
+			t.Errorf("Region(%s) = %q, want %q", id, got, want)
+		}
+	}
+
+	if _, err := ParseGrouping(strings.NewReader(`{"regions": [{"name": "Empty"}]}`), true); err == nil {
+		t.Error("expected an error for a region without FIRs")
+	}
+}
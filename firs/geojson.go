@@ -0,0 +1,95 @@
+package firs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// featureCollection is the part of a GeoJSON document the parser reads
+type featureCollection struct {
+	Features []struct {
+		Properties map[string]interface{} `json:"properties"`
+		Geometry   *struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+	} `json:"features"`
+}
+
+// ParseGeoJSON reads boundaries from a GeoJSON feature collection of
+// Polygon and MultiPolygon features, such as the VATSpy data project's
+// Boundaries.geojson. Each feature needs an id property; name, region,
+// division and oceanic are optional. Features sharing an id are merged
+// into one boundary.
+func ParseGeoJSON(r io.Reader) ([]Boundary, error) {
+	var fc featureCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("error decoding GeoJSON: %v", err)
+	}
+
+	byID := make(map[string]*Boundary)
+	for i, feature := range fc.Features {
+		id := strings.ToUpper(strings.TrimSpace(property(feature.Properties, "id")))
+		if id == "" {
+			return nil, fmt.Errorf("feature %d has no id", i)
+		}
+		if feature.Geometry == nil {
+			continue
+		}
+
+		var polygons [][][][2]float64
+		switch feature.Geometry.Type {
+		case "Polygon":
+			var polygon [][][2]float64
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygon); err != nil {
+				return nil, fmt.Errorf("feature %s: %v", id, err)
+			}
+			polygons = [][][][2]float64{polygon}
+		case "MultiPolygon":
+			if err := json.Unmarshal(feature.Geometry.Coordinates, &polygons); err != nil {
+				return nil, fmt.Errorf("feature %s: %v", id, err)
+			}
+		default:
+			return nil, fmt.Errorf("feature %s has unsupported geometry %q", id, feature.Geometry.Type)
+		}
+
+		b, ok := byID[id]
+		if !ok {
+			b = &Boundary{
+				ID:       id,
+				Name:     property(feature.Properties, "name"),
+				Region:   property(feature.Properties, "region"),
+				Division: property(feature.Properties, "division"),
+			}
+			b.Oceanic, _ = strconv.ParseBool(property(feature.Properties, "oceanic"))
+			byID[id] = b
+		}
+		b.Polygons = append(b.Polygons, polygons...)
+	}
+
+	boundaries := make([]Boundary, 0, len(byID))
+	for _, b := range byID {
+		boundaries = append(boundaries, *b)
+	}
+	sort.Slice(boundaries, func(i, j int) bool { return boundaries[i].ID < boundaries[j].ID })
+	return boundaries, nil
+}
+
+// property returns a feature property as a string. VATSpy writes numbers
+// and flags as strings, other sources as JSON numbers and booleans.
+func property(properties map[string]interface{}, name string) string {
+	switch v := properties[name].(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
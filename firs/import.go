@@ -0,0 +1,67 @@
+package firs
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// Import replaces the fir_boundaries table with the given boundaries in a
+// single transaction. Readers see the previous data until it commits.
+func Import(boundaries []Boundary) error {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM fir_boundaries`); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(pq.CopyIn("fir_boundaries",
+		"id", "name", "region", "division", "oceanic", "geometry"))
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(boundaries))
+	for _, b := range boundaries {
+		if seen[b.ID] {
+			stmt.Close()
+			return fmt.Errorf("duplicate boundary %s", b.ID)
+		}
+		seen[b.ID] = true
+
+		geometry, err := json.Marshal(b.Polygons)
+		if err != nil {
+			stmt.Close()
+			return fmt.Errorf("error encoding boundary %s: %v", b.ID, err)
+		}
+		_, err = stmt.Exec(b.ID, nullable(b.Name), nullable(b.Region), nullable(b.Division), b.Oceanic, string(geometry))
+		if err != nil {
+			stmt.Close()
+			return fmt.Errorf("error copying boundary %s: %v", b.ID, err)
+		}
+	}
+
+	if _, err := stmt.Exec(); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// nullable stores empty strings as NULL
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,155 @@
+package firs
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/vainnor/vatsim-stats/db"
+	"github.com/vainnor/vatsim-stats/geo"
+)
+
+// Index is an in-memory copy of the fir_boundaries table for locating
+// positions on hot paths such as the collector. It is safe for concurrent
+// use.
+type Index struct {
+	mu         sync.RWMutex
+	boundaries []*Boundary
+	byID       map[string]*Boundary
+	// Polygons of all boundaries, smallest first
+	shapes []shape
+}
+
+// shape is one polygon of a boundary
+type shape struct {
+	boundary *Boundary
+	polygon  geo.Polygon
+	area     float64
+}
+
+// NewIndex builds an index from a list of boundaries
+func NewIndex(boundaries []Boundary) *Index {
+	x := &Index{}
+	x.set(boundaries)
+	return x
+}
+
+// LoadIndex builds an index from the fir_boundaries table
+func LoadIndex() (*Index, error) {
+	x := &Index{}
+	if err := x.Reload(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Reload replaces the index contents with the fir_boundaries table
+func (x *Index) Reload() error {
+	rows, err := db.DB.Query(`SELECT ` + boundaryColumns + ` FROM fir_boundaries ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var boundaries []Boundary
+	for rows.Next() {
+		b, err := scanBoundary(rows)
+		if err != nil {
+			return err
+		}
+		boundaries = append(boundaries, *b)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	x.set(boundaries)
+	return nil
+}
+
+// set indexes boundaries by ID and orders their polygons by size, so that
+// a position in a region nested in or overlapping a larger one is
+// attributed to the smaller
+func (x *Index) set(list []Boundary) {
+	boundaries := make([]*Boundary, len(list))
+	byID := make(map[string]*Boundary, len(list))
+	var shapes []shape
+	for i := range list {
+		b := &list[i]
+		boundaries[i] = b
+		byID[b.ID] = b
+		for _, rings := range b.Polygons {
+			polygon := geo.NewPolygon(rings)
+			if !polygon.Empty() {
+				shapes = append(shapes, shape{boundary: b, polygon: polygon, area: polygon.Area()})
+			}
+		}
+	}
+	sort.SliceStable(shapes, func(i, j int) bool { return shapes[i].area < shapes[j].area })
+
+	x.mu.Lock()
+	x.boundaries = boundaries
+	x.byID = byID
+	x.shapes = shapes
+	x.mu.Unlock()
+}
+
+// Len returns the number of boundaries in the index
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.boundaries)
+}
+
+// All returns the boundaries in ID order
+func (x *Index) All() []*Boundary {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.boundaries
+}
+
+// Get returns a boundary by ID
+func (x *Index) Get(id string) (*Boundary, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	b, ok := x.byID[strings.ToUpper(strings.TrimSpace(id))]
+	return b, ok
+}
+
+// Center returns the middle of a boundary's largest polygon
+func (x *Index) Center(id string) (lat, lon float64, ok bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	b, found := x.byID[strings.ToUpper(strings.TrimSpace(id))]
+	if !found {
+		return 0, 0, false
+	}
+	for i := len(x.shapes) - 1; i >= 0; i-- {
+		if x.shapes[i].boundary == b {
+			lat, lon = x.shapes[i].polygon.Center()
+			return lat, lon, true
+		}
+	}
+	return 0, 0, false
+}
+
+// Locate returns the smallest boundary containing a position
+func (x *Index) Locate(lat, lon float64) (*Boundary, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	for _, s := range x.shapes {
+		if s.polygon.Contains(lat, lon) {
+			return s.boundary, true
+		}
+	}
+	return nil, false
+}
+
+// FIR returns the ID of the smallest boundary containing a position, or ""
+// if none does
+func (x *Index) FIR(lat, lon float64) string {
+	if b, ok := x.Locate(lat, lon); ok {
+		return b.ID
+	}
+	return ""
+}
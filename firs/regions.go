@@ -0,0 +1,126 @@
+package firs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Groupings by a boundary property
+const (
+	GroupFIR      = "fir"
+	GroupDivision = "division"
+	GroupRegion   = "region"
+	// GroupCustom is the grouping of a regions file
+	GroupCustom = "custom"
+)
+
+// Grouping assigns FIRs to the regions traffic is reported by
+type Grouping struct {
+	name    string
+	regions []region
+}
+
+// region is a named group of FIRs in a regions file
+type region struct {
+	Name string   `json:"name" yaml:"name"`
+	FIRs []string `json:"firs" yaml:"firs"`
+}
+
+// regionsFile is the layout of a regions file
+type regionsFile struct {
+	Regions []region `json:"regions" yaml:"regions"`
+}
+
+// GroupBy groups FIRs by their ID, VATSIM division or VATSIM region
+func GroupBy(property string) (*Grouping, error) {
+	switch property = strings.ToLower(strings.TrimSpace(property)); property {
+	case GroupFIR, GroupDivision, GroupRegion:
+		return &Grouping{name: property}, nil
+	default:
+		return nil, fmt.Errorf("unknown region grouping %q", property)
+	}
+}
+
+// LoadGrouping reads named regions, such as subdivisions or continents,
+// from a regions file, as JSON if its name ends in .json and as YAML
+// otherwise
+func LoadGrouping(path string) (*Grouping, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseGrouping(f, strings.EqualFold(filepath.Ext(path), ".json"))
+}
+
+// ParseGrouping reads a regions file listing the FIRs of each region. FIR
+// patterns may use * to match any characters, and a FIR belongs to the
+// first region with a pattern matching its ID.
+func ParseGrouping(r io.Reader, isJSON bool) (*Grouping, error) {
+	var contents regionsFile
+	var err error
+	if isJSON {
+		err = json.NewDecoder(r).Decode(&contents)
+	} else {
+		err = yaml.NewDecoder(r).Decode(&contents)
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing regions: %v", err)
+	}
+
+	g := &Grouping{name: GroupCustom}
+	for i, reg := range contents.Regions {
+		reg.Name = strings.TrimSpace(reg.Name)
+		if reg.Name == "" {
+			return nil, fmt.Errorf("region %d has no name", i+1)
+		}
+		if len(reg.FIRs) == 0 {
+			return nil, fmt.Errorf("region %s has no FIRs", reg.Name)
+		}
+		for j, pattern := range reg.FIRs {
+			reg.FIRs[j] = strings.ToUpper(strings.TrimSpace(pattern))
+			if _, err := path.Match(reg.FIRs[j], ""); err != nil {
+				return nil, fmt.Errorf("region %s has an invalid FIR pattern %q", reg.Name, pattern)
+			}
+		}
+		g.regions = append(g.regions, reg)
+	}
+	return g, nil
+}
+
+// Name returns fir, division, region or custom
+func (g *Grouping) Name() string {
+	return g.name
+}
+
+// Region returns the region of a FIR, or "" if it is in none
+func (g *Grouping) Region(b *Boundary) string {
+	switch g.name {
+	case GroupFIR:
+		return b.ID
+	case GroupDivision:
+		return b.Division
+	case GroupRegion:
+		return b.Region
+	}
+
+	for _, reg := range g.regions {
+		for _, pattern := range reg.FIRs {
+			if ok, _ := path.Match(pattern, b.ID); ok {
+				return reg.Name
+			}
+		}
+	}
+	return ""
+}
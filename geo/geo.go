@@ -0,0 +1,205 @@
+// Package geo provides the spherical geometry used for positions on the
+// network: distances and bounding boxes in latitude and longitude degrees.
+package geo
+
+import "math"
+
+// EarthRadiusNM is the mean radius of the earth in nautical miles
+const EarthRadiusNM = 3440.065
+
+// DistanceNM returns the great-circle distance between two points in
+// nautical miles
+func DistanceNM(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) +
+		math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * EarthRadiusNM * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// Box is an area bounded by latitudes and longitudes. A box whose West is
+// greater than its East crosses the antimeridian.
+type Box struct {
+	West, South, East, North float64
+}
+
+// Valid reports whether the box has coordinates within range and its
+// south edge is not north of its north edge
+func (b Box) Valid() bool {
+	return b.South >= -90 && b.North <= 90 && b.South <= b.North &&
+		b.West >= -180 && b.West <= 180 && b.East >= -180 && b.East <= 180
+}
+
+// Split returns the box as boxes that do not cross the antimeridian
+func (b Box) Split() []Box {
+	if b.West <= b.East {
+		return []Box{b}
+	}
+	return []Box{
+		{West: b.West, South: b.South, East: 180, North: b.North},
+		{West: -180, South: b.South, East: b.East, North: b.North},
+	}
+}
+
+// Contains reports whether a point lies within the box
+func (b Box) Contains(lat, lon float64) bool {
+	if lat < b.South || lat > b.North {
+		return false
+	}
+	if b.West <= b.East {
+		return lon >= b.West && lon <= b.East
+	}
+	return lon >= b.West || lon <= b.East
+}
+
+// RadiusBox returns a box containing every point within radiusNM of a
+// center. It spans all longitudes when the circle reaches a pole.
+func RadiusBox(lat, lon, radiusNM float64) Box {
+	dLat := radiusNM / EarthRadiusNM * 180 / math.Pi
+	south, north := lat-dLat, lat+dLat
+	if south <= -90 || north >= 90 {
+		return Box{West: -180, South: math.Max(south, -90), East: 180, North: math.Min(north, 90)}
+	}
+
+	// Widest longitude extent of the circle, reached north or south of
+	// the center's latitude
+	dLon := math.Asin(math.Min(1, math.Sin(radiusNM/EarthRadiusNM)/math.Cos(lat*math.Pi/180))) * 180 / math.Pi
+	if dLon >= 180 {
+		return Box{West: -180, South: south, East: 180, North: north}
+	}
+	return Box{West: wrapLongitude(lon - dLon), South: south, East: wrapLongitude(lon + dLon), North: north}
+}
+
+// wrapLongitude normalizes a longitude to [-180, 180]
+func wrapLongitude(lon float64) float64 {
+	for lon > 180 {
+		lon -= 360
+	}
+	for lon < -180 {
+		lon += 360
+	}
+	return lon
+}
+
+// Midpoint returns the mean of [longitude, latitude] points, with
+// longitudes taken the short way around from the first point
+func Midpoint(points [][2]float64) (lat, lon float64) {
+	for _, pt := range points {
+		lat += pt[1]
+		lon += points[0][0] + wrapLongitude(pt[0]-points[0][0])
+	}
+	n := float64(len(points))
+	return lat / n, wrapLongitude(lon / n)
+}
+
+// Circle returns a closed ring of [longitude, latitude] points
+// approximating the circle of radiusNM around a center. Longitudes stay
+// within 180 degrees of the center's, so a circle crossing the
+// antimeridian continues past it instead of wrapping around the globe.
+func Circle(lat, lon, radiusNM float64, segments int) [][2]float64 {
+	ring := make([][2]float64, 0, segments+1)
+	for i := 0; i < segments; i++ {
+		pLat, pLon := Destination(lat, lon, float64(i)*360/float64(segments), radiusNM)
+		ring = append(ring, [2]float64{lon + wrapLongitude(pLon-lon), pLat})
+	}
+	return append(ring, ring[0])
+}
+
+// Destination returns the point reached from a start by travelling a
+// distance in nautical miles along an initial bearing in degrees
+func Destination(lat, lon, bearing, distanceNM float64) (float64, float64) {
+	phi1 := lat * math.Pi / 180
+	lambda1 := lon * math.Pi / 180
+	theta := bearing * math.Pi / 180
+	delta := distanceNM / EarthRadiusNM
+
+	phi2 := math.Asin(math.Sin(phi1)*math.Cos(delta) + math.Cos(phi1)*math.Sin(delta)*math.Cos(theta))
+	lambda2 := lambda1 + math.Atan2(math.Sin(theta)*math.Sin(delta)*math.Cos(phi1),
+		math.Cos(delta)-math.Sin(phi1)*math.Sin(phi2))
+	return phi2 * 180 / math.Pi, wrapLongitude(lambda2 * 180 / math.Pi)
+}
+
+// Bearing returns the initial bearing in degrees from one point to another
+func Bearing(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dLambda := (lon2 - lon1) * math.Pi / 180
+
+	y := math.Sin(dLambda) * math.Cos(phi2)
+	x := math.Cos(phi1)*math.Sin(phi2) - math.Sin(phi1)*math.Cos(phi2)*math.Cos(dLambda)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
+
+// SegmentDistanceNM returns the distance in nautical miles from a point
+// to the nearest point of the great-circle segment between two others
+func SegmentDistanceNM(lat, lon, lat1, lon1, lat2, lon2 float64) float64 {
+	d13 := DistanceNM(lat1, lon1, lat, lon) / EarthRadiusNM
+	d12 := DistanceNM(lat1, lon1, lat2, lon2) / EarthRadiusNM
+	if d12 == 0 {
+		return d13 * EarthRadiusNM
+	}
+
+	dTheta := (Bearing(lat1, lon1, lat, lon) - Bearing(lat1, lon1, lat2, lon2)) * math.Pi / 180
+	if math.Cos(dTheta) < 0 {
+		// Behind the start of the segment
+		return d13 * EarthRadiusNM
+	}
+
+	crossTrack := math.Asin(math.Sin(d13) * math.Sin(dTheta))
+	alongTrack := math.Acos(math.Max(-1, math.Min(1, math.Cos(d13)/math.Cos(crossTrack))))
+	if alongTrack > d12 {
+		return DistanceNM(lat2, lon2, lat, lon)
+	}
+	return math.Abs(crossTrack) * EarthRadiusNM
+}
+
+// Simplify reduces a line of [longitude, latitude] points with the
+// Douglas-Peucker algorithm, dropping points closer than toleranceNM to
+// the simplified line. It returns the indices of the points kept, always
+// including the first and last.
+func Simplify(points [][2]float64, toleranceNM float64) []int {
+	if len(points) < 3 {
+		keep := make([]int, len(points))
+		for i := range keep {
+			keep[i] = i
+		}
+		return keep
+	}
+
+	kept := make([]bool, len(points))
+	kept[0], kept[len(points)-1] = true, true
+
+	// Ranges still to split, worked through with a stack rather than
+	// recursion so long tracks cannot grow the call stack
+	stack := [][2]int{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		farthest, maxDistance := -1, toleranceNM
+		for i := first + 1; i < last; i++ {
+			d := SegmentDistanceNM(points[i][1], points[i][0],
+				points[first][1], points[first][0], points[last][1], points[last][0])
+			if d > maxDistance {
+				farthest, maxDistance = i, d
+			}
+		}
+		if farthest < 0 {
+			continue
+		}
+
+		kept[farthest] = true
+		stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+	}
+
+	keep := make([]int, 0, len(points))
+	for i, k := range kept {
+		if k {
+			keep = append(keep, i)
+		}
+	}
+	return keep
+}
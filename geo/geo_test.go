@@ -0,0 +1,160 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceNM(t *testing.T) {
+	// KJFK to KLAX is about 2145 nm
+	d := DistanceNM(40.6398, -73.7789, 33.9425, -118.4081)
+	if math.Abs(d-2145) > 5 {
+		t.Errorf("distance = %.1f nm, want about 2145", d)
+	}
+
+	// One degree of latitude is 60 nm
+	if d := DistanceNM(0, 0, 1, 0); math.Abs(d-60) > 0.1 {
+		t.Errorf("one degree = %.2f nm, want 60", d)
+	}
+}
+
+func TestRadiusBoxContainsCircle(t *testing.T) {
+	centers := [][2]float64{{0, 0}, {51.5, -0.1}, {-33.9, 151.2}, {64, 179.5}}
+	for _, c := range centers {
+		box := RadiusBox(c[0], c[1], 100)
+		// Sample points on the circle
+		for bearing := 0.0; bearing < 360; bearing += 10 {
+			lat, lon := Destination(c[0], c[1], bearing, 99.9)
+			if !box.Contains(lat, lon) {
+				t.Errorf("box %+v around %v misses %v,%v", box, c, lat, lon)
+			}
+		}
+	}
+}
+
+func TestBoxSplitAntimeridian(t *testing.T) {
+	box := Box{West: 170, South: -10, East: -170, North: 10}
+	parts := box.Split()
+	if len(parts) != 2 {
+		t.Fatalf("expected two boxes, got %+v", parts)
+	}
+	if !box.Contains(0, 179) || !box.Contains(0, -179) || box.Contains(0, 0) {
+		t.Error("antimeridian box containment is wrong")
+	}
+}
+
+func TestCircle(t *testing.T) {
+	ring := Circle(51.47, -0.45, 30, 36)
+	if len(ring) != 37 || ring[0] != ring[36] {
+		t.Fatalf("expected a closed ring of 37 points, got %d", len(ring))
+	}
+	for _, p := range ring {
+		if d := DistanceNM(51.47, -0.45, p[1], p[0]); math.Abs(d-30) > 0.01 {
+			t.Errorf("point %v is %.3f nm from the center, want 30", p, d)
+		}
+	}
+}
+
+func TestCircleAcrossAntimeridian(t *testing.T) {
+	ring := Circle(10, 179, 120, 36)
+	crossed := false
+	for i, p := range ring {
+		if p[0] > 180 {
+			crossed = true
+		}
+		if i > 0 && math.Abs(p[0]-ring[i-1][0]) > 10 {
+			t.Errorf("edge from %v to %v spans the globe", ring[i-1], p)
+		}
+		if d := DistanceNM(10, 179, p[1], p[0]); math.Abs(d-120) > 0.01 {
+			t.Errorf("point %v is %.3f nm from the center, want 120", p, d)
+		}
+	}
+	if !crossed {
+		t.Error("expected the ring to continue past 180")
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+	lat, lon := Midpoint([][2]float64{{179, 10}, {-179, 20}})
+	if lat != 15 || math.Abs(math.Abs(lon)-180) > 1e-9 {
+		t.Errorf("midpoint across the antimeridian = %v, %v, want 15, 180", lat, lon)
+	}
+}
+
+func TestSegmentDistanceNM(t *testing.T) {
+	// Along the equator, one degree north of the middle is 60 nm away
+	if d := SegmentDistanceNM(1, 5, 0, 0, 0, 10); math.Abs(d-60) > 0.1 {
+		t.Errorf("abeam distance = %.2f nm, want 60", d)
+	}
+	// Beyond the end of the segment the distance is to the endpoint
+	if d := SegmentDistanceNM(0, 12, 0, 0, 0, 10); math.Abs(d-120) > 0.5 {
+		t.Errorf("past the end = %.2f nm, want 120", d)
+	}
+	if d := SegmentDistanceNM(0, -1, 0, 0, 0, 10); math.Abs(d-60) > 0.1 {
+		t.Errorf("before the start = %.2f nm, want 60", d)
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	// A straight line with a small wiggle and one large detour
+	points := [][2]float64{{0, 0}, {1, 0.01}, {2, 0}, {3, 1}, {4, 0}, {5, 0.01}, {6, 0}}
+
+	keep := Simplify(points, 5)
+	want := []int{0, 2, 3, 4, 6}
+	if len(keep) != len(want) {
+		t.Fatalf("kept %v, want %v", keep, want)
+	}
+	for i := range want {
+		if keep[i] != want[i] {
+			t.Fatalf("kept %v, want %v", keep, want)
+		}
+	}
+
+	if keep := Simplify(points, 100); len(keep) != 2 {
+		t.Errorf("a wide tolerance should keep only the endpoints, kept %v", keep)
+	}
+	if keep := Simplify(points[:2], 5); len(keep) != 2 {
+		t.Errorf("two points should both be kept, kept %v", keep)
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	// A square with a square hole
+	square := NewPolygon([][][2]float64{
+		{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {0, 0}},
+		{{4, 4}, {6, 4}, {6, 6}, {4, 6}, {4, 4}},
+	})
+	for _, tc := range []struct {
+		lat, lon float64
+		want     bool
+	}{
+		{1, 1, true},
+		{5, 5, false},
+		{5, 11, false},
+		{-1, 5, false},
+		{9, 9, true},
+	} {
+		if got := square.Contains(tc.lat, tc.lon); got != tc.want {
+			t.Errorf("Contains(%v, %v) = %v, want %v", tc.lat, tc.lon, got, tc.want)
+		}
+	}
+	if a := square.Area(); a != 100 {
+		t.Errorf("area = %v, want 100", a)
+	}
+}
+
+func TestPolygonAntimeridian(t *testing.T) {
+	// The same area written wrapping to -180 and continuing past 180
+	for _, ring := range [][][2]float64{
+		{{170, -10}, {-170, -10}, {-170, 10}, {170, 10}},
+		{{170, -10}, {190, -10}, {190, 10}, {170, 10}},
+	} {
+		p := NewPolygon([][][2]float64{ring})
+		if !p.Contains(0, 179) || !p.Contains(0, -179) || p.Contains(0, 0) || p.Contains(0, 160) {
+			t.Errorf("antimeridian containment is wrong for %v", ring)
+		}
+		if b := p.Bounds(); b.West != 170 || b.East != -170 {
+			t.Errorf("bounds = %+v, want west 170 and east -170", b)
+		}
+	}
+}
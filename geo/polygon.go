@@ -0,0 +1,144 @@
+package geo
+
+import "math"
+
+// Polygon is an area bounded by rings of [longitude, latitude] points, the
+// first the outer boundary and any others holes. Rings may cross the
+// antimeridian, either wrapping from 180 to -180 or continuing beyond it.
+type Polygon struct {
+	// Rings with longitudes made continuous, so that no edge spans more
+	// than 180 degrees
+	rings [][][2]float64
+	// Bounds of the outer ring in continuous longitudes
+	west, south, east, north float64
+}
+
+// NewPolygon builds a polygon from its rings. Rings need not be closed.
+func NewPolygon(rings [][][2]float64) Polygon {
+	p := Polygon{west: math.Inf(1), south: math.Inf(1), east: math.Inf(-1), north: math.Inf(-1)}
+	for i, ring := range rings {
+		unwrapped := unwrapRing(ring)
+		if len(unwrapped) < 3 {
+			if i == 0 {
+				return Polygon{}
+			}
+			continue
+		}
+		if i > 0 {
+			// Holes lie on the same turn of longitude as the outer ring
+			shift := math.Round((p.rings[0][0][0]-unwrapped[0][0])/360) * 360
+			for k := range unwrapped {
+				unwrapped[k][0] += shift
+			}
+			p.rings = append(p.rings, unwrapped)
+			continue
+		}
+		p.rings = append(p.rings, unwrapped)
+		for _, pt := range unwrapped {
+			p.west, p.east = math.Min(p.west, pt[0]), math.Max(p.east, pt[0])
+			p.south, p.north = math.Min(p.south, pt[1]), math.Max(p.north, pt[1])
+		}
+	}
+	return p
+}
+
+// unwrapRing shifts longitudes by whole turns so that successive points
+// are never more than 180 degrees apart
+func unwrapRing(ring [][2]float64) [][2]float64 {
+	unwrapped := make([][2]float64, 0, len(ring))
+	for i, pt := range ring {
+		if i > 0 {
+			prev := unwrapped[i-1][0]
+			for pt[0]-prev > 180 {
+				pt[0] -= 360
+			}
+			for pt[0]-prev < -180 {
+				pt[0] += 360
+			}
+		}
+		unwrapped = append(unwrapped, pt)
+	}
+	return unwrapped
+}
+
+// Empty reports whether the polygon has no outer ring
+func (p Polygon) Empty() bool {
+	return len(p.rings) == 0
+}
+
+// Bounds returns the box around the polygon's outer ring
+func (p Polygon) Bounds() Box {
+	if p.Empty() {
+		return Box{}
+	}
+	if p.east-p.west >= 360 {
+		return Box{West: -180, South: p.south, East: 180, North: p.north}
+	}
+	return Box{West: wrapLongitude(p.west), South: p.south, East: wrapLongitude(p.east), North: p.north}
+}
+
+// Center returns the middle of the box around the polygon's outer ring
+func (p Polygon) Center() (lat, lon float64) {
+	return (p.south + p.north) / 2, wrapLongitude((p.west + p.east) / 2)
+}
+
+// Area returns the area of the outer ring in square degrees of latitude and
+// longitude, for telling overlapping polygons apart by size
+func (p Polygon) Area() float64 {
+	if p.Empty() {
+		return 0
+	}
+	ring := p.rings[0]
+	var sum float64
+	for i := range ring {
+		j := (i + 1) % len(ring)
+		sum += ring[i][0]*ring[j][1] - ring[j][0]*ring[i][1]
+	}
+	return math.Abs(sum) / 2
+}
+
+// Contains reports whether a point lies inside the polygon and outside its
+// holes. Points exactly on an edge may fall either way.
+func (p Polygon) Contains(lat, lon float64) bool {
+	if p.Empty() || lat < p.south || lat > p.north {
+		return false
+	}
+
+	// Try the point at each turn of longitude the polygon reaches
+	for lon > p.west {
+		lon -= 360
+	}
+	for ; lon <= p.east; lon += 360 {
+		if lon < p.west {
+			continue
+		}
+		if !ringContains(p.rings[0], lat, lon) {
+			continue
+		}
+		inHole := false
+		for _, hole := range p.rings[1:] {
+			if ringContains(hole, lat, lon) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains tests a point against a ring by counting the edges a ray
+// running east from it crosses
+func ringContains(ring [][2]float64, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -3,7 +3,8 @@ module github.com/vainnor/vatsim-stats
 go 1.22
 
 require (
-	github.com/gorilla/mux v1.8.1 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/lib/pq v1.10.9 // indirect
+	github.com/gorilla/mux v1.8.1
+	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -19,12 +19,30 @@ func main() {
 		log.Printf("Warning: Error loading .env file: %v", err)
 	}
 
-	// Initialize database connection
-	if err := db.InitDB(); err != nil {
+	command := ""
+	if len(os.Args) > 1 {
+		command = os.Args[1]
+	}
+
+	// Initialize database connection. The migrate command manages the
+	// schema itself, everything else requires it to be current.
+	initDB := db.InitDB
+	if command == "migrate" {
+		initDB = db.Open
+	}
+	if err := initDB(); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.CloseDB()
 
+	// Run a subcommand instead of the service if one was given
+	if command != "" {
+		if err := runCommand(command, os.Args[2:]); err != nil {
+			log.Fatalf("%s: %v", command, err)
+		}
+		return
+	}
+
 	// Get update interval from environment variable (default to 15 seconds)
 	updateInterval := 15
 	if intervalStr := os.Getenv("UPDATE_INTERVAL"); intervalStr != "" {
@@ -33,11 +51,78 @@ func main() {
 		}
 	}
 
+	// Select the data source (defaults to the live VATSIM feed)
+	source, err := collector.NewDataSource(os.Getenv("DATA_SOURCE"))
+	if err != nil {
+		log.Fatalf("Failed to configure data source: %v", err)
+	}
+
 	// Create and start collector
-	c := collector.NewCollector()
+	c := collector.NewCollector(source)
 	ticker := time.NewTicker(time.Duration(updateInterval) * time.Second)
 	defer ticker.Stop()
 
+	// Read server metadata from the VATSIM server list (defaults to the
+	// live vatsim-servers.json, "off" uses only the data feed's servers)
+	serverSource, err := collector.NewServerSource(os.Getenv("SERVER_LIST"))
+	if err != nil {
+		log.Fatalf("Failed to configure server list: %v", err)
+	}
+	c.SetServerSource(serverSource)
+
+	// Optionally record every raw payload for later replay
+	if archiveDir := os.Getenv("ARCHIVE_DIR"); archiveDir != "" {
+		archive, err := collector.NewArchive(archiveDir)
+		if err != nil {
+			log.Fatalf("Failed to configure archive: %v", err)
+		}
+		c.SetArchive(archive)
+		log.Printf("Archiving raw snapshots to %s", archiveDir)
+	}
+
+	// Use airport positions for flight phase detection, if imported
+	if dir := loadAirports(); dir != nil {
+		c.SetAirports(dir)
+		go refreshAirports(dir)
+	}
+
+	// Attribute pilot positions to FIRs, if boundaries were imported
+	if index := loadFIRs(); index != nil {
+		c.SetFIRs(index)
+		api.SetFIRs(index)
+		go refreshFIRs(index)
+	}
+
+	// Group FIRs into the regions of the network statistics
+	regions, err := loadRegions()
+	if err != nil {
+		log.Fatalf("Failed to configure regions: %v", err)
+	}
+	api.SetRegions(regions)
+
+	// Group controllers into the facilities of the registry, if configured
+	registry, err := loadFacilities()
+	if err != nil {
+		log.Fatalf("Failed to configure facilities: %v", err)
+	}
+	if registry != nil {
+		c.SetFacilities(registry)
+		api.SetFacilities(registry)
+	}
+
+	// Refresh the leaderboard rankings periodically (default 15 minutes,
+	// 0 disables)
+	leaderboardRefresh := 15
+	if refreshStr := os.Getenv("LEADERBOARD_REFRESH_MINUTES"); refreshStr != "" {
+		if refresh, err := strconv.Atoi(refreshStr); err == nil {
+			leaderboardRefresh = refresh
+		}
+	}
+	c.SetLeaderboardRefresh(time.Duration(leaderboardRefresh) * time.Minute)
+
+	// Downsample and expire raw snapshot partitions in the background
+	go runRetention(retentionPolicyFromEnv())
+
 	// Set up API routes with the new router
 	router := api.NewRouter(c)
 
@@ -49,7 +134,7 @@ func main() {
 		}
 	}()
 
-	log.Printf("Starting VATSIM data collector (update interval: %d seconds)", updateInterval)
+	log.Printf("Starting VATSIM data collector (source: %s, update interval: %d seconds)", source, updateInterval)
 
 	// Initial collection
 	if err := c.FetchAndStore(); err != nil {
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/vainnor/vatsim-stats/db"
+)
+
+// retentionInterval is how often the retention policy is applied
+const retentionInterval = time.Hour
+
+// retentionPolicyFromEnv reads the raw snapshot retention settings
+// (defaults: downsample after 24 hours, drop after 90 days)
+func retentionPolicyFromEnv() db.RetentionPolicy {
+	downsampleHours := 24
+	if v := os.Getenv("SNAPSHOT_DOWNSAMPLE_AFTER_HOURS"); v != "" {
+		if hours, err := strconv.Atoi(v); err == nil {
+			downsampleHours = hours
+		}
+	}
+
+	retentionDays := 90
+	if v := os.Getenv("SNAPSHOT_RETENTION_DAYS"); v != "" {
+		if days, err := strconv.Atoi(v); err == nil {
+			retentionDays = days
+		}
+	}
+
+	return db.RetentionPolicy{
+		DownsampleAfter: time.Duration(downsampleHours) * time.Hour,
+		Retention:       time.Duration(retentionDays) * 24 * time.Hour,
+	}
+}
+
+// runRetention applies the retention policy now and then periodically
+func runRetention(policy db.RetentionPolicy) {
+	log.Printf("Snapshot retention: downsample after %v, drop after %v", policy.DownsampleAfter, policy.Retention)
+
+	for {
+		if err := db.ApplyRetention(policy, time.Now()); err != nil {
+			log.Printf("Error applying snapshot retention: %v", err)
+		}
+		time.Sleep(retentionInterval)
+	}
+}
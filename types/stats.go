@@ -8,6 +8,7 @@ type CollectionStats struct {
 	ActivePilots    int       `json:"active_pilots"`
 	ActiveATCs      int       `json:"active_atcs"`
 	ActiveATIS      int       `json:"active_atis"`
+	ActiveObservers int       `json:"active_observers"`
 	ProcessedPilots int64     `json:"processed_pilots"`
 	StartTime       time.Time `json:"start_time"`
 }
@@ -6,6 +6,7 @@ type VatsimData struct {
 	General     General      `json:"general"`
 	Pilots      []Pilot      `json:"pilots"`
 	Controllers []Controller `json:"controllers"`
+	Servers     []Server     `json:"servers"`
 	Facilities  []Facility   `json:"facilities"`
 	Ratings     []Rating     `json:"ratings"`
 }
@@ -58,6 +59,17 @@ type FlightPlan struct {
 	AssignedTransponder string `json:"assigned_transponder"`
 }
 
+// Server is a network server, as listed in the data feed and in the
+// vatsim-servers.json server list
+type Server struct {
+	Ident                    string `json:"ident"`
+	Hostname                 string `json:"hostname_or_ip"`
+	Location                 string `json:"location"`
+	Name                     string `json:"name"`
+	ClientConnectionsAllowed bool   `json:"client_connections_allowed"`
+	IsSweatbox               bool   `json:"is_sweatbox"`
+}
+
 type Facility struct {
 	ID    int    `json:"id"`
 	Short string `json:"short"`